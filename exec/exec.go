@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"io"
@@ -21,6 +22,12 @@ type ExecuteInterface interface {
 	ExecuteWithStdinPipe(binary string, args []string, stdinString string, timeout time.Duration) (string, error)
 }
 
+// StreamExecuteInterface is the interface for executing long-running commands
+// whose output is consumed line by line while they are running.
+type StreamExecuteInterface interface {
+	ExecuteWithStdinStream(ctx context.Context, binary string, args []string, stdinString string, lineFn func(line string)) (string, error)
+}
+
 // NewExecutor returns a new Executor.
 func NewExecutor() ExecuteInterface {
 	return &Executor{}
@@ -123,3 +130,69 @@ func (e *Executor) ExecuteWithStdinPipe(binary string, args []string, stdinStrin
 
 	return e.executeCmd(cmd, timeout)
 }
+
+// ExecuteWithStdinStream executes the command with stdin and calls lineFn for
+// every line the command writes to stdout. Both "\n" and "\r" are treated as
+// line terminators so that progress output is delivered as it is printed.
+// When ctx is done, the command is interrupted with SIGINT, giving it a chance
+// to leave a consistent state behind, and killed if it does not exit within
+// types.ExecuteCancelGracePeriod.
+func (e *Executor) ExecuteWithStdinStream(ctx context.Context, binary string, args []string, stdinString string, lineFn func(line string)) (string, error) {
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Env = os.Environ()
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = types.ExecuteCancelGracePeriod
+
+	if stdinString != "" {
+		cmd.Stdin = strings.NewReader(stdinString)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", errors.Wrapf(err, "failed to start: %v %v", cmd.Path, cmd.Args)
+	}
+
+	var output bytes.Buffer
+	scanner := bufio.NewScanner(io.TeeReader(stdout, &output))
+	scanner.Split(scanLinesOrCarriageReturns)
+	for scanner.Scan() {
+		if lineFn != nil && scanner.Text() != "" {
+			lineFn(scanner.Text())
+		}
+	}
+	// Drain whatever is left so that the command never blocks on a full pipe.
+	_, _ = io.Copy(io.Discard, io.TeeReader(stdout, &output))
+
+	if err := cmd.Wait(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = errors.Wrap(ctxErr, err.Error())
+		}
+		return output.String(), errors.Wrapf(err, "failed to execute: %v %v, output %s, stderr %s",
+			cmd.Path, cmd.Args, output.String(), stderr.String())
+	}
+	return output.String(), nil
+}
+
+// scanLinesOrCarriageReturns is a bufio.SplitFunc that splits the input at
+// either "\n" or "\r".
+func scanLinesOrCarriageReturns(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -1,10 +1,12 @@
 package exec
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/longhorn/go-common-libs/types"
@@ -133,3 +135,57 @@ func TestExecuteWithStdinPipe(t *testing.T) {
 		})
 	}
 }
+
+func TestExecuteWithStdinStream(t *testing.T) {
+	type testCase struct {
+		command      []string
+		commandStdin string
+		cancelAfter  time.Duration
+
+		expected      string
+		expectedLines []string
+		expectError   bool
+	}
+	testCases := map[string]testCase{
+		"Streams lines and carriage returns": {
+			command:       []string{"bash", "-c", `printf 'a\rb\nc\n'`},
+			expected:      "a\rb\nc\n",
+			expectedLines: []string{"a", "b", "c"},
+		},
+		"Echo stdin input": {
+			command:       []string{"bash", "-c", "read input; echo ${input}"},
+			commandStdin:  "foo",
+			expected:      "foo\n",
+			expectedLines: []string{"foo"},
+		},
+		"Command is interrupted on cancellation": {
+			command:     []string{"sleep", "10"},
+			cancelAfter: 100 * time.Millisecond,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if testCase.cancelAfter > 0 {
+				time.AfterFunc(testCase.cancelAfter, cancel)
+			}
+
+			executor := &Executor{}
+
+			var lines []string
+			output, err := executor.ExecuteWithStdinStream(ctx, testCase.command[0], testCase.command[1:], testCase.commandStdin, func(line string) {
+				lines = append(lines, line)
+			})
+			if testCase.expectError {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, context.Canceled))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, output)
+			assert.Equal(t, testCase.expectedLines, lines)
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package io
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// NewChecksumHash returns a new hash.Hash computing the checksum algorithm.
+func NewChecksumHash(algorithm types.ChecksumAlgorithm) (hash.Hash, error) {
+	switch algorithm {
+	case types.ChecksumAlgorithmSHA256:
+		return sha256.New(), nil
+	case types.ChecksumAlgorithmSHA512:
+		return sha512.New(), nil
+	case types.ChecksumAlgorithmXXHash64:
+		return xxhash.New(), nil
+	case types.ChecksumAlgorithmCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	default:
+		return nil, errors.Errorf("unknown checksum algorithm %q", algorithm)
+	}
+}
+
+// GetFileChecksum returns the hex encoded checksum of the whole content of the
+// file. The holes of a sparse file are hashed as zeros without being read.
+func GetFileChecksum(ctx context.Context, path string, algorithm types.ChecksumAlgorithm) (string, error) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get checksum of %v", path)
+	}
+	return GetFileRangeChecksum(ctx, path, algorithm, 0, fileInfo.Size())
+}
+
+// GetFileRangeChecksum returns the hex encoded checksum of length bytes of the
+// file starting at offset. The holes of a sparse file are hashed as zeros
+// without being read.
+func GetFileRangeChecksum(ctx context.Context, path string, algorithm types.ChecksumAlgorithm, offset, length int64) (checksum string, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get checksum of %v range [%v, %v)", path, offset, offset+length)
+	}()
+
+	hasher, err := NewChecksumHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if err := hashFileRange(ctx, hasher, path, offset, length); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// hashFileRange writes length bytes of the file starting at offset to hasher.
+// Only the data extents are read, the holes are written as zeros.
+func hashFileRange(ctx context.Context, hasher hash.Hash, path string, offset, length int64) error {
+	if offset < 0 || length < 0 {
+		return errors.Errorf("invalid range offset %v length %v", offset, length)
+	}
+
+	extents, err := GetFileExtents(path)
+	if err != nil {
+		return err
+	}
+
+	end := offset + length
+	if size := GetExtentsSize(extents); end > size {
+		return errors.Errorf("range end %v is beyond the file size %v", end, size)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", path)
+		}
+	}()
+
+	buffer := make([]byte, copyChunkSize)
+	zeros := make([]byte, copyChunkSize)
+	for _, extent := range extents {
+		chunkStart := max(extent.Offset, offset)
+		extentEnd := min(extent.Offset+extent.Length, end)
+		for chunkStart < extentEnd {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			chunkLength := min(copyChunkSize, extentEnd-chunkStart)
+			if extent.Type == types.FileExtentTypeHole {
+				_, _ = hasher.Write(zeros[:chunkLength])
+			} else {
+				chunk := buffer[:chunkLength]
+				if _, err := file.ReadAt(chunk, chunkStart); err != nil {
+					return errors.Wrapf(err, "failed to read range [%v, %v)", chunkStart, chunkStart+chunkLength)
+				}
+				_, _ = hasher.Write(chunk)
+			}
+			chunkStart += chunkLength
+		}
+	}
+	return nil
+}
+
+// GetDirectoryChecksumManifest returns the checksums of the regular files in
+// the directory tree and the root digest of the tree. Symbolic links and other
+// special files are not included.
+func GetDirectoryChecksumManifest(ctx context.Context, directory string, algorithm types.ChecksumAlgorithm) (manifest *types.ChecksumManifest, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get checksum manifest of %v", directory)
+	}()
+
+	manifest = &types.ChecksumManifest{
+		Algorithm: algorithm,
+		Files:     []types.FileChecksum{},
+	}
+
+	err = filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+
+		digest, err := GetFileRangeChecksum(ctx, path, algorithm, 0, fileInfo.Size())
+		if err != nil {
+			return err
+		}
+
+		manifest.Files = append(manifest.Files, types.FileChecksum{
+			Path:   filepath.ToSlash(relativePath),
+			Size:   fileInfo.Size(),
+			Digest: digest,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.Digest, err = getChecksumManifestDigest(algorithm, manifest.Files)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// getChecksumManifestDigest hashes the sorted file entries, one line per file
+// with the digest, size and path, into the root digest of the manifest.
+func getChecksumManifestDigest(algorithm types.ChecksumAlgorithm, files []types.FileChecksum) (string, error) {
+	sorted := make([]types.FileChecksum, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	hasher, err := NewChecksumHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range sorted {
+		_, _ = fmt.Fprintf(hasher, "%s %d %s\n", file.Digest, file.Size, file.Path)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// VerifyChecksumManifest checks the directory tree against the manifest. It
+// returns the relative paths of the files that are changed, missing or not in
+// the manifest. An empty result means the tree matches the manifest.
+func VerifyChecksumManifest(ctx context.Context, directory string, manifest *types.ChecksumManifest) (mismatchedPaths []string, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to verify checksum manifest of %v", directory)
+	}()
+
+	if manifest == nil {
+		return nil, errors.New("manifest is nil")
+	}
+
+	current, err := GetDirectoryChecksumManifest(ctx, directory, manifest.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if current.Digest == manifest.Digest {
+		return []string{}, nil
+	}
+
+	expected := make(map[string]types.FileChecksum, len(manifest.Files))
+	for _, file := range manifest.Files {
+		expected[file.Path] = file
+	}
+
+	mismatchedPaths = []string{}
+	for _, file := range current.Files {
+		expectedFile, exists := expected[file.Path]
+		if !exists || expectedFile != file {
+			mismatchedPaths = append(mismatchedPaths, file.Path)
+		}
+		delete(expected, file.Path)
+	}
+	for path := range expected {
+		mismatchedPaths = append(mismatchedPaths, path)
+	}
+	if len(mismatchedPaths) == 0 {
+		return nil, errors.Errorf("root digest %v does not match the files of the manifest", manifest.Digest)
+	}
+	sort.Strings(mismatchedPaths)
+	return mismatchedPaths, nil
+}
@@ -0,0 +1,159 @@
+package io
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestGetFileRangeChecksum(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	filePath := filepath.Join(fakeDir, "file")
+	err := os.WriteFile(filePath, []byte("abc"), 0644)
+	assert.NoError(t, err)
+
+	sparseFile := fake.CreateTempSparseFile(fakeDir, "sparse", 2*copyChunkSize+1, "abc", t)
+	_ = sparseFile.Close()
+	denseFilePath := filepath.Join(fakeDir, "dense")
+	denseContent := make([]byte, 2*copyChunkSize+1)
+	copy(denseContent, "abc")
+	err = os.WriteFile(denseFilePath, denseContent, 0644)
+	assert.NoError(t, err)
+
+	type testCase struct {
+		algorithm types.ChecksumAlgorithm
+		offset    int64
+		length    int64
+
+		expectedChecksum string
+		expectError      bool
+	}
+	testCases := map[string]testCase{
+		"SHA-256": {
+			algorithm:        types.ChecksumAlgorithmSHA256,
+			length:           3,
+			expectedChecksum: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		},
+		"SHA-512": {
+			algorithm:        types.ChecksumAlgorithmSHA512,
+			length:           3,
+			expectedChecksum: "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f",
+		},
+		"xxHash64": {
+			algorithm:        types.ChecksumAlgorithmXXHash64,
+			length:           3,
+			expectedChecksum: "44bc2cf5ad770999",
+		},
+		"CRC32C": {
+			algorithm:        types.ChecksumAlgorithmCRC32C,
+			length:           3,
+			expectedChecksum: "364b3fb7",
+		},
+		"Byte range": {
+			algorithm:        types.ChecksumAlgorithmSHA256,
+			offset:           1,
+			length:           1,
+			expectedChecksum: "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d",
+		},
+		"Range beyond file size": {
+			algorithm:   types.ChecksumAlgorithmSHA256,
+			offset:      1,
+			length:      3,
+			expectError: true,
+		},
+		"Unknown algorithm": {
+			algorithm:   "md5",
+			length:      3,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			checksum, err := GetFileRangeChecksum(context.Background(), filePath, testCase.algorithm, testCase.offset, testCase.length)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expectedChecksum, checksum, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+
+	t.Run("Sparse file", func(t *testing.T) {
+		sparseChecksum, err := GetFileChecksum(context.Background(), sparseFile.Name(), types.ChecksumAlgorithmSHA256)
+		assert.NoError(t, err)
+		denseChecksum, err := GetFileChecksum(context.Background(), denseFilePath, types.ChecksumAlgorithmSHA256)
+		assert.NoError(t, err)
+		assert.Equal(t, denseChecksum, sparseChecksum)
+	})
+}
+
+func TestVerifyChecksumManifest(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	type testCase struct {
+		modify func(directory string) error
+
+		expectedMismatchedPaths []string
+	}
+	testCases := map[string]testCase{
+		"Unchanged tree": {
+			modify: func(directory string) error {
+				return nil
+			},
+			expectedMismatchedPaths: []string{},
+		},
+		"Changed file": {
+			modify: func(directory string) error {
+				return os.WriteFile(filepath.Join(directory, "sub", "file2"), []byte("changed"), 0644)
+			},
+			expectedMismatchedPaths: []string{"sub/file2"},
+		},
+		"Missing and added files": {
+			modify: func(directory string) error {
+				if err := os.Remove(filepath.Join(directory, "file1")); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(directory, "file3"), []byte("added"), 0644)
+			},
+			expectedMismatchedPaths: []string{"file1", "file3"},
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			directory := fake.CreateTempDirectory(fakeDir, t)
+			err := os.MkdirAll(filepath.Join(directory, "sub"), 0755)
+			assert.NoError(t, err)
+			err = os.WriteFile(filepath.Join(directory, "file1"), []byte("content1"), 0644)
+			assert.NoError(t, err)
+			err = os.WriteFile(filepath.Join(directory, "sub", "file2"), []byte("content2"), 0644)
+			assert.NoError(t, err)
+
+			manifest, err := GetDirectoryChecksumManifest(context.Background(), directory, types.ChecksumAlgorithmSHA256)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Len(t, manifest.Files, 2, Commentf(test.ErrResultFmt, testName))
+
+			err = testCase.modify(directory)
+			assert.NoError(t, err)
+
+			mismatchedPaths, err := VerifyChecksumManifest(context.Background(), directory, manifest)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expectedMismatchedPaths, mismatchedPaths, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
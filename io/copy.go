@@ -0,0 +1,417 @@
+package io
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// copyChunkSize is the size of the chunks copied between two checks of the
+// context, the bandwidth limit and the progress.
+const copyChunkSize = 1024 * 1024
+
+// CopyOptions defines optional parameters used when copying files.
+type CopyOptions struct {
+	// OverWrite overwrites the existing destination files. They are skipped otherwise.
+	OverWrite bool
+	// ProgressFn, if not nil, is called with the progress of the copy.
+	ProgressFn func(types.CopyProgress)
+	// ProgressInterval is the minimal interval between two progress reports.
+	// Zero reports the progress after every chunk. The final progress is always reported.
+	ProgressInterval time.Duration
+	// BandwidthLimit is the maximal rate of the copy in bytes per second. Zero means unlimited.
+	BandwidthLimit int64
+	// Checksum computes the SHA-256 checksum of every file while it is copied.
+	Checksum bool
+
+	// PreserveMode keeps the permission bits, including setuid, setgid and
+	// sticky. Otherwise directories are created with 0755 and files with 0666,
+	// both before umask.
+	PreserveMode bool
+	// PreserveOwnership keeps the uid and gid. It usually requires root.
+	PreserveOwnership bool
+	// PreserveXattrs keeps the extended attributes, including security.selinux
+	// and security.capability.
+	PreserveXattrs bool
+	// CopySymlinks copies symbolic links as links. Otherwise they are followed
+	// and the content of their target is copied.
+	CopySymlinks bool
+	// PreserveHardlinks links the destination files whose sources are hard
+	// links to the same inode, instead of copying the content again.
+	PreserveHardlinks bool
+}
+
+// CopyDirectoryWithOptions copies the directory from source to destination
+// like CopyDirectory. The copy stops with the context error when ctx is done.
+//
+// Files are copied in chunks when ctx can be cancelled or any of progress,
+// bandwidth limit or checksum is requested, so the holes of sparse files are
+// still preserved but the files are no longer reflinked.
+func CopyDirectoryWithOptions(ctx context.Context, sourcePath, destinationPath string, options *CopyOptions) (result *types.CopyResult, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to copy directory %v to %v", sourcePath, destinationPath)
+	}()
+
+	c := newCopier(ctx, options)
+	if err := c.scan(sourcePath); err != nil {
+		return nil, err
+	}
+	if err := c.copyDirectory(sourcePath, destinationPath); err != nil {
+		return nil, err
+	}
+	return c.finish(), nil
+}
+
+// CopyFilesWithOptions copies the files from source to destination like
+// CopyFiles. See CopyDirectoryWithOptions for the meaning of ctx and options.
+func CopyFilesWithOptions(ctx context.Context, sourcePath, destinationPath string, options *CopyOptions) (result *types.CopyResult, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to copy files %v to %v", sourcePath, destinationPath)
+	}()
+
+	c := newCopier(ctx, options)
+	if err := c.scan(sourcePath); err != nil {
+		return nil, err
+	}
+	if err := c.copyFiles(sourcePath, destinationPath); err != nil {
+		return nil, err
+	}
+	return c.finish(), nil
+}
+
+// copier holds the state of a copy operation.
+type copier struct {
+	ctx     context.Context
+	options CopyOptions
+	limiter *rate.Limiter
+
+	progress   types.CopyProgress
+	lastReport time.Time
+	checksums  map[string]string
+
+	// hardlinks maps the source inodes with more than one link to the first
+	// destination path they are copied to.
+	hardlinks map[fileInode]string
+}
+
+// fileInode identifies a file across hard links.
+type fileInode struct {
+	dev uint64
+	ino uint64
+}
+
+func newCopier(ctx context.Context, options *CopyOptions) *copier {
+	c := &copier{ctx: ctx}
+	if options != nil {
+		c.options = *options
+	}
+	if c.options.BandwidthLimit > 0 {
+		c.limiter = rate.NewLimiter(rate.Limit(c.options.BandwidthLimit), copyChunkSize)
+	}
+	if c.options.Checksum {
+		c.checksums = map[string]string{}
+	}
+	if c.options.PreserveHardlinks {
+		c.hardlinks = map[fileInode]string{}
+	}
+	return c
+}
+
+// isChunked checks if the file content must be copied in chunks instead of
+// being reflinked or copied by the kernel in one go.
+func (c *copier) isChunked() bool {
+	return c.ctx.Done() != nil || c.options.ProgressFn != nil || c.limiter != nil || c.options.Checksum
+}
+
+// scan counts the files and bytes to copy, it is only needed to report the progress.
+func (c *copier) scan(sourcePath string) error {
+	if c.options.ProgressFn == nil {
+		return nil
+	}
+
+	return filepath.WalkDir(sourcePath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		c.progress.TotalFiles++
+		if c.options.CopySymlinks && entry.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		c.progress.TotalBytes += fileInfo.Size()
+		return nil
+	})
+}
+
+func (c *copier) finish() *types.CopyResult {
+	c.reportProgress(true)
+	return &types.CopyResult{
+		CopiedBytes: c.progress.CopiedBytes,
+		CopiedFiles: c.progress.CopiedFiles,
+		Checksums:   c.checksums,
+	}
+}
+
+func (c *copier) reportProgress(force bool) {
+	if c.options.ProgressFn == nil {
+		return
+	}
+
+	now := time.Now()
+	if !force && c.options.ProgressInterval > 0 && now.Sub(c.lastReport) < c.options.ProgressInterval {
+		return
+	}
+	c.lastReport = now
+	c.options.ProgressFn(c.progress)
+}
+
+func (c *copier) addCopiedBytes(length int64) {
+	c.progress.CopiedBytes += length
+	c.reportProgress(false)
+}
+
+func (c *copier) copyDirectory(sourcePath, destinationPath string) error {
+	sourcePathInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	destinationAbsPath, err := CreateDirectory(destinationPath, sourcePathInfo.ModTime())
+	if err != nil {
+		return err
+	}
+
+	if err := c.copyFiles(sourcePath, destinationAbsPath); err != nil {
+		return err
+	}
+
+	// The attributes are copied last, so that a read-only source directory
+	// does not prevent copying its content.
+	return c.copyAttributes(sourcePath, destinationAbsPath, sourcePathInfo)
+}
+
+func (c *copier) copyFiles(sourcePath, destinationPath string) error {
+	srcFileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	if !srcFileInfo.IsDir() {
+		if c.options.CopySymlinks {
+			if linkInfo, err := os.Lstat(sourcePath); err == nil && linkInfo.Mode()&os.ModeSymlink != 0 {
+				return c.copySymlink(sourcePath, destinationPath, linkInfo)
+			}
+		}
+		return c.copyFile(sourcePath, destinationPath)
+	}
+
+	srcFileInfos, err := os.ReadDir(sourcePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read source directory %v", sourcePath)
+	}
+
+	for _, srcFileInfo := range srcFileInfos {
+		srcFileInfo, err := srcFileInfo.Info()
+		if err != nil {
+			return err
+		}
+
+		dstFilePath := filepath.Join(destinationPath, srcFileInfo.Name())
+		srcFilePath := filepath.Join(sourcePath, srcFileInfo.Name())
+
+		if srcFileInfo.IsDir() {
+			if err := c.copyDirectory(srcFilePath, dstFilePath); err != nil {
+				return err
+			}
+		} else if c.options.CopySymlinks && srcFileInfo.Mode()&os.ModeSymlink != 0 {
+			if err := c.copySymlink(srcFilePath, dstFilePath, srcFileInfo); err != nil {
+				return err
+			}
+		} else {
+			if err := c.copyFile(srcFilePath, dstFilePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *copier) copyFile(sourcePath, destinationPath string) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to copy file %v to %v", sourcePath, destinationPath)
+	}()
+
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+
+	sourceFileInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	if !c.options.OverWrite {
+		if _, err := os.Stat(destinationPath); err == nil {
+			logrus.Warnf("destination file %v already exists", destinationPath)
+			c.progress.TotalFiles--
+			c.progress.TotalBytes -= sourceFileInfo.Size()
+			return nil
+		}
+	}
+
+	_, err = CreateDirectory(filepath.Dir(destinationPath), sourceFileInfo.ModTime())
+	if err != nil {
+		return err
+	}
+
+	isLinked, err := c.linkHardlink(destinationPath, sourceFileInfo)
+	if err != nil || isLinked {
+		return err
+	}
+
+	sourceFile, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := sourceFile.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close source file %v", sourcePath)
+		}
+	}()
+
+	destinationFile, err := os.Create(destinationPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := destinationFile.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close destination file %v", destinationPath)
+		}
+	}()
+
+	c.progress.CurrentFile = sourcePath
+	if c.isChunked() {
+		checksum, err := c.copyFileContentInChunks(destinationFile, sourceFile, sourceFileInfo.Size())
+		if err != nil {
+			return err
+		}
+		if c.options.Checksum {
+			c.checksums[destinationPath] = checksum
+		}
+	} else {
+		if err := copyFileContent(destinationFile, sourceFile, sourceFileInfo.Size()); err != nil {
+			return err
+		}
+		c.progress.CopiedBytes += sourceFileInfo.Size()
+	}
+
+	if err := c.copyAttributes(sourcePath, destinationPath, sourceFileInfo); err != nil {
+		return err
+	}
+
+	// Set the modification time of the file.
+	sourceFileModTime := sourceFileInfo.ModTime()
+	if err := os.Chtimes(destinationPath, sourceFileModTime, sourceFileModTime); err != nil {
+		return err
+	}
+
+	c.recordHardlink(destinationPath, sourceFileInfo)
+	c.progress.CopiedFiles++
+	c.reportProgress(false)
+	return nil
+}
+
+// copyFileContentInChunks copies the data extents of the source file in chunks
+// to the same offsets of the destination file, so that the holes are preserved.
+// It returns the SHA-256 checksum of the whole content if checksums are requested.
+func (c *copier) copyFileContentInChunks(destinationFile, sourceFile *os.File, size int64) (string, error) {
+	if err := destinationFile.Truncate(size); err != nil {
+		return "", err
+	}
+
+	extents, err := GetFileExtents(sourceFile.Name())
+	if err != nil {
+		return "", err
+	}
+
+	var hasher hash.Hash
+	var buffer, zeros []byte
+	if c.options.Checksum {
+		hasher = sha256.New()
+		buffer = make([]byte, copyChunkSize)
+		zeros = make([]byte, copyChunkSize)
+	}
+
+	for _, extent := range extents {
+		if extent.Type == types.FileExtentTypeHole && hasher == nil {
+			c.addCopiedBytes(extent.Length)
+			continue
+		}
+
+		end := extent.Offset + extent.Length
+		for offset := extent.Offset; offset < end; {
+			if err := c.ctx.Err(); err != nil {
+				return "", err
+			}
+
+			length := min(copyChunkSize, end-offset)
+			switch {
+			case extent.Type == types.FileExtentTypeHole:
+				_, _ = hasher.Write(zeros[:length])
+			case hasher == nil:
+				if err := c.waitBandwidth(length); err != nil {
+					return "", err
+				}
+				if err := copyRange(destinationFile, sourceFile, offset, length); err != nil {
+					return "", errors.Wrapf(err, "failed to copy range [%v, %v)", offset, offset+length)
+				}
+			default:
+				if err := c.waitBandwidth(length); err != nil {
+					return "", err
+				}
+				chunk := buffer[:length]
+				if _, err := sourceFile.ReadAt(chunk, offset); err != nil {
+					return "", errors.Wrapf(err, "failed to read range [%v, %v)", offset, offset+length)
+				}
+				_, _ = hasher.Write(chunk)
+				if _, err := destinationFile.WriteAt(chunk, offset); err != nil {
+					return "", errors.Wrapf(err, "failed to write range [%v, %v)", offset, offset+length)
+				}
+			}
+
+			offset += length
+			c.addCopiedBytes(length)
+		}
+	}
+
+	if hasher == nil {
+		return "", nil
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func (c *copier) waitBandwidth(length int64) error {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.WaitN(c.ctx, int(length))
+}
@@ -0,0 +1,207 @@
+package io
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// copyAttributes copies the ownership, extended attributes and permission bits
+// of the source to the destination, as requested by the copy options. The
+// ownership is copied first, because changing it clears the setuid and setgid
+// bits and the file capabilities. The permission bits are copied last, because
+// a read-only mode prevents setting the user extended attributes.
+func (c *copier) copyAttributes(sourcePath, destinationPath string, sourceInfo os.FileInfo) error {
+	if c.options.PreserveOwnership {
+		if stat, ok := sourceInfo.Sys().(*syscall.Stat_t); ok {
+			if err := os.Lchown(destinationPath, int(stat.Uid), int(stat.Gid)); err != nil {
+				return errors.Wrapf(err, "failed to change ownership of %v", destinationPath)
+			}
+		}
+	}
+
+	if c.options.PreserveXattrs {
+		if err := copyXattrs(sourcePath, destinationPath); err != nil {
+			return err
+		}
+	}
+
+	// The permission bits of a symbolic link cannot be changed.
+	if c.options.PreserveMode && sourceInfo.Mode()&os.ModeSymlink == 0 {
+		mode := sourceInfo.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err := os.Chmod(destinationPath, mode); err != nil {
+			return errors.Wrapf(err, "failed to change mode of %v", destinationPath)
+		}
+	}
+	return nil
+}
+
+// copyXattrs copies the extended attributes of the source to the destination,
+// without following symbolic links.
+func copyXattrs(sourcePath, destinationPath string) error {
+	names, err := listXattrs(sourcePath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list extended attributes of %v", sourcePath)
+	}
+
+	for _, name := range names {
+		value, err := getXattr(sourcePath, name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get extended attribute %v of %v", name, sourcePath)
+		}
+		if err := unix.Lsetxattr(destinationPath, name, value, 0); err != nil {
+			return errors.Wrapf(err, "failed to set extended attribute %v of %v", name, destinationPath)
+		}
+	}
+	return nil
+}
+
+// listXattrs returns the names of the extended attributes of the path. It
+// returns no names if the filesystem does not support extended attributes.
+func listXattrs(path string) ([]string, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	buffer := make([]byte, size)
+	size, err = unix.Llistxattr(path, buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range bytes.Split(buffer[:size], []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	size, err := unix.Lgetxattr(path, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	value := make([]byte, size)
+	size, err = unix.Lgetxattr(path, name, value)
+	if err != nil {
+		return nil, err
+	}
+	return value[:size], nil
+}
+
+// copySymlink copies the symbolic link itself from source to destination.
+func (c *copier) copySymlink(sourcePath, destinationPath string, sourceInfo os.FileInfo) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to copy symbolic link %v to %v", sourcePath, destinationPath)
+	}()
+
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+
+	target, err := os.Readlink(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(destinationPath); err == nil {
+		if !c.options.OverWrite {
+			logrus.Warnf("destination file %v already exists", destinationPath)
+			c.progress.TotalFiles--
+			return nil
+		}
+		if err := os.Remove(destinationPath); err != nil {
+			return err
+		}
+	}
+
+	_, err = CreateDirectory(filepath.Dir(destinationPath), sourceInfo.ModTime())
+	if err != nil {
+		return err
+	}
+
+	if err := os.Symlink(target, destinationPath); err != nil {
+		return err
+	}
+
+	if err := c.copyAttributes(sourcePath, destinationPath, sourceInfo); err != nil {
+		return err
+	}
+
+	// Set the modification time of the link, os.Chtimes would follow it.
+	modTime := unix.NsecToTimespec(sourceInfo.ModTime().UnixNano())
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, destinationPath, []unix.Timespec{modTime, modTime}, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return err
+	}
+
+	c.progress.CopiedFiles++
+	c.reportProgress(false)
+	return nil
+}
+
+// getFileInode returns the inode of the file if it has more than one hard link.
+func getFileInode(fileInfo os.FileInfo) (fileInode, bool) {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 {
+		return fileInode{}, false
+	}
+	return fileInode{dev: uint64(stat.Dev), ino: stat.Ino}, true
+}
+
+// linkHardlink links the destination to the destination of an already copied
+// hard link of the same source inode. It returns false if there is none.
+func (c *copier) linkHardlink(destinationPath string, sourceInfo os.FileInfo) (bool, error) {
+	if c.hardlinks == nil {
+		return false, nil
+	}
+
+	inode, ok := getFileInode(sourceInfo)
+	if !ok {
+		return false, nil
+	}
+	linkedPath, exists := c.hardlinks[inode]
+	if !exists {
+		return false, nil
+	}
+
+	if err := os.Remove(destinationPath); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := os.Link(linkedPath, destinationPath); err != nil {
+		return false, err
+	}
+
+	if checksum, exists := c.checksums[linkedPath]; exists {
+		c.checksums[destinationPath] = checksum
+	}
+	c.progress.CopiedFiles++
+	c.progress.TotalBytes -= sourceInfo.Size()
+	c.reportProgress(false)
+	return true, nil
+}
+
+// recordHardlink remembers the destination of a copied source file with more
+// than one hard link.
+func (c *copier) recordHardlink(destinationPath string, sourceInfo os.FileInfo) {
+	if c.hardlinks == nil {
+		return
+	}
+	if inode, ok := getFileInode(sourceInfo); ok {
+		c.hardlinks[inode] = destinationPath
+	}
+}
@@ -0,0 +1,249 @@
+package io
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestCopyDirectoryWithOptions(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	const fileCount = 3
+	const sparseSize = 3 * copyChunkSize
+
+	type testCase struct {
+		isCancelled    bool
+		bandwidthLimit int64
+		checksum       bool
+
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Copy with progress": {},
+		"Copy with checksum": {
+			checksum: true,
+		},
+		"Copy with bandwidth limit": {
+			bandwidthLimit: 64 * copyChunkSize,
+		},
+		"Cancelled copy": {
+			isCancelled: true,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			sourceDir := fake.CreateTempDirectory(fakeDir, t)
+			subDir := fake.CreateTempDirectory(sourceDir, t)
+			for i := 0; i < fileCount; i++ {
+				file := fake.CreateTempSparseFile(subDir, fmt.Sprintf("file-%v", i), sparseSize, fmt.Sprintf("test-%v", i), t)
+				_ = file.Close()
+			}
+			destinationDir := filepath.Join(fakeDir, "destination-"+filepath.Base(sourceDir))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if testCase.isCancelled {
+				cancel()
+			}
+
+			var progresses []types.CopyProgress
+			options := &CopyOptions{
+				OverWrite:      true,
+				ProgressFn:     func(progress types.CopyProgress) { progresses = append(progresses, progress) },
+				BandwidthLimit: testCase.bandwidthLimit,
+				Checksum:       testCase.checksum,
+			}
+			result, err := CopyDirectoryWithOptions(ctx, sourceDir, destinationDir, options)
+			if testCase.expectError {
+				assert.ErrorIs(t, err, context.Canceled)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+
+			expected := types.CopyProgress{
+				CopiedBytes: fileCount * sparseSize,
+				TotalBytes:  fileCount * sparseSize,
+				CopiedFiles: fileCount,
+				TotalFiles:  fileCount,
+			}
+			assert.Equal(t, expected.CopiedBytes, result.CopiedBytes, Commentf(test.ErrResultFmt, testName))
+			assert.Equal(t, expected.CopiedFiles, result.CopiedFiles, Commentf(test.ErrResultFmt, testName))
+			if assert.NotEmpty(t, progresses) {
+				last := progresses[len(progresses)-1]
+				last.CurrentFile = ""
+				assert.Equal(t, expected, last, Commentf(test.ErrResultFmt, testName))
+			}
+
+			for i := 0; i < fileCount; i++ {
+				destinationFile := filepath.Join(destinationDir, filepath.Base(subDir), fmt.Sprintf("file-%v", i))
+				content, err := os.ReadFile(destinationFile)
+				assert.NoError(t, err)
+				assert.Equal(t, int64(sparseSize), int64(len(content)), Commentf(test.ErrResultFmt, testName))
+				assert.Equal(t, fmt.Sprintf("test-%v", i), string(content[:len("test-0")]), Commentf(test.ErrResultFmt, testName))
+
+				if testCase.checksum {
+					checksum := sha256.Sum256(content)
+					assert.Equal(t, hex.EncodeToString(checksum[:]), result.Checksums[destinationFile], Commentf(test.ErrResultFmt, testName))
+				} else {
+					assert.Nil(t, result.Checksums, Commentf(test.ErrResultFmt, testName))
+				}
+			}
+		})
+	}
+}
+
+func TestCopyFilesWithOptionsBandwidthLimit(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	sourcePath := filepath.Join(fakeDir, "source")
+	err := os.WriteFile(sourcePath, make([]byte, 3*copyChunkSize), 0644)
+	assert.NoError(t, err)
+
+	// The bucket starts full with one chunk, the two other chunks take at least 200ms.
+	options := &CopyOptions{
+		BandwidthLimit: 10 * copyChunkSize,
+	}
+	start := time.Now()
+	_, err = CopyFilesWithOptions(context.Background(), sourcePath, filepath.Join(fakeDir, "destination"), options)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestCopyDirectoryWithOptionsAttributes(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	type testCase struct {
+		options *CopyOptions
+
+		expectPreserved bool
+	}
+	testCases := map[string]testCase{
+		"Default options": {
+			options: &CopyOptions{},
+		},
+		"Preserve attributes": {
+			options: &CopyOptions{
+				PreserveMode:      true,
+				PreserveOwnership: os.Geteuid() == 0,
+				PreserveXattrs:    true,
+				CopySymlinks:      true,
+				PreserveHardlinks: true,
+			},
+			expectPreserved: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			sourceDir := fake.CreateTempDirectory(fakeDir, t)
+			filePath := filepath.Join(sourceDir, "file")
+			err := os.WriteFile(filePath, []byte("content"), 0644)
+			assert.NoError(t, err)
+			err = os.Chmod(filePath, 0750)
+			assert.NoError(t, err)
+			err = os.Link(filePath, filepath.Join(sourceDir, "hardlink"))
+			assert.NoError(t, err)
+			err = os.Symlink("file", filepath.Join(sourceDir, "symlink"))
+			assert.NoError(t, err)
+			if os.Geteuid() == 0 {
+				err = os.Chown(filePath, 1000, 1000)
+				assert.NoError(t, err)
+			}
+			isXattrSupported := unix.Setxattr(filePath, "user.test", []byte("value"), 0) == nil
+
+			destinationDir := filepath.Join(fakeDir, "destination-"+filepath.Base(sourceDir))
+			_, err = CopyDirectoryWithOptions(context.Background(), sourceDir, destinationDir, testCase.options)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+
+			destinationFilePath := filepath.Join(destinationDir, "file")
+			fileInfo, err := os.Stat(destinationFilePath)
+			assert.NoError(t, err)
+			hardlinkInfo, err := os.Stat(filepath.Join(destinationDir, "hardlink"))
+			assert.NoError(t, err)
+			symlinkInfo, err := os.Lstat(filepath.Join(destinationDir, "symlink"))
+			assert.NoError(t, err)
+			stat := fileInfo.Sys().(*syscall.Stat_t)
+
+			if !testCase.expectPreserved {
+				assert.False(t, os.SameFile(fileInfo, hardlinkInfo), Commentf(test.ErrResultFmt, testName))
+				assert.Zero(t, symlinkInfo.Mode()&os.ModeSymlink, Commentf(test.ErrResultFmt, testName))
+				return
+			}
+
+			assert.Equal(t, os.FileMode(0750), fileInfo.Mode().Perm(), Commentf(test.ErrResultFmt, testName))
+			assert.True(t, os.SameFile(fileInfo, hardlinkInfo), Commentf(test.ErrResultFmt, testName))
+			assert.NotZero(t, symlinkInfo.Mode()&os.ModeSymlink, Commentf(test.ErrResultFmt, testName))
+			target, err := os.Readlink(filepath.Join(destinationDir, "symlink"))
+			assert.NoError(t, err)
+			assert.Equal(t, "file", target, Commentf(test.ErrResultFmt, testName))
+			if os.Geteuid() == 0 {
+				assert.Equal(t, uint32(1000), stat.Uid, Commentf(test.ErrResultFmt, testName))
+				assert.Equal(t, uint32(1000), stat.Gid, Commentf(test.ErrResultFmt, testName))
+			}
+			if isXattrSupported {
+				value, err := getXattr(destinationFilePath, "user.test")
+				assert.NoError(t, err)
+				assert.Equal(t, "value", string(value), Commentf(test.ErrResultFmt, testName))
+			}
+		})
+	}
+}
+
+func TestCopyAttributesReadOnly(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	sourcePath := filepath.Join(fakeDir, "source")
+	err := os.WriteFile(sourcePath, []byte("content"), 0644)
+	assert.NoError(t, err)
+	if err := unix.Setxattr(sourcePath, "user.test", []byte("value"), 0); err != nil {
+		t.Skipf("Extended attributes are not supported: %v", err)
+	}
+	err = os.Chmod(sourcePath, 0444)
+	assert.NoError(t, err)
+
+	destinationPath := filepath.Join(fakeDir, "destination")
+	err = os.WriteFile(destinationPath, []byte("content"), 0644)
+	assert.NoError(t, err)
+
+	sourceInfo, err := os.Lstat(sourcePath)
+	assert.NoError(t, err)
+
+	// The extended attributes are set before the mode makes the file read-only.
+	c := &copier{options: CopyOptions{PreserveMode: true, PreserveXattrs: true}}
+	err = c.copyAttributes(sourcePath, destinationPath, sourceInfo)
+	assert.NoError(t, err)
+
+	destinationInfo, err := os.Lstat(destinationPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0444), destinationInfo.Mode().Perm())
+	value, err := getXattr(destinationPath, "user.test")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", string(value))
+}
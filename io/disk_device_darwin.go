@@ -0,0 +1,10 @@
+package io
+
+import (
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// setDiskStatBlockDevice leaves the block device fields empty on darwin.
+func setDiskStatBlockDevice(diskStat *types.DiskStat, path string) error {
+	return nil
+}
@@ -0,0 +1,112 @@
+package io
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/longhorn/go-common-libs/types"
+	"github.com/longhorn/go-common-libs/utils"
+)
+
+const sysfsDirectory = "/sys"
+
+// scsiDiskDriver is the kernel driver of the SCSI disks, whatever their host
+// adapter is.
+const scsiDiskDriver = "sd"
+
+// kernelDiskDrivers maps the kernel driver names to the disk drivers.
+// The other kernel driver names are used as is.
+var kernelDiskDrivers = map[string]types.DiskDriver{
+	"nvme":            types.DiskDriverNvme,
+	"virtio_blk":      types.DiskDriverVirtioBlk,
+	"virtio_scsi":     types.DiskDriverVirtioScsi,
+	"virtio-pci":      types.DiskDriverVirtioPci,
+	"uio_pci_generic": types.DiskDriverUioPciGeneric,
+	"vfio-pci":        types.DiskDriverVfioPci,
+}
+
+// setDiskStatBlockDevice fills in the block device fields of the disk stat
+// from the sysfs entry of the device the path is on.
+//
+// The device is found by the device number of the path instead of
+// sys.FindBlockDeviceForMount, as the path does not need to be a mount point
+// and the sys package depends on this package.
+func setDiskStatBlockDevice(diskStat *types.DiskStat, path string) error {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return errors.Wrapf(err, "failed to stat %v", path)
+	}
+	return setDiskStatBlockDeviceFromSysfs(diskStat, sysfsDirectory, unix.Major(uint64(stat.Dev)), unix.Minor(uint64(stat.Dev)))
+}
+
+func setDiskStatBlockDeviceFromSysfs(diskStat *types.DiskStat, sysfsDir string, major, minor uint32) error {
+	devicePath, err := filepath.EvalSymlinks(filepath.Join(sysfsDir, "dev", "block", fmt.Sprintf("%d:%d", major, minor)))
+	if err != nil {
+		return errors.Wrapf(err, "failed to find block device %d:%d", major, minor)
+	}
+	diskStat.Name = filepath.Base(devicePath)
+
+	// The disk properties are on the whole disk, which is the parent of a partition.
+	diskPath := devicePath
+	if _, err := os.Stat(filepath.Join(devicePath, "partition")); err == nil {
+		diskPath = filepath.Dir(devicePath)
+	}
+
+	diskStat.Driver = getBlockDeviceDriver(diskPath)
+	diskStat.Model = utils.ReadSysfsString(filepath.Join(diskPath, "device", "model"), os.ReadFile)
+	diskStat.Serial = utils.ReadSysfsString(filepath.Join(diskPath, "device", "serial"), os.ReadFile)
+	if diskStat.Serial == "" {
+		diskStat.Serial = utils.ReadSysfsString(filepath.Join(diskPath, "serial"), os.ReadFile)
+	}
+	diskStat.IsRotational = utils.ReadSysfsString(filepath.Join(diskPath, "queue", "rotational"), os.ReadFile) == "1"
+	diskStat.LogicalSectorSize = utils.ReadSysfsInt64(filepath.Join(diskPath, "queue", "logical_block_size"), os.ReadFile)
+	diskStat.PhysicalSectorSize = utils.ReadSysfsInt64(filepath.Join(diskPath, "queue", "physical_block_size"), os.ReadFile)
+	return nil
+}
+
+// getBlockDeviceDriver returns the driver of the disk. The device of an NVMe
+// namespace is the controller, so the driver is looked up on its parent too.
+// The driver of a SCSI disk is sd, so the driver of a virtio-scsi disk is
+// looked up on its host adapter.
+func getBlockDeviceDriver(diskPath string) types.DiskDriver {
+	for _, driverLink := range []string{
+		filepath.Join(diskPath, "device", "driver"),
+		filepath.Join(diskPath, "device", "device", "driver"),
+	} {
+		driverPath, err := os.Readlink(driverLink)
+		if err != nil {
+			continue
+		}
+		driverName := filepath.Base(driverPath)
+		if driverName == scsiDiskDriver && isVirtioScsiDevice(filepath.Dir(driverLink)) {
+			return types.DiskDriverVirtioScsi
+		}
+		if driver, ok := kernelDiskDrivers[driverName]; ok {
+			return driver
+		}
+		return types.DiskDriver(driverName)
+	}
+	return types.DiskDriverNone
+}
+
+// isVirtioScsiDevice checks if the SCSI device is attached to a virtio-scsi
+// host adapter, which is an ancestor of the device in sysfs, e.g.
+// /sys/devices/pci0000:00/0000:00:04.0/virtio1/host0/target0:0:0/0:0:0:0.
+func isVirtioScsiDevice(devicePath string) bool {
+	devicePath, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return false
+	}
+
+	for directory := filepath.Dir(devicePath); directory != filepath.Dir(directory); directory = filepath.Dir(directory) {
+		driverPath, err := os.Readlink(filepath.Join(directory, "driver"))
+		if err == nil && kernelDiskDrivers[filepath.Base(driverPath)] == types.DiskDriverVirtioScsi {
+			return true
+		}
+	}
+	return false
+}
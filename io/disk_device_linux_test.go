@@ -0,0 +1,165 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestSetDiskStatBlockDeviceFromSysfs(t *testing.T) {
+	sysfsDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(sysfsDir)
+	}()
+
+	createFakeSysfs := func(files map[string]string, links map[string]string) {
+		for path, content := range files {
+			path = filepath.Join(sysfsDir, path)
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			assert.NoError(t, err)
+			err = os.WriteFile(path, []byte(content), 0644)
+			assert.NoError(t, err)
+		}
+		for path, target := range links {
+			path = filepath.Join(sysfsDir, path)
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			assert.NoError(t, err)
+			err = os.Symlink(target, path)
+			assert.NoError(t, err)
+		}
+	}
+
+	// An NVMe namespace partition, a virtio disk, a virtio-scsi disk, a SATA
+	// disk and a device mapper device.
+	createFakeSysfs(map[string]string{
+		"devices/pci/nvme/nvme0/nvme0n1/nvme0n1p1/partition":                                "1\n",
+		"devices/pci/nvme/nvme0/nvme0n1/queue/rotational":                                   "0\n",
+		"devices/pci/nvme/nvme0/nvme0n1/queue/logical_block_size":                           "512\n",
+		"devices/pci/nvme/nvme0/nvme0n1/queue/physical_block_size":                          "4096\n",
+		"devices/pci/nvme/nvme0/model":                                                      "Fake NVMe   \n",
+		"devices/pci/nvme/nvme0/serial":                                                     "S123\n",
+		"devices/pci/virtio1/block/vda/serial":                                              "V456\n",
+		"devices/pci/virtio1/block/vda/queue/rotational":                                    "1\n",
+		"devices/pci/virtio1/block/vda/queue/logical_block_size":                            "512\n",
+		"devices/pci/virtio1/block/vda/queue/physical_block_size":                           "512\n",
+		"devices/pci/virtio2/host0/target0:0:0/0:0:0:0/model":                               "QEMU HARDDISK\n",
+		"devices/pci/virtio2/host0/target0:0:0/0:0:0:0/block/sda/queue/rotational":          "1\n",
+		"devices/pci/virtio2/host0/target0:0:0/0:0:0:0/block/sda/queue/logical_block_size":  "512\n",
+		"devices/pci/virtio2/host0/target0:0:0/0:0:0:0/block/sda/queue/physical_block_size": "512\n",
+		"devices/pci/ata1/host1/target1:0:0/1:0:0:0/model":                                  "Fake SATA\n",
+		"devices/pci/ata1/host1/target1:0:0/1:0:0:0/block/sdb/queue/rotational":             "1\n",
+		"devices/virtual/block/dm-0/queue/rotational":                                       "0\n",
+		"devices/virtual/block/dm-0/queue/logical_block_size":                               "4096\n",
+		"devices/virtual/block/dm-0/queue/physical_block_size":                              "4096\n",
+		"bus/pci/drivers/nvme/.keep":                                                        "",
+		"bus/virtio/drivers/virtio_blk/.keep":                                               "",
+		"bus/virtio/drivers/virtio_scsi/.keep":                                              "",
+		"bus/scsi/drivers/sd/.keep":                                                         "",
+	}, map[string]string{
+		"devices/pci/nvme/nvme0/nvme0n1/device":                          "../../nvme0",
+		"devices/pci/nvme/nvme0/device":                                  "../..",
+		"devices/pci/driver":                                             "../../bus/pci/drivers/nvme",
+		"devices/pci/virtio1/block/vda/device":                           "../..",
+		"devices/pci/virtio1/driver":                                     "../../../bus/virtio/drivers/virtio_blk",
+		"dev/block/259:1":                                                "../../devices/pci/nvme/nvme0/nvme0n1/nvme0n1p1",
+		"dev/block/254:0":                                                "../../devices/pci/virtio1/block/vda",
+		"dev/block/252:0":                                                "../../devices/virtual/block/dm-0",
+		"devices/pci/virtio2/driver":                                     "../../../bus/virtio/drivers/virtio_scsi",
+		"devices/pci/virtio2/host0/target0:0:0/0:0:0:0/driver":           "../../../../../../bus/scsi/drivers/sd",
+		"devices/pci/virtio2/host0/target0:0:0/0:0:0:0/block/sda/device": "../..",
+		"devices/pci/ata1/host1/target1:0:0/1:0:0:0/driver":              "../../../../../../bus/scsi/drivers/sd",
+		"devices/pci/ata1/host1/target1:0:0/1:0:0:0/block/sdb/device":    "../..",
+		"dev/block/8:0":                                                  "../../devices/pci/virtio2/host0/target0:0:0/0:0:0:0/block/sda",
+		"dev/block/8:16":                                                 "../../devices/pci/ata1/host1/target1:0:0/1:0:0:0/block/sdb",
+	})
+
+	type testCase struct {
+		major uint32
+		minor uint32
+
+		expectedDiskStat types.DiskStat
+		expectError      bool
+	}
+	testCases := map[string]testCase{
+		"NVMe partition": {
+			major: 259,
+			minor: 1,
+			expectedDiskStat: types.DiskStat{
+				Name:               "nvme0n1p1",
+				Driver:             types.DiskDriverNvme,
+				Model:              "Fake NVMe",
+				Serial:             "S123",
+				LogicalSectorSize:  512,
+				PhysicalSectorSize: 4096,
+			},
+		},
+		"Virtio disk": {
+			major: 254,
+			minor: 0,
+			expectedDiskStat: types.DiskStat{
+				Name:               "vda",
+				Driver:             types.DiskDriverVirtioBlk,
+				Serial:             "V456",
+				IsRotational:       true,
+				LogicalSectorSize:  512,
+				PhysicalSectorSize: 512,
+			},
+		},
+		"Device mapper": {
+			major: 252,
+			minor: 0,
+			expectedDiskStat: types.DiskStat{
+				Name:               "dm-0",
+				Driver:             types.DiskDriverNone,
+				LogicalSectorSize:  4096,
+				PhysicalSectorSize: 4096,
+			},
+		},
+		"Virtio SCSI disk": {
+			major: 8,
+			minor: 0,
+			expectedDiskStat: types.DiskStat{
+				Name:               "sda",
+				Driver:             types.DiskDriverVirtioScsi,
+				Model:              "QEMU HARDDISK",
+				IsRotational:       true,
+				LogicalSectorSize:  512,
+				PhysicalSectorSize: 512,
+			},
+		},
+		"SATA disk": {
+			major: 8,
+			minor: 16,
+			expectedDiskStat: types.DiskStat{
+				Name:         "sdb",
+				Driver:       types.DiskDriver("sd"),
+				Model:        "Fake SATA",
+				IsRotational: true,
+			},
+		},
+		"Unknown device": {
+			major:       8,
+			minor:       32,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			diskStat := types.DiskStat{}
+			err := setDiskStatBlockDeviceFromSysfs(&diskStat, sysfsDir, testCase.major, testCase.minor)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expectedDiskStat, diskStat, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package io
+
+import (
+	"container/heap"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// DiskUsageOptions defines optional parameters used when computing the disk usage.
+type DiskUsageOptions struct {
+	// MaxDepth, if greater than 0, limits the walk to the specified depth like
+	// FindFiles. The deeper entries are not counted.
+	MaxDepth int
+	// LargestFileCount is the number of largest files to report.
+	LargestFileCount int
+	// Parallelism is the number of directories read concurrently. Zero or one
+	// reads them sequentially.
+	Parallelism int
+}
+
+// GetDiskUsage walks the directory and returns the space used by its tree.
+// Symbolic links are not followed, and the files with several hard links in
+// the tree are counted once. The walk stops with the context error when ctx
+// is done.
+func GetDiskUsage(ctx context.Context, directory string, options *DiskUsageOptions) (usage *types.DiskUsage, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get disk usage of %v", directory)
+	}()
+
+	rootInfo, err := os.Lstat(directory)
+	if err != nil {
+		return nil, err
+	}
+	if !rootInfo.IsDir() {
+		return nil, errors.Errorf("%v is not a directory", directory)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	walker := &diskUsageWalker{
+		ctx:       ctx,
+		cancel:    cancel,
+		usage:     &types.DiskUsage{DirectoryCount: 1},
+		hardlinks: map[fileInode]bool{},
+	}
+	if options != nil {
+		walker.options = *options
+	}
+	if walker.options.Parallelism > 1 {
+		walker.semaphore = make(chan struct{}, walker.options.Parallelism-1)
+	}
+
+	walker.walk(directory, 0)
+	walker.wg.Wait()
+	if walker.err != nil {
+		return nil, walker.err
+	}
+
+	walker.usage.LargestFiles = make([]types.FileUsage, len(walker.largestFiles))
+	copy(walker.usage.LargestFiles, walker.largestFiles)
+	sort.Slice(walker.usage.LargestFiles, func(i, j int) bool {
+		return isFileUsageLarger(walker.usage.LargestFiles[i], walker.usage.LargestFiles[j])
+	})
+	return walker.usage, nil
+}
+
+// diskUsageWalker holds the state of a disk usage walk. The directories are
+// read in new goroutines while the semaphore allows it, and in the current
+// goroutine otherwise.
+type diskUsageWalker struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	options   DiskUsageOptions
+	semaphore chan struct{}
+	wg        sync.WaitGroup
+
+	mutex        sync.Mutex
+	usage        *types.DiskUsage
+	largestFiles fileUsageHeap
+	hardlinks    map[fileInode]bool
+	err          error
+}
+
+func (w *diskUsageWalker) walk(directory string, depth int) {
+	if err := w.ctx.Err(); err != nil {
+		w.fail(err)
+		return
+	}
+
+	// The entries are one level deeper than the directory, so the directories
+	// at the maximum depth are not read at all.
+	depth++
+	if w.options.MaxDepth > 0 && depth > w.options.MaxDepth {
+		return
+	}
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		w.fail(errors.Wrapf(err, "failed to read directory %v", directory))
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(directory, entry.Name())
+		fileInfo, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The entry was removed while the directory is being walked.
+				continue
+			}
+			w.fail(err)
+			return
+		}
+
+		if !fileInfo.IsDir() {
+			w.addFile(path, fileInfo)
+			continue
+		}
+
+		w.mutex.Lock()
+		w.usage.DirectoryCount++
+		w.mutex.Unlock()
+
+		select {
+		case w.semaphore <- struct{}{}:
+			w.wg.Add(1)
+			go func(path string) {
+				defer w.wg.Done()
+				defer func() { <-w.semaphore }()
+				w.walk(path, depth)
+			}(path)
+		default:
+			w.walk(path, depth)
+		}
+	}
+}
+
+func (w *diskUsageWalker) addFile(path string, fileInfo os.FileInfo) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if inode, ok := getFileInode(fileInfo); ok {
+		if w.hardlinks[inode] {
+			return
+		}
+		w.hardlinks[inode] = true
+	}
+
+	fileUsage := types.FileUsage{
+		Path:          path,
+		ApparentSize:  fileInfo.Size(),
+		AllocatedSize: getAllocatedSize(fileInfo),
+	}
+	w.usage.FileCount++
+	w.usage.ApparentSize += fileUsage.ApparentSize
+	w.usage.AllocatedSize += fileUsage.AllocatedSize
+
+	if w.options.LargestFileCount <= 0 {
+		return
+	}
+	if len(w.largestFiles) < w.options.LargestFileCount {
+		heap.Push(&w.largestFiles, fileUsage)
+	} else if isFileUsageLarger(fileUsage, w.largestFiles[0]) {
+		w.largestFiles[0] = fileUsage
+		heap.Fix(&w.largestFiles, 0)
+	}
+}
+
+// fail records the first error and stops the walk.
+func (w *diskUsageWalker) fail(err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err == nil {
+		w.err = err
+		w.cancel()
+	}
+}
+
+// getAllocatedSize returns the bytes allocated on disk for the file.
+func getAllocatedSize(fileInfo os.FileInfo) int64 {
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileInfo.Size()
+	}
+	return int64(stat.Blocks) * 512
+}
+
+// isFileUsageLarger orders the files by allocated size, then by apparent size.
+func isFileUsageLarger(a, b types.FileUsage) bool {
+	if a.AllocatedSize != b.AllocatedSize {
+		return a.AllocatedSize > b.AllocatedSize
+	}
+	if a.ApparentSize != b.ApparentSize {
+		return a.ApparentSize > b.ApparentSize
+	}
+	return a.Path < b.Path
+}
+
+// fileUsageHeap is a min-heap of the largest files, the smallest one is on top.
+type fileUsageHeap []types.FileUsage
+
+func (h fileUsageHeap) Len() int           { return len(h) }
+func (h fileUsageHeap) Less(i, j int) bool { return isFileUsageLarger(h[j], h[i]) }
+func (h fileUsageHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *fileUsageHeap) Push(x any) {
+	*h = append(*h, x.(types.FileUsage))
+}
+
+func (h *fileUsageHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,117 @@
+package io
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+)
+
+func TestGetDiskUsage(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	// root/a (100 bytes), root/b -> hard link of root/a,
+	// root/sub/c (300 bytes), root/sub/deep/d (200 bytes)
+	rootDir := filepath.Join(fakeDir, "root")
+	err := os.MkdirAll(filepath.Join(rootDir, "sub", "deep"), 0755)
+	assert.NoError(t, err)
+	for path, size := range map[string]int{
+		"a":          100,
+		"sub/c":      300,
+		"sub/deep/d": 200,
+	} {
+		err := os.WriteFile(filepath.Join(rootDir, path), []byte(strings.Repeat("x", size)), 0644)
+		assert.NoError(t, err)
+	}
+	err = os.Link(filepath.Join(rootDir, "a"), filepath.Join(rootDir, "b"))
+	assert.NoError(t, err)
+
+	type testCase struct {
+		options *DiskUsageOptions
+		cancel  bool
+
+		expectedFileCount      int64
+		expectedDirectoryCount int64
+		expectedFileSize       int64
+		expectedLargestFiles   []string
+		expectError            bool
+	}
+	testCases := map[string]testCase{
+		"Whole tree": {
+			expectedFileCount:      3,
+			expectedDirectoryCount: 3,
+			expectedFileSize:       600,
+		},
+		"Largest files": {
+			options: &DiskUsageOptions{
+				LargestFileCount: 2,
+			},
+			expectedFileCount:      3,
+			expectedDirectoryCount: 3,
+			expectedFileSize:       600,
+			expectedLargestFiles:   []string{"sub/c", "sub/deep/d"},
+		},
+		"Max depth": {
+			options: &DiskUsageOptions{
+				MaxDepth: 2,
+			},
+			expectedFileCount:      2,
+			expectedDirectoryCount: 3,
+			expectedFileSize:       400,
+		},
+		"Parallel": {
+			options: &DiskUsageOptions{
+				Parallelism:      4,
+				LargestFileCount: 1,
+			},
+			expectedFileCount:      3,
+			expectedDirectoryCount: 3,
+			expectedFileSize:       600,
+			expectedLargestFiles:   []string{"sub/c"},
+		},
+		"Canceled context": {
+			cancel:      true,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if testCase.cancel {
+				cancel()
+			}
+
+			usage, err := GetDiskUsage(ctx, rootDir, testCase.options)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName))
+
+			assert.Equal(t, testCase.expectedFileCount, usage.FileCount, Commentf(test.ErrResultFmt, testName))
+			assert.Equal(t, testCase.expectedDirectoryCount, usage.DirectoryCount, Commentf(test.ErrResultFmt, testName))
+
+			assert.Equal(t, testCase.expectedFileSize, usage.ApparentSize, Commentf(test.ErrResultFmt, testName))
+			assert.Greater(t, usage.AllocatedSize, int64(0), Commentf(test.ErrResultFmt, testName))
+
+			var largestFiles []string
+			for _, file := range usage.LargestFiles {
+				relPath, err := filepath.Rel(rootDir, file.Path)
+				assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName))
+				largestFiles = append(largestFiles, relPath)
+			}
+			assert.Equal(t, testCase.expectedLargestFiles, largestFiles, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package io
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetFileExtents returns the data and hole ranges of the file, found with
+// SEEK_DATA/SEEK_HOLE. The ranges are ordered, contiguous and cover the whole
+// file. If the filesystem does not report holes, the whole file is returned
+// as a single data range.
+func GetFileExtents(path string) (extents []types.FileExtent, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get extents of %v", path)
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", path)
+		}
+	}()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, errors.Errorf("file %v is a directory", path)
+	}
+	size := fileInfo.Size()
+
+	fd := int(file.Fd())
+	var offset int64
+	for offset < size {
+		dataStart, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if err != nil {
+			if errors.Is(err, unix.ENXIO) {
+				break
+			}
+			if isSeekHoleUnsupported(err) {
+				logrus.WithError(err).Tracef("Filesystem of %v does not report holes", path)
+				return []types.FileExtent{{Offset: 0, Length: size, Type: types.FileExtentTypeData}}, nil
+			}
+			return nil, err
+		}
+
+		dataEnd, err := unix.Seek(fd, dataStart, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, err
+		}
+		dataEnd = min(dataEnd, size)
+
+		if dataStart > offset {
+			extents = append(extents, types.FileExtent{Offset: offset, Length: dataStart - offset, Type: types.FileExtentTypeHole})
+		}
+		extents = append(extents, types.FileExtent{Offset: dataStart, Length: dataEnd - dataStart, Type: types.FileExtentTypeData})
+		offset = dataEnd
+	}
+	if offset < size {
+		extents = append(extents, types.FileExtent{Offset: offset, Length: size - offset, Type: types.FileExtentTypeHole})
+	}
+
+	return extents, nil
+}
+
+// GetExtentsDataSize returns the total length of the data ranges in extents.
+func GetExtentsDataSize(extents []types.FileExtent) int64 {
+	var size int64
+	for _, extent := range extents {
+		if extent.Type == types.FileExtentTypeData {
+			size += extent.Length
+		}
+	}
+	return size
+}
+
+// GetExtentsSize returns the total length of extents, which is the file size
+// when extents cover the whole file.
+func GetExtentsSize(extents []types.FileExtent) int64 {
+	var size int64
+	for _, extent := range extents {
+		size += extent.Length
+	}
+	return size
+}
+
+// GetFileAllocatedSize returns the number of bytes allocated on disk for the
+// file, as reported by stat in 512-byte blocks.
+func GetFileAllocatedSize(path string) (int64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "failed to stat %v", path)
+	}
+	return int64(stat.Blocks) * 512, nil
+}
+
+// CompareFileExtents compares the data and hole ranges of two files. It returns
+// the ranges where the files differ, typed as they are in the first file. An
+// empty result means that both files have the same layout. The ranges beyond
+// the end of the shorter file are reported as differences as well.
+func CompareFileExtents(path1, path2 string) ([]types.FileExtent, error) {
+	extents1, err := GetFileExtents(path1)
+	if err != nil {
+		return nil, err
+	}
+	extents2, err := GetFileExtents(path2)
+	if err != nil {
+		return nil, err
+	}
+	return diffFileExtents(extents1, extents2), nil
+}
+
+// isSeekHoleUnsupported checks if the error means that the filesystem does
+// not support SEEK_DATA/SEEK_HOLE.
+func isSeekHoleUnsupported(err error) bool {
+	return errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EOPNOTSUPP)
+}
+
+// diffFileExtents walks both sorted and contiguous extent lists at once and
+// returns the merged ranges where their types differ.
+func diffFileExtents(extents1, extents2 []types.FileExtent) []types.FileExtent {
+	typeAt := func(extents []types.FileExtent, index int) types.FileExtentType {
+		if index < len(extents) {
+			return extents[index].Type
+		}
+		return ""
+	}
+	endOf := func(extents []types.FileExtent, index int) int64 {
+		if index < len(extents) {
+			return extents[index].Offset + extents[index].Length
+		}
+		return -1
+	}
+
+	var diffs []types.FileExtent
+	var offset int64
+	i, j := 0, 0
+	for i < len(extents1) || j < len(extents2) {
+		end1, end2 := endOf(extents1, i), endOf(extents2, j)
+		end := end1
+		if end < 0 || (end2 >= 0 && end2 < end) {
+			end = end2
+		}
+
+		type1, type2 := typeAt(extents1, i), typeAt(extents2, j)
+		if type1 != type2 {
+			diffType := type1
+			if diffType == "" {
+				diffType = types.FileExtentTypeHole
+			}
+			last := len(diffs) - 1
+			if last >= 0 && diffs[last].Type == diffType && diffs[last].Offset+diffs[last].Length == offset {
+				diffs[last].Length += end - offset
+			} else {
+				diffs = append(diffs, types.FileExtent{Offset: offset, Length: end - offset, Type: diffType})
+			}
+		}
+
+		if end == end1 {
+			i++
+		}
+		if end == end2 {
+			j++
+		}
+		offset = end
+	}
+	return diffs
+}
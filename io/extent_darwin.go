@@ -0,0 +1,12 @@
+package io
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetFileExtentsWithFiemap is not supported on darwin, use GetFileExtents instead.
+func GetFileExtentsWithFiemap(path string) ([]types.FileExtent, error) {
+	return nil, errors.Errorf("failed to get FIEMAP extents of %v: FIEMAP is not supported", path)
+}
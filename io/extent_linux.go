@@ -0,0 +1,130 @@
+package io
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// FIEMAP ioctl and flags from linux/fiemap.h, which are not provided by x/sys/unix.
+const (
+	fsIocFiemap = 0xC020660B
+
+	fiemapFlagSync = 0x00000001
+
+	fiemapExtentLast      = 0x00000001
+	fiemapExtentUnwritten = 0x00000800
+	fiemapExtentShared    = 0x00002000
+
+	// fiemapExtentBatchSize is the number of extents requested by one FIEMAP call.
+	fiemapExtentBatchSize = 256
+)
+
+type fiemapExtent struct {
+	Logical    uint64
+	Physical   uint64
+	Length     uint64
+	reserved64 [2]uint64
+	Flags      uint32
+	reserved   [3]uint32
+}
+
+type fiemap struct {
+	Start         uint64
+	Length        uint64
+	Flags         uint32
+	MappedExtents uint32
+	ExtentCount   uint32
+	reserved      uint32
+	Extents       [fiemapExtentBatchSize]fiemapExtent
+}
+
+// GetFileExtentsWithFiemap returns the data and hole ranges of the file, found
+// with the FIEMAP ioctl. Unlike GetFileExtents, the data ranges also carry the
+// physical offset and whether they are unwritten (preallocated) or shared with
+// other files (reflinked). Adjacent extents are not merged. The file is synced
+// before it is mapped so that delayed allocations are reported.
+func GetFileExtentsWithFiemap(path string) (extents []types.FileExtent, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get FIEMAP extents of %v", path)
+	}()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", path)
+		}
+	}()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fileInfo.IsDir() {
+		return nil, errors.Errorf("file %v is a directory", path)
+	}
+	size := fileInfo.Size()
+
+	var offset int64
+	appendHole := func(end int64) {
+		if end > offset {
+			extents = append(extents, types.FileExtent{Offset: offset, Length: end - offset, Type: types.FileExtentTypeHole})
+			offset = end
+		}
+	}
+
+	request := &fiemap{}
+	isLast := false
+	for !isLast && offset < size {
+		*request = fiemap{
+			Start:       uint64(offset),
+			Length:      uint64(size - offset),
+			Flags:       fiemapFlagSync,
+			ExtentCount: fiemapExtentBatchSize,
+		}
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), fsIocFiemap, uintptr(unsafe.Pointer(request)))
+		if errno != 0 {
+			return nil, errno
+		}
+		if request.MappedExtents == 0 {
+			break
+		}
+
+		previousOffset := offset
+		for _, mapped := range request.Extents[:request.MappedExtents] {
+			start := int64(mapped.Logical)
+			end := min(start+int64(mapped.Length), size)
+			isLast = mapped.Flags&fiemapExtentLast != 0
+			if end <= offset {
+				continue
+			}
+			// The first extent may start before the requested offset.
+			start = max(start, offset)
+
+			appendHole(start)
+			extents = append(extents, types.FileExtent{
+				Offset:         start,
+				Length:         end - start,
+				Type:           types.FileExtentTypeData,
+				PhysicalOffset: int64(mapped.Physical) + start - int64(mapped.Logical),
+				IsUnwritten:    mapped.Flags&fiemapExtentUnwritten != 0,
+				IsShared:       mapped.Flags&fiemapExtentShared != 0,
+			})
+			offset = end
+		}
+		if offset == previousOffset {
+			break
+		}
+	}
+	appendHole(size)
+
+	return extents, nil
+}
@@ -0,0 +1,130 @@
+package io
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestGetFileExtents(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	type testCase struct {
+		sparseSize  int64
+		isDirectory bool
+
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Regular file": {},
+		"Sparse file": {
+			sparseSize: 8 * 1024 * 1024,
+		},
+		"Directory": {
+			isDirectory: true,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			testDir := fake.CreateTempDirectory(fakeDir, t)
+
+			filePath := testDir
+			if !testCase.isDirectory {
+				fileName := fmt.Sprintf("test-%v", time.Now().UnixNano())
+				var file *os.File
+				if testCase.sparseSize != 0 {
+					file = fake.CreateTempSparseFile(testDir, fileName, testCase.sparseSize, "content", t)
+				} else {
+					file = fake.CreateTempFile(testDir, fileName, "content", t)
+				}
+				filePath = file.Name()
+				_ = file.Close()
+			}
+
+			for _, getExtents := range []func(string) ([]types.FileExtent, error){GetFileExtents, GetFileExtentsWithFiemap} {
+				extents, err := getExtents(filePath)
+				if testCase.expectError {
+					assert.Error(t, err)
+					continue
+				}
+				assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+
+				fileInfo, err := os.Stat(filePath)
+				assert.NoError(t, err)
+
+				// The extents must cover the whole file without gaps.
+				var offset int64
+				for _, extent := range extents {
+					assert.Equal(t, offset, extent.Offset, Commentf(test.ErrResultFmt, testName))
+					offset += extent.Length
+				}
+				assert.Equal(t, fileInfo.Size(), offset, Commentf(test.ErrResultFmt, testName))
+
+				if assert.NotEmpty(t, extents) {
+					assert.Equal(t, types.FileExtentTypeData, extents[0].Type, Commentf(test.ErrResultFmt, testName))
+				}
+				if testCase.sparseSize != 0 && len(extents) > 1 {
+					assert.Equal(t, types.FileExtentTypeHole, extents[len(extents)-1].Type, Commentf(test.ErrResultFmt, testName))
+					assert.Less(t, GetExtentsDataSize(extents), testCase.sparseSize, Commentf(test.ErrResultFmt, testName))
+				}
+			}
+		})
+	}
+}
+
+func TestDiffFileExtents(t *testing.T) {
+	data := func(offset, length int64) types.FileExtent {
+		return types.FileExtent{Offset: offset, Length: length, Type: types.FileExtentTypeData}
+	}
+	hole := func(offset, length int64) types.FileExtent {
+		return types.FileExtent{Offset: offset, Length: length, Type: types.FileExtentTypeHole}
+	}
+
+	type testCase struct {
+		extents1 []types.FileExtent
+		extents2 []types.FileExtent
+
+		expectedDiffs []types.FileExtent
+	}
+	testCases := map[string]testCase{
+		"Same layout": {
+			extents1: []types.FileExtent{data(0, 4096), hole(4096, 4096)},
+			extents2: []types.FileExtent{data(0, 4096), hole(4096, 4096)},
+		},
+		"Same layout split differently": {
+			extents1: []types.FileExtent{data(0, 8192)},
+			extents2: []types.FileExtent{data(0, 4096), data(4096, 4096)},
+		},
+		"Data filled hole": {
+			extents1: []types.FileExtent{data(0, 4096), hole(4096, 8192)},
+			extents2: []types.FileExtent{data(0, 8192), hole(8192, 4096)},
+
+			expectedDiffs: []types.FileExtent{hole(4096, 4096)},
+		},
+		"Different sizes": {
+			extents1: []types.FileExtent{data(0, 4096)},
+			extents2: []types.FileExtent{data(0, 4096), hole(4096, 4096), data(8192, 4096)},
+
+			expectedDiffs: []types.FileExtent{hole(4096, 8192)},
+		},
+		"Empty files": {},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			diffs := diffFileExtents(testCase.extents1, testCase.extents2)
+			assert.Equal(t, testCase.expectedDiffs, diffs, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package io
+
+import (
+	"fmt"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// PunchHole is not supported on darwin.
+func PunchHole(path string, offset, length int64) error {
+	return fmt.Errorf("fallocate mode %v for %v %w", types.FallocateModePunchHole, path, types.ErrNotSupported)
+}
+
+// ZeroRange is not supported on darwin.
+func ZeroRange(path string, offset, length int64) error {
+	return fmt.Errorf("fallocate mode %v for %v %w", types.FallocateModeZeroRange, path, types.ErrNotSupported)
+}
+
+// Preallocate is not supported on darwin.
+func Preallocate(path string, offset, length int64, keepSize bool) error {
+	mode := types.FallocateModePreallocate
+	if keepSize {
+		mode = types.FallocateModePreallocateKeepSize
+	}
+	return fmt.Errorf("fallocate mode %v for %v %w", mode, path, types.ErrNotSupported)
+}
+
+// IsFallocateModeSupported always returns false on darwin.
+func IsFallocateModeSupported(directory string, mode types.FallocateMode) (bool, error) {
+	return false, nil
+}
+
+// GetFallocateSupportedModes always returns no modes on darwin.
+func GetFallocateSupportedModes(directory string) ([]types.FallocateMode, error) {
+	return nil, nil
+}
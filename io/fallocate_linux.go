@@ -0,0 +1,125 @@
+package io
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// PunchHole deallocates length bytes of the file at path starting at offset.
+// The range reads as zeros afterwards and the file size does not change.
+// The returned error wraps types.ErrNotSupported if the filesystem cannot punch holes.
+func PunchHole(path string, offset, length int64) error {
+	return fallocate(path, types.FallocateModePunchHole, offset, length)
+}
+
+// ZeroRange zeroes length bytes of the file at path starting at offset. Unlike
+// PunchHole, the range stays allocated. The file size does not change.
+// The returned error wraps types.ErrNotSupported if the filesystem cannot zero ranges.
+func ZeroRange(path string, offset, length int64) error {
+	return fallocate(path, types.FallocateModeZeroRange, offset, length)
+}
+
+// Preallocate allocates length bytes of the file at path starting at offset,
+// so that later writes to the range do not fail with ENOSPC. If keepSize is
+// false, the file is extended when the range ends beyond the file size.
+// The returned error wraps types.ErrNotSupported if the filesystem cannot preallocate.
+func Preallocate(path string, offset, length int64, keepSize bool) error {
+	mode := types.FallocateModePreallocate
+	if keepSize {
+		mode = types.FallocateModePreallocateKeepSize
+	}
+	return fallocate(path, mode, offset, length)
+}
+
+// IsFallocateModeSupported checks if the filesystem of the directory supports
+// the fallocate mode, by trying it on a temporary file created in the directory.
+func IsFallocateModeSupported(directory string, mode types.FallocateMode) (bool, error) {
+	file, err := os.CreateTemp(directory, ".fallocate-")
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to create temporary file in %v", directory)
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", file.Name())
+		}
+		if errRemove := os.Remove(file.Name()); errRemove != nil {
+			logrus.WithError(errRemove).Errorf("Failed to remove file %v", file.Name())
+		}
+	}()
+
+	// Holes can only be punched and zeroed in blocks that exist, so the probe
+	// file gets some data first.
+	if _, err := file.Write(make([]byte, 2*4096)); err != nil {
+		return false, errors.Wrapf(err, "failed to write temporary file %v", file.Name())
+	}
+
+	err = fallocateFile(file, mode, 0, 4096)
+	if errors.Is(err, types.ErrNotSupported) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetFallocateSupportedModes returns the fallocate modes supported by the
+// filesystem of the directory.
+func GetFallocateSupportedModes(directory string) ([]types.FallocateMode, error) {
+	var supportedModes []types.FallocateMode
+	for _, mode := range types.FallocateModes {
+		isSupported, err := IsFallocateModeSupported(directory, mode)
+		if err != nil {
+			return nil, err
+		}
+		if isSupported {
+			supportedModes = append(supportedModes, mode)
+		}
+	}
+	return supportedModes, nil
+}
+
+func fallocate(path string, mode types.FallocateMode, offset, length int64) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %v", path)
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", path)
+		}
+	}()
+
+	return fallocateFile(file, mode, offset, length)
+}
+
+func fallocateFile(file *os.File, mode types.FallocateMode, offset, length int64) error {
+	var flags uint32
+	switch mode {
+	case types.FallocateModePreallocate:
+	case types.FallocateModePreallocateKeepSize:
+		flags = unix.FALLOC_FL_KEEP_SIZE
+	case types.FallocateModePunchHole:
+		flags = unix.FALLOC_FL_PUNCH_HOLE | unix.FALLOC_FL_KEEP_SIZE
+	case types.FallocateModeZeroRange:
+		flags = unix.FALLOC_FL_ZERO_RANGE | unix.FALLOC_FL_KEEP_SIZE
+	default:
+		return errors.Errorf("unknown fallocate mode %q", mode)
+	}
+
+	if offset < 0 || length <= 0 {
+		return errors.Errorf("invalid fallocate range offset %v length %v", offset, length)
+	}
+
+	err := unix.Fallocate(int(file.Fd()), flags, offset, length)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return fmt.Errorf("fallocate mode %v for %v %w: %w", mode, file.Name(), types.ErrNotSupported, err)
+	}
+	return errors.Wrapf(err, "failed to fallocate %v with mode %v at offset %v length %v", file.Name(), mode, offset, length)
+}
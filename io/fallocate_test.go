@@ -0,0 +1,108 @@
+package io
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestFallocate(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	const blockSize = 4096
+
+	type testCase struct {
+		mode   types.FallocateMode
+		offset int64
+		length int64
+
+		expectedSize int64
+		expectError  bool
+	}
+	testCases := map[string]testCase{
+		"Preallocate beyond file size": {
+			mode:         types.FallocateModePreallocate,
+			offset:       2 * blockSize,
+			length:       2 * blockSize,
+			expectedSize: 4 * blockSize,
+		},
+		"Preallocate beyond file size keeping size": {
+			mode:         types.FallocateModePreallocateKeepSize,
+			offset:       2 * blockSize,
+			length:       2 * blockSize,
+			expectedSize: 2 * blockSize,
+		},
+		"Punch hole": {
+			mode:         types.FallocateModePunchHole,
+			offset:       0,
+			length:       blockSize,
+			expectedSize: 2 * blockSize,
+		},
+		"Zero range": {
+			mode:         types.FallocateModeZeroRange,
+			offset:       0,
+			length:       blockSize,
+			expectedSize: 2 * blockSize,
+		},
+		"Invalid length": {
+			mode:        types.FallocateModePreallocate,
+			offset:      0,
+			length:      0,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			testDir := fake.CreateTempDirectory(fakeDir, t)
+
+			isSupported, err := IsFallocateModeSupported(testDir, testCase.mode)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+
+			filePath := filepath.Join(testDir, "file")
+			err = os.WriteFile(filePath, bytes.Repeat([]byte("a"), 2*blockSize), 0644)
+			assert.NoError(t, err)
+
+			switch testCase.mode {
+			case types.FallocateModePunchHole:
+				err = PunchHole(filePath, testCase.offset, testCase.length)
+			case types.FallocateModeZeroRange:
+				err = ZeroRange(filePath, testCase.offset, testCase.length)
+			default:
+				err = Preallocate(filePath, testCase.offset, testCase.length, testCase.mode == types.FallocateModePreallocateKeepSize)
+			}
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			if !isSupported {
+				assert.True(t, errors.Is(err, types.ErrNotSupported), Commentf(test.ErrErrorFmt, testName, err))
+				t.Skipf("Fallocate mode %v is not supported in %v", testCase.mode, testDir)
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+
+			fileInfo, err := os.Stat(filePath)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedSize, fileInfo.Size(), Commentf(test.ErrResultFmt, testName))
+
+			content, err := os.ReadFile(filePath)
+			assert.NoError(t, err)
+			expectedContent := bytes.Repeat([]byte("a"), 2*blockSize)
+			if testCase.mode == types.FallocateModePunchHole || testCase.mode == types.FallocateModeZeroRange {
+				copy(expectedContent[testCase.offset:], make([]byte, testCase.length))
+			}
+			assert.Equal(t, expectedContent, content[:2*blockSize], Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
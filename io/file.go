@@ -1,6 +1,7 @@
 package io
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -40,117 +41,24 @@ func CreateDirectory(path string, modTime time.Time) (string, error) {
 	return filepath.Abs(path)
 }
 
-// CopyDirectory copies the directory from source to destination.
+// CopyDirectory copies the directory from source to destination. The holes of
+// sparse files are preserved.
 func CopyDirectory(sourcePath, destinationPath string, doOverWrite bool) error {
-	var err error
-	defer func() {
-		err = errors.Wrapf(err, "failed to copy directory %v to %v", sourcePath, destinationPath)
-	}()
-
-	sourcePathInfo, err := os.Stat(sourcePath)
-	if err != nil {
-		return err
-	}
-
-	destinationAbsPath, err := CreateDirectory(destinationPath, sourcePathInfo.ModTime())
-	if err != nil {
-		return err
-	}
-
-	return CopyFiles(sourcePath, destinationAbsPath, doOverWrite)
+	_, err := CopyDirectoryWithOptions(context.Background(), sourcePath, destinationPath, &CopyOptions{OverWrite: doOverWrite})
+	return err
 }
 
 // CopyFiles copies the files from source to destination.
 func CopyFiles(sourcePath, destinationPath string, doOverWrite bool) error {
-	srcFileInfo, err := os.Stat(sourcePath)
-	if err != nil {
-		return err
-	}
-
-	if !srcFileInfo.IsDir() {
-		return CopyFile(sourcePath, destinationPath, doOverWrite)
-	}
-
-	srcFileInfos, err := os.ReadDir(sourcePath)
-	if err != nil {
-		return errors.Wrapf(err, "failed to read source directory %v", sourcePath)
-	}
-
-	for _, srcFileInfo := range srcFileInfos {
-		srcFileInfo, err := srcFileInfo.Info()
-		if err != nil {
-			return err
-		}
-
-		dstFilePath := filepath.Join(destinationPath, srcFileInfo.Name())
-		srcFilePath := filepath.Join(sourcePath, srcFileInfo.Name())
-
-		if srcFileInfo.IsDir() {
-			if err := CopyDirectory(srcFilePath, dstFilePath, doOverWrite); err != nil {
-				return err
-			}
-		} else {
-			if err := CopyFile(srcFilePath, dstFilePath, doOverWrite); err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	_, err := CopyFilesWithOptions(context.Background(), sourcePath, destinationPath, &CopyOptions{OverWrite: doOverWrite})
+	return err
 }
 
-// CopyFile copies the file from source to destination.
+// CopyFile copies the file from source to destination. The holes of a sparse
+// source file are preserved, and the content is reflinked or copied in the
+// kernel when the filesystems support it.
 func CopyFile(sourcePath, destinationPath string, overWrite bool) error {
-	var err error
-	defer func() {
-		err = errors.Wrapf(err, "failed to copy file %v to %v", sourcePath, destinationPath)
-	}()
-
-	sourceFileInfo, err := os.Stat(sourcePath)
-	if err != nil {
-		return err
-	}
-
-	if !overWrite {
-		if _, err := os.Stat(destinationPath); err == nil {
-			logrus.Warnf("destination file %v already exists", destinationPath)
-			return nil
-		}
-	}
-
-	sourceFile, err := os.Open(sourcePath)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if errClose := sourceFile.Close(); errClose != nil {
-			logrus.WithError(errClose).Errorf("Failed to close source file %v", sourcePath)
-		}
-	}()
-
-	_, err = CreateDirectory(filepath.Dir(destinationPath), sourceFileInfo.ModTime())
-	if err != nil {
-		return err
-	}
-
-	destinationFile, err := os.Create(destinationPath)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if errClose := destinationFile.Close(); errClose != nil {
-			logrus.WithError(errClose).Errorf("Failed to close destination file %v", destinationPath)
-		}
-	}()
-
-	_, err = io.Copy(destinationFile, sourceFile)
-	if err != nil {
-		return err
-	}
-
-	// Set the modification time of the file.
-	sourceFileModTime := sourceFileInfo.ModTime()
-	return os.Chtimes(destinationPath, sourceFileModTime, sourceFileModTime)
+	return newCopier(context.Background(), &CopyOptions{OverWrite: overWrite}).copyFile(sourcePath, destinationPath)
 }
 
 // GetEmptyFiles retrieves a list of paths for all empty files within the specified directory.
@@ -260,7 +168,7 @@ func GetDiskStat(path string) (diskStat types.DiskStat, err error) {
 	// Format the FSID value with leading zeros
 	fsidFormatted := fmt.Sprintf("%012x", fsidValue)
 
-	return types.DiskStat{
+	diskStat = types.DiskStat{
 		DiskID:           fsidFormatted,
 		Path:             path,
 		Type:             usage.Fstype,
@@ -270,7 +178,35 @@ func GetDiskStat(path string) (diskStat types.DiskStat, err error) {
 		BlockSize:        int64(statfs.Bsize),
 		StorageMaximum:   int64(statfs.Blocks) * int64(statfs.Bsize),
 		StorageAvailable: int64(statfs.Bfree) * int64(statfs.Bsize),
-	}, nil
+		TotalInodes:      int64(statfs.Files),
+		FreeInodes:       int64(statfs.Ffree),
+		MountFlags:       getStatfsMountFlags(&statfs),
+		FilesystemMagic:  int64(statfs.Type),
+	}
+
+	// The block device is unknown for the filesystems without one, like tmpfs.
+	if err := setDiskStatBlockDevice(&diskStat, path); err != nil {
+		logrus.WithError(err).Debugf("Failed to get block device for %v", path)
+	}
+
+	return diskStat, nil
+}
+
+// GetDiskStatWithQuota returns the disk stat for the specified path, with the
+// project quota of the path. The quota is nil if the filesystem does not
+// enforce project quotas.
+func GetDiskStatWithQuota(path string) (types.DiskStat, error) {
+	diskStat, err := GetDiskStat(path)
+	if err != nil {
+		return diskStat, err
+	}
+
+	projectQuota, err := GetProjectQuota(path)
+	if err != nil && !errors.Is(err, types.ErrNotSupported) {
+		return diskStat, errors.Wrapf(err, "failed to get project quota for %v", path)
+	}
+	diskStat.ProjectQuota = projectQuota
+	return diskStat, nil
 }
 
 // ListOpenFiles returns a list of open files in the specified directory.
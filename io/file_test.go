@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -305,7 +306,12 @@ func TestCopyFile(t *testing.T) {
 		"Handle sparse file": {
 			doOverWrite:      true,
 			sparseSize:       4097,
-			expectedSameSize: false,
+			expectedSameSize: true,
+		},
+		"Handle large sparse file": {
+			doOverWrite:      true,
+			sparseSize:       64 * 1024 * 1024,
+			expectedSameSize: true,
 		},
 	}
 	for testName, testCase := range testCases {
@@ -329,7 +335,8 @@ func TestCopyFile(t *testing.T) {
 			}
 
 			if !testCase.doOverWrite && testCase.notExistingDestDirName == "" {
-				fake.CreateTempFile(fakeDestDir, filepath.Base(fakeSourceFile), "do-not-overwrite", t)
+				fakeDestFile := fake.CreateTempFile(fakeDestDir, filepath.Base(fakeSourceFile), "do-not-overwrite", t)
+				_ = fakeDestFile.Close()
 			}
 
 			fakeDestPath := filepath.Join(fakeDestDir, filepath.Base(fakeSourceFile))
@@ -628,12 +635,15 @@ func TestGetDiskStat(t *testing.T) {
 			assert.Equal(t, diskStat.TotalBlocks, expectedDiskStat.TotalBlocks)
 			assert.Equal(t, diskStat.BlockSize, expectedDiskStat.BlockSize)
 			assert.Equal(t, diskStat.StorageMaximum, expectedDiskStat.StorageMaximum)
+			assert.Equal(t, diskStat.TotalInodes, expectedDiskStat.TotalInodes)
+			assert.Equal(t, diskStat.FilesystemMagic, expectedDiskStat.FilesystemMagic)
+			assert.NotNil(t, diskStat.MountFlags)
 		})
 	}
 }
 
 func getDiskStat(path string) (*types.DiskStat, error) {
-	args := []string{"-fc", "{\"path\":\"%n\",\"fsid\":\"%i\",\"type\":\"%T\",\"freeBlock\":%f,\"totalBlock\":%b,\"blockSize\":%S}", path}
+	args := []string{"-fc", "{\"path\":\"%n\",\"fsid\":\"%i\",\"type\":\"%T\",\"freeBlock\":%f,\"totalBlock\":%b,\"blockSize\":%S,\"totalInode\":%c,\"magic\":\"%t\"}", path}
 	output, err := exec.NewExecutor().Execute(nil, "stat", args, types.ExecuteDefaultTimeout)
 	if err != nil {
 		return nil, err
@@ -647,6 +657,8 @@ func getDiskStat(path string) (*types.DiskStat, error) {
 		FreeBlock  int64
 		TotalBlock int64
 		BlockSize  int64
+		TotalInode int64
+		Magic      string
 	}
 	fsStat := &FsStat{}
 	err = json.Unmarshal([]byte(output), fsStat)
@@ -654,6 +666,11 @@ func getDiskStat(path string) (*types.DiskStat, error) {
 		return nil, err
 	}
 
+	magic, err := strconv.ParseInt(fsStat.Magic, 16, 64)
+	if err != nil {
+		return nil, err
+	}
+
 	return &types.DiskStat{
 		DiskID:           fsStat.Fsid,
 		Path:             fsStat.Path,
@@ -664,6 +681,8 @@ func getDiskStat(path string) (*types.DiskStat, error) {
 		BlockSize:        fsStat.BlockSize,
 		StorageMaximum:   fsStat.TotalBlock * fsStat.BlockSize,
 		StorageAvailable: fsStat.FreeBlock * fsStat.BlockSize,
+		TotalInodes:      fsStat.TotalInode,
+		FilesystemMagic:  magic,
 	}, nil
 }
 
@@ -0,0 +1,230 @@
+package io
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const deletedFileSuffix = " (deleted)"
+
+// ListOpenFileDetails returns the files in the specified directory held by the
+// processes in procDirectory, either through a file descriptor or a memory
+// mapping. Each match carries the process and, for file descriptors, the open
+// flags and offset. The result is sorted by pid, type and file descriptor.
+func ListOpenFileDetails(procDirectory, directory string) (openFiles []types.OpenFile, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to list open files in %v", directory)
+	}()
+
+	if _, err := os.Stat(directory); err != nil {
+		return nil, err
+	}
+
+	procs, err := os.ReadDir(procDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, proc := range procs {
+		if !proc.IsDir() {
+			continue
+		}
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+
+		processFiles := listProcessOpenFiles(procDirectory, pid, directory)
+		if len(processFiles) == 0 {
+			continue
+		}
+
+		// The process details are only read for the processes holding a match.
+		processDir := filepath.Join(procDirectory, proc.Name())
+		processName, cmdline := readProcessNameAndCmdline(processDir)
+		for i := range processFiles {
+			processFiles[i].Pid = pid
+			processFiles[i].ProcessName = processName
+			processFiles[i].Cmdline = cmdline
+		}
+		openFiles = append(openFiles, processFiles...)
+	}
+
+	sort.SliceStable(openFiles, func(i, j int) bool {
+		if openFiles[i].Pid != openFiles[j].Pid {
+			return openFiles[i].Pid < openFiles[j].Pid
+		}
+		if openFiles[i].Type != openFiles[j].Type {
+			return openFiles[i].Type < openFiles[j].Type
+		}
+		return openFiles[i].Fd < openFiles[j].Fd
+	})
+	return openFiles, nil
+}
+
+// listProcessOpenFiles returns the files in directory held by the process
+// through file descriptors and memory mappings. The process may exit at any
+// time, so the files that cannot be read are skipped.
+func listProcessOpenFiles(procDirectory string, pid int, directory string) []types.OpenFile {
+	processDir := filepath.Join(procDirectory, strconv.Itoa(pid))
+
+	var openFiles []types.OpenFile
+	fdDir := filepath.Join(processDir, "fd")
+	fds, err := os.ReadDir(fdDir)
+	if err != nil {
+		logrus.WithError(err).Tracef("Failed to read file descriptors for process %v", pid)
+	}
+	for _, fdEntry := range fds {
+		fd, err := strconv.Atoi(fdEntry.Name())
+		if err != nil {
+			continue
+		}
+
+		filePath, err := os.Readlink(filepath.Join(fdDir, fdEntry.Name()))
+		if err != nil {
+			logrus.WithError(err).Tracef("Failed to read link for file descriptor %v of process %v", fd, pid)
+			continue
+		}
+		filePath, isDeleted := strings.CutSuffix(filePath, deletedFileSuffix)
+		if !isPathInDirectory(filePath, directory) {
+			continue
+		}
+
+		openFile := types.OpenFile{
+			Path:      filePath,
+			IsDeleted: isDeleted,
+			Type:      types.OpenFileTypeFd,
+			Fd:        fd,
+		}
+		fdInfo, err := os.ReadFile(filepath.Join(processDir, "fdinfo", fdEntry.Name()))
+		if err == nil {
+			openFile.Offset, openFile.Flags, err = parseFdInfo(string(fdInfo))
+		}
+		if err != nil {
+			logrus.WithError(err).Tracef("Failed to read fdinfo of file descriptor %v of process %v", fd, pid)
+		}
+		openFiles = append(openFiles, openFile)
+	}
+
+	maps, err := os.ReadFile(filepath.Join(processDir, "maps"))
+	if err != nil {
+		logrus.WithError(err).Tracef("Failed to read memory maps for process %v", pid)
+		return openFiles
+	}
+	for _, mapping := range parseProcMaps(string(maps)) {
+		if isPathInDirectory(mapping.Path, directory) {
+			openFiles = append(openFiles, mapping)
+		}
+	}
+	return openFiles
+}
+
+func isPathInDirectory(path, directory string) bool {
+	return strings.HasPrefix(path, directory+"/") || path == directory
+}
+
+// readProcessNameAndCmdline reads the name and the NUL-separated command line
+// of the process.
+func readProcessNameAndCmdline(processDir string) (string, []string) {
+	name, err := os.ReadFile(filepath.Join(processDir, "comm"))
+	if err != nil {
+		logrus.WithError(err).Tracef("Failed to read process name in %v", processDir)
+	}
+
+	var cmdline []string
+	rawCmdline, err := os.ReadFile(filepath.Join(processDir, "cmdline"))
+	if err != nil {
+		logrus.WithError(err).Tracef("Failed to read process cmdline in %v", processDir)
+	}
+	for _, arg := range strings.Split(strings.TrimRight(string(rawCmdline), "\x00"), "\x00") {
+		if arg != "" {
+			cmdline = append(cmdline, arg)
+		}
+	}
+	return strings.TrimSpace(string(name)), cmdline
+}
+
+// parseFdInfo parses the offset and the octal open flags of a file descriptor
+// from /proc/<pid>/fdinfo/<fd>, e.g.:
+//
+//	pos:	4096
+//	flags:	0100002
+//	mnt_id:	29
+//	ino:	1234
+func parseFdInfo(fdInfo string) (offset int64, flags int, err error) {
+	hasPos, hasFlags := false, false
+	for _, line := range strings.Split(fdInfo, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "pos":
+			offset, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, 0, errors.Wrapf(err, "invalid fdinfo pos %q", value)
+			}
+			hasPos = true
+		case "flags":
+			parsed, err := strconv.ParseInt(value, 8, 64)
+			if err != nil {
+				return 0, 0, errors.Wrapf(err, "invalid fdinfo flags %q", value)
+			}
+			flags = int(parsed)
+			hasFlags = true
+		}
+	}
+	if !hasPos || !hasFlags {
+		return 0, 0, errors.Errorf("failed to find pos and flags in fdinfo %q", fdInfo)
+	}
+	return offset, flags, nil
+}
+
+// parseProcMaps returns the files mapped in /proc/<pid>/maps, once per file,
+// e.g.:
+//
+//	7f2c4a000000-7f2c4a021000 r-xp 00000000 08:01 1234   /usr/lib/libc.so.6
+//	7f2c4b000000-7f2c4b400000 rw-s 00000000 00:05 5678   /var/lib/longhorn/replicas/r-1/volume-head-000.img (deleted)
+func parseProcMaps(maps string) []types.OpenFile {
+	var openFiles []types.OpenFile
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(strings.NewReader(maps))
+	for scanner.Scan() {
+		// The path is the sixth field, it may contain spaces.
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 6)
+		if len(fields) < 6 {
+			continue
+		}
+		path := strings.TrimSpace(fields[5])
+		if !strings.HasPrefix(path, "/") {
+			// Anonymous mappings and pseudo paths like [heap] or [stack].
+			continue
+		}
+		path, isDeleted := strings.CutSuffix(path, deletedFileSuffix)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		openFiles = append(openFiles, types.OpenFile{
+			Path:           path,
+			IsDeleted:      isDeleted,
+			Type:           types.OpenFileTypeMmap,
+			Fd:             -1,
+			MapPermissions: fields[1],
+		})
+	}
+	return openFiles
+}
@@ -0,0 +1,110 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestListOpenFileDetails(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	dataDir := filepath.Join(fakeDir, "data")
+	err := os.MkdirAll(dataDir, 0755)
+	assert.NoError(t, err)
+
+	// Create a fake proc directory with one process holding files in the data
+	// directory and one process holding none.
+	fakeProcDir := filepath.Join(fakeDir, "proc")
+	createFakeProcess := func(pid, comm, cmdline, maps string, fds map[string]string, fdInfos map[string]string) {
+		processDir := filepath.Join(fakeProcDir, pid)
+		for _, dir := range []string{"fd", "fdinfo"} {
+			err := os.MkdirAll(filepath.Join(processDir, dir), 0755)
+			assert.NoError(t, err)
+		}
+		for fd, target := range fds {
+			err := os.Symlink(target, filepath.Join(processDir, "fd", fd))
+			assert.NoError(t, err)
+		}
+		for fd, fdInfo := range fdInfos {
+			err := os.WriteFile(filepath.Join(processDir, "fdinfo", fd), []byte(fdInfo), 0644)
+			assert.NoError(t, err)
+		}
+		err := os.WriteFile(filepath.Join(processDir, "comm"), []byte(comm+"\n"), 0644)
+		assert.NoError(t, err)
+		err = os.WriteFile(filepath.Join(processDir, "cmdline"), []byte(cmdline), 0644)
+		assert.NoError(t, err)
+		err = os.WriteFile(filepath.Join(processDir, "maps"), []byte(maps), 0644)
+		assert.NoError(t, err)
+	}
+	createFakeProcess("42", "longhorn",
+		"longhorn\x00replica\x00"+dataDir+"\x00",
+		"55d0c0a00000-55d0c0a21000 r-xp 00000000 08:01 1234                       /usr/bin/longhorn\n"+
+			"7f2c4b000000-7f2c4b400000 rw-s 00000000 08:01 5678                       "+dataDir+"/volume.img\n"+
+			"7f2c4b400000-7f2c4b800000 rw-s 00400000 08:01 5678                       "+dataDir+"/volume.img\n"+
+			"7ffd1c000000-7ffd1c021000 rw-p 00000000 00:00 0                          [stack]\n",
+		map[string]string{
+			"0":  "/dev/null",
+			"3":  dataDir + "/volume.img",
+			"10": dataDir + "/old.img (deleted)",
+		},
+		map[string]string{
+			"3":  "pos:\t4096\nflags:\t0140002\nmnt_id:\t29\nino:\t5678\n",
+			"10": "pos:\t0\nflags:\t02100000\nmnt_id:\t29\nino:\t5679\n",
+		},
+	)
+	createFakeProcess("7", "sleep", "sleep\x00infinity\x00", "", map[string]string{"0": "/dev/null"}, nil)
+	err = os.WriteFile(filepath.Join(fakeProcDir, "uptime"), []byte("1.0 1.0\n"), 0644)
+	assert.NoError(t, err)
+
+	type testCase struct {
+		directory string
+
+		expectedOpenFiles []types.OpenFile
+		expectError       bool
+	}
+	process := func(openFile types.OpenFile) types.OpenFile {
+		openFile.Pid = 42
+		openFile.ProcessName = "longhorn"
+		openFile.Cmdline = []string{"longhorn", "replica", dataDir}
+		return openFile
+	}
+	testCases := map[string]testCase{
+		"Files held by file descriptors and memory mappings": {
+			directory: dataDir,
+			expectedOpenFiles: []types.OpenFile{
+				process(types.OpenFile{Path: dataDir + "/volume.img", Type: types.OpenFileTypeFd, Fd: 3, Flags: 0140002, Offset: 4096}),
+				process(types.OpenFile{Path: dataDir + "/old.img", IsDeleted: true, Type: types.OpenFileTypeFd, Fd: 10, Flags: 02100000}),
+				process(types.OpenFile{Path: dataDir + "/volume.img", Type: types.OpenFileTypeMmap, Fd: -1, MapPermissions: "rw-s"}),
+			},
+		},
+		"No open files": {
+			directory: fakeProcDir,
+		},
+		"Not existing directory": {
+			directory:   filepath.Join(fakeDir, "not-existing"),
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			openFiles, err := ListOpenFileDetails(fakeProcDir, testCase.directory)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expectedOpenFiles, openFiles, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
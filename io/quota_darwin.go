@@ -0,0 +1,22 @@
+package io
+
+import (
+	"fmt"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetProjectID is not supported on darwin.
+func GetProjectID(path string) (uint32, error) {
+	return 0, fmt.Errorf("project ID for %v %w", path, types.ErrNotSupported)
+}
+
+// GetProjectQuota is not supported on darwin.
+func GetProjectQuota(path string) (*types.ProjectQuota, error) {
+	return nil, fmt.Errorf("project quota for %v %w", path, types.ErrNotSupported)
+}
+
+// SetProjectQuota is not supported on darwin.
+func SetProjectQuota(directory string, projectID uint32, hardLimitBytes, hardLimitInodes int64) error {
+	return fmt.Errorf("project quota for %v %w", directory, types.ErrNotSupported)
+}
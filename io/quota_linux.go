@@ -0,0 +1,265 @@
+package io
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// The following definitions are from linux/fs.h and linux/quota.h, they are
+// not provided by golang.org/x/sys/unix.
+const (
+	fsIocFsGetXattr    = 0x801c581f // _IOR('X', 31, struct fsxattr)
+	fsIocFsSetXattr    = 0x401c5820 // _IOW('X', 32, struct fsxattr)
+	fsXflagProjInherit = 0x200
+
+	qGetQuota    = 0x800007
+	qSetQuota    = 0x800008
+	prjQuota     = 2
+	qifBLimits   = 0x1
+	qifILimits   = 0x4
+	qifDqBlkSize = 1024
+)
+
+type fsxattr struct {
+	Xflags     uint32
+	Extsize    uint32
+	Nextents   uint32
+	Projid     uint32
+	Cowextsize uint32
+	Pad        [8]byte
+}
+
+type ifDqblk struct {
+	BHardlimit uint64 // In qifDqBlkSize blocks
+	BSoftlimit uint64 // In qifDqBlkSize blocks
+	CurSpace   uint64 // In bytes
+	IHardlimit uint64
+	ISoftlimit uint64
+	CurInodes  uint64
+	BTime      uint64
+	ITime      uint64
+	Valid      uint32
+}
+
+// GetProjectID returns the XFS or ext4 project ID of the path.
+// The returned error wraps types.ErrNotSupported if the filesystem has no project IDs.
+func GetProjectID(path string) (projectID uint32, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to open %v", path)
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", path)
+		}
+	}()
+
+	attr, err := getFsxattr(file)
+	if err != nil {
+		return 0, err
+	}
+	return attr.Projid, nil
+}
+
+// GetProjectQuota returns the project quota of the path. It returns nil if
+// the path has no project ID. The returned error wraps types.ErrNotSupported
+// if the filesystem does not enforce project quotas.
+func GetProjectQuota(path string) (*types.ProjectQuota, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %v", path)
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", path)
+		}
+	}()
+
+	attr, err := getFsxattr(file)
+	if err != nil {
+		return nil, err
+	}
+	if attr.Projid == 0 {
+		return nil, nil
+	}
+
+	var dqblk ifDqblk
+	if err := quotactl(file, qGetQuota, attr.Projid, &dqblk); err != nil {
+		return nil, err
+	}
+	return &types.ProjectQuota{
+		ProjectID:       attr.Projid,
+		UsedBytes:       int64(dqblk.CurSpace),
+		SoftLimitBytes:  int64(dqblk.BSoftlimit) * qifDqBlkSize,
+		HardLimitBytes:  int64(dqblk.BHardlimit) * qifDqBlkSize,
+		UsedInodes:      int64(dqblk.CurInodes),
+		SoftLimitInodes: int64(dqblk.ISoftlimit),
+		HardLimitInodes: int64(dqblk.IHardlimit),
+	}, nil
+}
+
+// SetProjectQuota assigns the project ID to the directory and its entries,
+// and limits the project to hardLimitBytes bytes and hardLimitInodes inodes.
+// A limit of 0 means no limit. The entries created in the directory afterwards
+// inherit the project ID. The returned error wraps types.ErrNotSupported if the
+// filesystem does not enforce project quotas.
+func SetProjectQuota(directory string, projectID uint32, hardLimitBytes, hardLimitInodes int64) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to set project %v quota for %v", projectID, directory)
+	}()
+
+	if projectID == 0 {
+		return errors.New("project ID 0 is reserved")
+	}
+	if hardLimitBytes < 0 || hardLimitInodes < 0 {
+		return errors.Errorf("invalid limits %v bytes %v inodes", hardLimitBytes, hardLimitInodes)
+	}
+
+	err = filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// The project ID can only be set through regular files and directories.
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			return nil
+		}
+		return setProjectID(path, projectID, entry.IsDir())
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", directory)
+		}
+	}()
+
+	dqblk := ifDqblk{
+		BHardlimit: uint64((hardLimitBytes + qifDqBlkSize - 1) / qifDqBlkSize),
+		IHardlimit: uint64(hardLimitInodes),
+		Valid:      qifBLimits | qifILimits,
+	}
+	return quotactl(file, qSetQuota, projectID, &dqblk)
+}
+
+func setProjectID(path string, projectID uint32, isDirectory bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %v", path)
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %v", path)
+		}
+	}()
+
+	attr, err := getFsxattr(file)
+	if err != nil {
+		return err
+	}
+	attr.Projid = projectID
+	if isDirectory {
+		attr.Xflags |= fsXflagProjInherit
+	}
+	return ioctlFsxattr(file, fsIocFsSetXattr, attr)
+}
+
+func getFsxattr(file *os.File) (*fsxattr, error) {
+	attr := &fsxattr{}
+	if err := ioctlFsxattr(file, fsIocFsGetXattr, attr); err != nil {
+		return nil, err
+	}
+	return attr, nil
+}
+
+func ioctlFsxattr(file *os.File, request uintptr, attr *fsxattr) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), request, uintptr(unsafe.Pointer(attr)))
+	if errno == 0 {
+		return nil
+	}
+	if errno == unix.ENOTTY || errno == unix.EOPNOTSUPP {
+		return fmt.Errorf("project ID for %v %w: %w", file.Name(), types.ErrNotSupported, errno)
+	}
+	return errors.Wrapf(errno, "failed to ioctl fsxattr of %v", file.Name())
+}
+
+// quotactl runs the project quota command on the filesystem of the file with
+// quotactl_fd(2), so that the block device of the filesystem is not needed.
+// Before Linux 5.14, it falls back to quotactl(2) on the block device.
+func quotactl(file *os.File, command int, projectID uint32, dqblk *ifDqblk) error {
+	cmd := command<<8 | prjQuota
+	_, _, errno := unix.Syscall6(unix.SYS_QUOTACTL_FD, file.Fd(), uintptr(cmd), uintptr(projectID), uintptr(unsafe.Pointer(dqblk)), 0, 0)
+	if errno == unix.ENOSYS {
+		devicePath, err := getFilesystemDevicePath(file)
+		if err != nil {
+			return err
+		}
+		errno = quotactlDevice(devicePath, cmd, projectID, dqblk)
+	}
+	if errno == 0 {
+		return nil
+	}
+	// ESRCH means the project quota is not turned on for the filesystem.
+	if errno == unix.ENOSYS || errno == unix.EOPNOTSUPP || errno == unix.ESRCH {
+		return fmt.Errorf("project quota for %v %w: %w", file.Name(), types.ErrNotSupported, errno)
+	}
+	return errors.Wrapf(errno, "failed to quotactl project %v for %v", projectID, file.Name())
+}
+
+// quotactlDevice runs the quota command on the filesystem of the block device
+// with quotactl(2).
+func quotactlDevice(devicePath string, cmd int, projectID uint32, dqblk *ifDqblk) unix.Errno {
+	devicePathPtr, err := unix.BytePtrFromString(devicePath)
+	if err != nil {
+		return unix.EINVAL
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_QUOTACTL, uintptr(cmd), uintptr(unsafe.Pointer(devicePathPtr)), uintptr(projectID), uintptr(unsafe.Pointer(dqblk)), 0, 0)
+	return errno
+}
+
+// getFilesystemDevicePath returns the path of the block device of the
+// filesystem of the file. The returned error wraps types.ErrNotSupported if
+// the filesystem has no block device, e.g. tmpfs.
+func getFilesystemDevicePath(file *os.File) (string, error) {
+	var stat unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &stat); err != nil {
+		return "", errors.Wrapf(err, "failed to stat %v", file.Name())
+	}
+	return getBlockDevicePath(types.SysDevBlockDirectory, unix.Major(stat.Dev), unix.Minor(stat.Dev), os.ReadFile)
+}
+
+// getBlockDevicePath returns the /dev path of the block device of the device
+// number from its uevent in the sysDevBlockDirectory.
+// It injects the readFileFn for testing.
+func getBlockDevicePath(sysDevBlockDirectory string, major, minor uint32, readFileFn func(string) ([]byte, error)) (string, error) {
+	ueventPath := filepath.Join(sysDevBlockDirectory, fmt.Sprintf("%d:%d", major, minor), "uevent")
+	content, err := readFileFn(ueventPath)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("block device %d:%d %w", major, minor, types.ErrNotSupported)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %v", ueventPath)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if name, ok := strings.CutPrefix(line, "DEVNAME="); ok {
+			return filepath.Join("/dev", name), nil
+		}
+	}
+	return "", fmt.Errorf("no DEVNAME in %v", ueventPath)
+}
@@ -0,0 +1,41 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestGetBlockDevicePath(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	for device, uevent := range map[string]string{
+		"8:1":   "MAJOR=8\nMINOR=1\nDEVNAME=sda1\nDEVTYPE=partition\n",
+		"253:0": "MAJOR=253\nMINOR=0\nDEVTYPE=disk\n",
+	} {
+		err := os.MkdirAll(filepath.Join(fakeDir, device), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(filepath.Join(fakeDir, device, "uevent"), []byte(uevent), 0644)
+		assert.NoError(t, err)
+	}
+
+	devicePath, err := getBlockDevicePath(fakeDir, 8, 1, os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/sda1", devicePath)
+
+	_, err = getBlockDevicePath(fakeDir, 253, 0, os.ReadFile)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, types.ErrNotSupported))
+
+	_, err = getBlockDevicePath(fakeDir, 0, 42, os.ReadFile)
+	assert.True(t, errors.Is(err, types.ErrNotSupported))
+}
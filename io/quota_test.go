@@ -0,0 +1,61 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestProjectQuota(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	file := fake.CreateTempFile(fakeDir, "existing", "content", t)
+	_ = file.Close()
+
+	err := SetProjectQuota(fakeDir, 0, 0, 0)
+	assert.Error(t, err, "project ID 0 should be rejected")
+
+	projectID := uint32(4242)
+	err = SetProjectQuota(fakeDir, projectID, 10*1024*1024, 100)
+	if errors.Is(err, types.ErrNotSupported) {
+		t.Skipf("Project quota is not supported for %v: %v", fakeDir, err)
+	}
+	assert.NoError(t, err, Commentf(test.ErrErrorFmt, "SetProjectQuota", err))
+
+	for _, path := range []string{fakeDir, file.Name()} {
+		id, err := GetProjectID(path)
+		assert.NoError(t, err, Commentf(test.ErrErrorFmt, "GetProjectID", err))
+		assert.Equal(t, projectID, id, Commentf(test.ErrResultFmt, path))
+	}
+
+	newFile := fake.CreateTempFile(fakeDir, "new", "content", t)
+	_ = newFile.Close()
+	id, err := GetProjectID(filepath.Join(fakeDir, "new"))
+	assert.NoError(t, err, Commentf(test.ErrErrorFmt, "GetProjectID", err))
+	assert.Equal(t, projectID, id, "new files should inherit the project ID")
+
+	quota, err := GetProjectQuota(fakeDir)
+	assert.NoError(t, err, Commentf(test.ErrErrorFmt, "GetProjectQuota", err))
+	assert.Equal(t, int64(10*1024*1024), quota.HardLimitBytes)
+	assert.Equal(t, int64(100), quota.HardLimitInodes)
+	assert.Greater(t, quota.UsedInodes, int64(0))
+
+	diskStat, err := GetDiskStat(fakeDir)
+	assert.NoError(t, err, Commentf(test.ErrErrorFmt, "GetDiskStat", err))
+	assert.Nil(t, diskStat.ProjectQuota)
+
+	diskStat, err = GetDiskStatWithQuota(fakeDir)
+	assert.NoError(t, err, Commentf(test.ErrErrorFmt, "GetDiskStatWithQuota", err))
+	assert.Equal(t, quota, diskStat.ProjectQuota)
+}
@@ -0,0 +1,21 @@
+package io
+
+import (
+	"io"
+	"os"
+)
+
+// copyFileContent copies the content of the source file to the destination file.
+func copyFileContent(destinationFile, sourceFile *os.File, size int64) error {
+	_, err := io.Copy(destinationFile, sourceFile)
+	return err
+}
+
+// copyRange copies length bytes at offset of the source file to the same
+// offset of the destination file.
+func copyRange(destinationFile, sourceFile *os.File, offset, length int64) error {
+	reader := io.NewSectionReader(sourceFile, offset, length)
+	writer := io.NewOffsetWriter(destinationFile, offset)
+	_, err := io.Copy(writer, reader)
+	return err
+}
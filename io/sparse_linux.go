@@ -0,0 +1,123 @@
+package io
+
+import (
+	"io"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// copyFileContent copies the content of the source file to the destination file
+// and keeps the holes of the source file. It tries, in order:
+//  1. a reflink (FICLONE), which shares the extents without copying any data,
+//  2. a sparse copy of the data extents found with SEEK_DATA/SEEK_HOLE, using
+//     copy_file_range when the kernel and filesystems support it,
+//  3. a plain copy of the whole content when the filesystem cannot report holes.
+func copyFileContent(destinationFile, sourceFile *os.File, size int64) error {
+	err := unix.IoctlFileClone(int(destinationFile.Fd()), int(sourceFile.Fd()))
+	if err == nil {
+		return nil
+	}
+	logrus.WithError(err).Tracef("Failed to reflink %v to %v, falling back to sparse copy", sourceFile.Name(), destinationFile.Name())
+
+	err = copySparse(destinationFile, sourceFile, size)
+	if err == nil {
+		return nil
+	}
+	if !isSeekHoleUnsupported(err) {
+		return err
+	}
+
+	logrus.WithError(err).Tracef("Filesystem of %v does not report holes, falling back to plain copy", sourceFile.Name())
+	if _, err := sourceFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := destinationFile.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := destinationFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(destinationFile, sourceFile)
+	return err
+}
+
+// copySparse copies only the data extents of the source file to the same
+// offsets of the destination file, and extends the destination file to size
+// so that the holes are preserved.
+func copySparse(destinationFile, sourceFile *os.File, size int64) error {
+	if err := destinationFile.Truncate(size); err != nil {
+		return err
+	}
+
+	sourceFd := int(sourceFile.Fd())
+	var offset int64
+	for offset < size {
+		dataStart, err := unix.Seek(sourceFd, offset, unix.SEEK_DATA)
+		if err != nil {
+			if errors.Is(err, unix.ENXIO) {
+				// There is no more data after offset, the rest of the file is a hole.
+				return nil
+			}
+			return err
+		}
+
+		dataEnd, err := unix.Seek(sourceFd, dataStart, unix.SEEK_HOLE)
+		if err != nil {
+			return err
+		}
+		if dataEnd > size {
+			dataEnd = size
+		}
+
+		if err := copyRange(destinationFile, sourceFile, dataStart, dataEnd-dataStart); err != nil {
+			return errors.Wrapf(err, "failed to copy range [%v, %v)", dataStart, dataEnd)
+		}
+		offset = dataEnd
+	}
+	return nil
+}
+
+// copyRange copies length bytes at offset of the source file to the same
+// offset of the destination file. It uses copy_file_range and falls back to
+// reading and writing the range if it is not supported.
+func copyRange(destinationFile, sourceFile *os.File, offset, length int64) error {
+	sourceOffset := offset
+	destinationOffset := offset
+	remaining := length
+	for remaining > 0 {
+		copied, err := unix.CopyFileRange(int(sourceFile.Fd()), &sourceOffset, int(destinationFile.Fd()), &destinationOffset, int(remaining), 0)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if isCopyFileRangeUnsupported(err) {
+				break
+			}
+			return err
+		}
+		if copied == 0 {
+			break
+		}
+		remaining -= int64(copied)
+	}
+	if remaining == 0 {
+		return nil
+	}
+
+	copyOffset := offset + length - remaining
+	reader := io.NewSectionReader(sourceFile, copyOffset, remaining)
+	writer := io.NewOffsetWriter(destinationFile, copyOffset)
+	_, err := io.Copy(writer, reader)
+	return err
+}
+
+// isCopyFileRangeUnsupported checks if the error means that copy_file_range
+// cannot be used between the files, e.g. they are on different filesystems on
+// an older kernel.
+func isCopyFileRangeUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EBADF)
+}
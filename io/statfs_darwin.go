@@ -0,0 +1,28 @@
+package io
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+var statfsMountFlags = []struct {
+	flag uint32
+	name string
+}{
+	{unix.MNT_RDONLY, "ro"},
+	{unix.MNT_NOSUID, "nosuid"},
+	{unix.MNT_NODEV, "nodev"},
+	{unix.MNT_NOEXEC, "noexec"},
+	{unix.MNT_SYNCHRONOUS, "sync"},
+	{unix.MNT_NOATIME, "noatime"},
+}
+
+// getStatfsMountFlags returns the names of the mount flags set in the statfs result.
+func getStatfsMountFlags(statfs *unix.Statfs_t) []string {
+	flags := []string{}
+	for _, mountFlag := range statfsMountFlags {
+		if statfs.Flags&mountFlag.flag != 0 {
+			flags = append(flags, mountFlag.name)
+		}
+	}
+	return flags
+}
@@ -0,0 +1,31 @@
+package io
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+var statfsMountFlags = []struct {
+	flag int64
+	name string
+}{
+	{unix.ST_RDONLY, "ro"},
+	{unix.ST_NOSUID, "nosuid"},
+	{unix.ST_NODEV, "nodev"},
+	{unix.ST_NOEXEC, "noexec"},
+	{unix.ST_SYNCHRONOUS, "sync"},
+	{unix.ST_MANDLOCK, "mand"},
+	{unix.ST_NOATIME, "noatime"},
+	{unix.ST_NODIRATIME, "nodiratime"},
+	{unix.ST_RELATIME, "relatime"},
+}
+
+// getStatfsMountFlags returns the names of the mount flags set in the statfs result.
+func getStatfsMountFlags(statfs *unix.Statfs_t) []string {
+	flags := []string{}
+	for _, mountFlag := range statfsMountFlags {
+		if int64(statfs.Flags)&mountFlag.flag != 0 {
+			flags = append(flags, mountFlag.name)
+		}
+	}
+	return flags
+}
@@ -0,0 +1,318 @@
+package io
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const (
+	defaultWatcherDebounce     = 100 * time.Millisecond
+	defaultWatcherPollInterval = 2 * time.Second
+
+	// watcherMaxDebounceFactor bounds the delay of a batch under a continuous
+	// stream of events, as a multiple of the debounce.
+	watcherMaxDebounceFactor = 10
+
+	watcherErrorBufferSize = 16
+)
+
+// WatcherOptions defines optional parameters of a Watcher.
+type WatcherOptions struct {
+	// Recursive watches the subdirectories, including the ones created later.
+	Recursive bool
+	// Debounce is the quiet period after the last event before the pending
+	// events are delivered. Zero means 100ms. Under a continuous stream of
+	// events, a batch is still delivered every 10 debounce periods.
+	Debounce time.Duration
+	// PollInterval is the interval of the polling fallback. Zero means 2s.
+	PollInterval time.Duration
+	// ForcePolling polls the paths even if inotify is available.
+	ForcePolling bool
+	// RootDirectory, if not empty, is the directory the paths are resolved in
+	// (e.g. /proc/1/root), the event paths are reported relative to it.
+	RootDirectory string
+}
+
+// Watcher reports the changes of files and directories. It uses inotify when
+// it is available and falls back to polling otherwise. The events are
+// coalesced per path and delivered in batches sorted by path.
+type Watcher struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	options   WatcherOptions
+	paths     []string // The watched paths resolved in the root directory.
+	isPolling bool
+
+	rawEvents chan types.FileEvent
+	events    chan []types.FileEvent
+	errors    chan error
+}
+
+// watcherBackend detects the changes of the watched paths.
+type watcherBackend interface {
+	// run reports the changes with Watcher.emit until ctx is done.
+	run(ctx context.Context)
+}
+
+// NewWatcher starts watching the paths until ctx is done or the Watcher is closed.
+func NewWatcher(ctx context.Context, paths []string, options *WatcherOptions) (*Watcher, error) {
+	w := &Watcher{
+		rawEvents: make(chan types.FileEvent),
+		events:    make(chan []types.FileEvent),
+		errors:    make(chan error, watcherErrorBufferSize),
+	}
+	if options != nil {
+		w.options = *options
+	}
+	if w.options.Debounce <= 0 {
+		w.options.Debounce = defaultWatcherDebounce
+	}
+	if w.options.PollInterval <= 0 {
+		w.options.PollInterval = defaultWatcherPollInterval
+	}
+
+	for _, path := range paths {
+		resolvedPath := filepath.Join(w.options.RootDirectory, path)
+		if _, err := os.Stat(resolvedPath); err != nil {
+			return nil, errors.Wrapf(err, "failed to watch %v", path)
+		}
+		w.paths = append(w.paths, resolvedPath)
+	}
+
+	var backend watcherBackend
+	if !w.options.ForcePolling {
+		var err error
+		backend, err = newWatcherBackend(w)
+		if err != nil {
+			logrus.WithError(err).Warnf("Failed to watch %v with inotify, falling back to polling", paths)
+		}
+	}
+	if backend == nil {
+		w.isPolling = true
+		backend = newPollingWatcherBackend(w)
+	}
+
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	w.wg.Add(2)
+	go func() {
+		defer w.wg.Done()
+		defer close(w.errors)
+		backend.run(w.ctx)
+	}()
+	go func() {
+		defer w.wg.Done()
+		w.coalesce(w.ctx)
+	}()
+	return w, nil
+}
+
+// Events returns the channel of the event batches. It is closed when the
+// Watcher stops.
+func (w *Watcher) Events() <-chan []types.FileEvent {
+	return w.events
+}
+
+// Errors returns the channel of the errors that do not stop the Watcher. It
+// is closed when the Watcher stops.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
+}
+
+// IsPolling checks if the Watcher uses the polling fallback.
+func (w *Watcher) IsPolling() bool {
+	return w.isPolling
+}
+
+// Close stops the Watcher and waits for it to release its resources.
+func (w *Watcher) Close() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+// emit sends a change of a resolved path to the coalescing loop.
+func (w *Watcher) emit(event types.FileEvent) {
+	if w.options.RootDirectory != "" {
+		if relativePath, err := filepath.Rel(w.options.RootDirectory, event.Path); err == nil {
+			event.Path = filepath.Join("/", relativePath)
+		}
+	}
+
+	select {
+	case w.rawEvents <- event:
+	case <-w.ctx.Done():
+	}
+}
+
+func (w *Watcher) reportError(err error) {
+	select {
+	case w.errors <- err:
+	default:
+		logrus.WithError(err).Warn("Dropping file watcher error")
+	}
+}
+
+// coalesce merges the events per path and delivers them once no event arrived
+// for the debounce period.
+func (w *Watcher) coalesce(ctx context.Context) {
+	defer close(w.events)
+
+	pending := map[string]types.FileEventOp{}
+	var firstPending time.Time
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event := <-w.rawEvents:
+			mergeFileEvent(pending, event)
+
+			now := time.Now()
+			if firstPending.IsZero() {
+				firstPending = now
+			}
+			delay := min(w.options.Debounce, firstPending.Add(watcherMaxDebounceFactor*w.options.Debounce).Sub(now))
+			timer.Reset(max(delay, 0))
+
+		case <-timer.C:
+			firstPending = time.Time{}
+			if len(pending) == 0 {
+				continue
+			}
+
+			batch := make([]types.FileEvent, 0, len(pending))
+			for path, op := range pending {
+				batch = append(batch, types.FileEvent{Path: path, Op: op})
+			}
+			sort.Slice(batch, func(i, j int) bool {
+				return batch[i].Path < batch[j].Path
+			})
+			clear(pending)
+
+			select {
+			case w.events <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// mergeFileEvent merges the event into the pending operations of its path.
+// A path created and removed again within the batch is dropped.
+func mergeFileEvent(pending map[string]types.FileEventOp, event types.FileEvent) {
+	op, exists := pending[event.Path]
+	if exists && op&types.FileEventOpCreate != 0 && op&types.FileEventOpResync == 0 &&
+		event.Op&(types.FileEventOpRemove|types.FileEventOpRename) != 0 {
+		delete(pending, event.Path)
+		return
+	}
+	pending[event.Path] = op | event.Op
+}
+
+// pollingWatcherBackend detects the changes by comparing snapshots of the
+// watched paths taken every poll interval.
+type pollingWatcherBackend struct {
+	w      *Watcher
+	states map[string]pollingFileState
+}
+
+type pollingFileState struct {
+	size    int64
+	modTime time.Time
+	mode    os.FileMode
+}
+
+// newPollingWatcherBackend takes the first snapshot right away, so that the
+// changes made after NewWatcher returns are reported.
+func newPollingWatcherBackend(w *Watcher) *pollingWatcherBackend {
+	b := &pollingWatcherBackend{w: w}
+	b.states = b.snapshot()
+	return b
+}
+
+func (b *pollingWatcherBackend) run(ctx context.Context) {
+	states := b.states
+
+	ticker := time.NewTicker(b.w.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := b.snapshot()
+		for path, state := range current {
+			previous, exists := states[path]
+			switch {
+			case !exists:
+				b.w.emit(types.FileEvent{Path: path, Op: types.FileEventOpCreate})
+			case state.size != previous.size || !state.modTime.Equal(previous.modTime):
+				b.w.emit(types.FileEvent{Path: path, Op: types.FileEventOpWrite})
+			case state.mode != previous.mode:
+				b.w.emit(types.FileEvent{Path: path, Op: types.FileEventOpChmod})
+			}
+		}
+		for path := range states {
+			if _, exists := current[path]; !exists {
+				b.w.emit(types.FileEvent{Path: path, Op: types.FileEventOpRemove})
+			}
+		}
+		states = current
+	}
+}
+
+// snapshot returns the states of the watched paths and of their children.
+// The paths removed while they are being read are skipped.
+func (b *pollingWatcherBackend) snapshot() map[string]pollingFileState {
+	states := map[string]pollingFileState{}
+	addState := func(path string, fileInfo os.FileInfo) {
+		states[path] = pollingFileState{
+			size:    fileInfo.Size(),
+			modTime: fileInfo.ModTime(),
+			mode:    fileInfo.Mode(),
+		}
+	}
+
+	for _, root := range b.w.paths {
+		rootInfo, err := os.Lstat(root)
+		if err != nil {
+			continue
+		}
+		addState(root, rootInfo)
+		if !rootInfo.IsDir() {
+			continue
+		}
+
+		_ = filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+			if err != nil || path == root {
+				return nil
+			}
+			if fileInfo, err := entry.Info(); err == nil {
+				addState(path, fileInfo)
+			}
+			if entry.IsDir() && !b.w.options.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	return states
+}
@@ -0,0 +1,12 @@
+package io
+
+import (
+	"fmt"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// newWatcherBackend always fails on darwin, so the Watcher falls back to polling.
+func newWatcherBackend(w *Watcher) (watcherBackend, error) {
+	return nil, fmt.Errorf("inotify %w", types.ErrNotSupported)
+}
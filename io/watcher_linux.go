@@ -0,0 +1,193 @@
+package io
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const inotifyWatchMask = unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CREATE | unix.IN_DELETE | unix.IN_DELETE_SELF |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_MOVE_SELF
+
+// inotifyWatcherBackend detects the changes with inotify. The watches are only
+// accessed by the goroutine running the backend once it is started.
+type inotifyWatcherBackend struct {
+	w       *Watcher
+	fd      int
+	file    *os.File
+	watches map[int32]string // The watched paths by watch descriptor.
+}
+
+func newWatcherBackend(w *Watcher) (watcherBackend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize inotify")
+	}
+
+	// The file is non-blocking, so reading it is handled by the runtime poller
+	// and closing it interrupts a pending read. The descriptor is kept aside,
+	// because calling Fd on the file would switch it back to blocking mode.
+	b := &inotifyWatcherBackend{
+		w:       w,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: map[int32]string{},
+	}
+	for _, path := range w.paths {
+		if err := b.addWatches(path); err != nil {
+			_ = b.file.Close()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// addWatches watches the path and, for a recursive Watcher, all the
+// directories under it.
+func (b *inotifyWatcherBackend) addWatches(path string) error {
+	if !b.w.options.Recursive {
+		return b.addWatch(path)
+	}
+
+	return filepath.WalkDir(path, func(subPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			// The directory may have been removed while it is being walked.
+			if subPath != path && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if subPath != path && !entry.IsDir() {
+			return nil
+		}
+		return b.addWatch(subPath)
+	})
+}
+
+func (b *inotifyWatcherBackend) addWatch(path string) error {
+	wd, err := unix.InotifyAddWatch(b.fd, path, inotifyWatchMask)
+	if err != nil {
+		return errors.Wrapf(err, "failed to add inotify watch for %v", path)
+	}
+	b.watches[int32(wd)] = path
+	return nil
+}
+
+func (b *inotifyWatcherBackend) run(ctx context.Context) {
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stopped:
+		}
+		_ = b.file.Close()
+	}()
+
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := b.file.Read(buffer)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return
+			}
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			b.w.reportError(errors.Wrap(err, "failed to read inotify events"))
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := nameStart + int(event.Len)
+			name := strings.TrimRight(string(buffer[nameStart:nameEnd]), "\x00")
+			b.handleEvent(event.Wd, event.Mask, name)
+			offset = nameEnd
+		}
+	}
+}
+
+func (b *inotifyWatcherBackend) handleEvent(wd int32, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		b.resync()
+		return
+	}
+
+	path, exists := b.watches[wd]
+	if !exists {
+		return
+	}
+	if mask&unix.IN_IGNORED != 0 {
+		delete(b.watches, wd)
+		return
+	}
+	if name != "" {
+		path = filepath.Join(path, name)
+	}
+
+	if op := inotifyMaskToFileEventOp(mask); op != 0 {
+		b.w.emit(types.FileEvent{Path: path, Op: op})
+	}
+
+	if b.w.options.Recursive && mask&unix.IN_ISDIR != 0 && mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		b.watchNewDirectory(path)
+	}
+}
+
+// watchNewDirectory watches a directory created or moved into a watched
+// directory. The entries created in it before the watch was added are reported
+// as created.
+func (b *inotifyWatcherBackend) watchNewDirectory(directory string) {
+	if err := b.addWatches(directory); err != nil {
+		b.w.reportError(err)
+		return
+	}
+
+	_ = filepath.WalkDir(directory, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && path != directory {
+			b.w.emit(types.FileEvent{Path: path, Op: types.FileEventOpCreate})
+		}
+		return nil
+	})
+}
+
+// resync handles an overflow of the inotify queue, where events were lost. It
+// watches the directories created in the meantime and asks the consumer to
+// read the state of every watched path again.
+func (b *inotifyWatcherBackend) resync() {
+	for _, path := range b.w.paths {
+		if err := b.addWatches(path); err != nil {
+			b.w.reportError(err)
+		}
+		b.w.emit(types.FileEvent{Path: path, Op: types.FileEventOpResync})
+	}
+}
+
+func inotifyMaskToFileEventOp(mask uint32) types.FileEventOp {
+	var op types.FileEventOp
+	if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		op |= types.FileEventOpCreate
+	}
+	if mask&unix.IN_MODIFY != 0 {
+		op |= types.FileEventOpWrite
+	}
+	if mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0 {
+		op |= types.FileEventOpRemove
+	}
+	if mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0 {
+		op |= types.FileEventOpRename
+	}
+	if mask&unix.IN_ATTRIB != 0 {
+		op |= types.FileEventOpChmod
+	}
+	return op
+}
@@ -0,0 +1,147 @@
+package io
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestWatcher(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	type testCase struct {
+		forcePolling  bool
+		rootDirectory bool
+	}
+	testCases := map[string]testCase{
+		"Inotify": {},
+		"Polling": {
+			forcePolling: true,
+		},
+		"Inotify in root directory": {
+			rootDirectory: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			testDir := fake.CreateTempDirectory(fakeDir, t)
+
+			// Events are reported with paths relative to the root directory.
+			watchedDir := testDir
+			reportedDir := testDir
+			options := &WatcherOptions{
+				Recursive:    true,
+				Debounce:     20 * time.Millisecond,
+				PollInterval: 50 * time.Millisecond,
+				ForcePolling: testCase.forcePolling,
+			}
+			if testCase.rootDirectory {
+				options.RootDirectory = fakeDir
+				watchedDir = filepath.Join("/", filepath.Base(testDir))
+				reportedDir = watchedDir
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			watcher, err := NewWatcher(ctx, []string{watchedDir}, options)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			defer watcher.Close()
+			assert.Equal(t, testCase.forcePolling, watcher.IsPolling(), Commentf(test.ErrResultFmt, testName))
+
+			err = os.MkdirAll(filepath.Join(testDir, "sub"), 0755)
+			assert.NoError(t, err)
+			// Let the new directory be watched before writing in it.
+			time.Sleep(100 * time.Millisecond)
+			err = os.WriteFile(filepath.Join(testDir, "sub", "file"), []byte("content"), 0644)
+			assert.NoError(t, err)
+
+			expectedPath := filepath.Join(reportedDir, "sub", "file")
+			seen := map[string]types.FileEventOp{}
+			for seen[expectedPath]&types.FileEventOpCreate == 0 {
+				select {
+				case batch := <-watcher.Events():
+					for _, event := range batch {
+						seen[event.Path] |= event.Op
+					}
+				case <-ctx.Done():
+					assert.Fail(t, "Timed out waiting for events", "%v: %v", testName, seen)
+					return
+				}
+			}
+			assert.NotZero(t, seen[filepath.Join(reportedDir, "sub")]&types.FileEventOpCreate, Commentf(test.ErrResultFmt, testName))
+
+			err = os.Remove(filepath.Join(testDir, "sub", "file"))
+			assert.NoError(t, err)
+			for seen[expectedPath]&types.FileEventOpRemove == 0 {
+				select {
+				case batch := <-watcher.Events():
+					for _, event := range batch {
+						seen[event.Path] |= event.Op
+					}
+				case <-ctx.Done():
+					assert.Fail(t, "Timed out waiting for events", "%v: %v", testName, seen)
+					return
+				}
+			}
+
+			watcher.Close()
+			_, isOpen := <-watcher.Events()
+			assert.False(t, isOpen, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestMergeFileEvent(t *testing.T) {
+	type testCase struct {
+		events []types.FileEvent
+
+		expectedPending map[string]types.FileEventOp
+	}
+	testCases := map[string]testCase{
+		"Coalesce writes": {
+			events: []types.FileEvent{
+				{Path: "/a", Op: types.FileEventOpWrite},
+				{Path: "/a", Op: types.FileEventOpWrite},
+				{Path: "/a", Op: types.FileEventOpChmod},
+			},
+			expectedPending: map[string]types.FileEventOp{"/a": types.FileEventOpWrite | types.FileEventOpChmod},
+		},
+		"Drop created and removed path": {
+			events: []types.FileEvent{
+				{Path: "/a", Op: types.FileEventOpCreate},
+				{Path: "/a", Op: types.FileEventOpWrite},
+				{Path: "/a", Op: types.FileEventOpRemove},
+				{Path: "/b", Op: types.FileEventOpWrite},
+			},
+			expectedPending: map[string]types.FileEventOp{"/b": types.FileEventOpWrite},
+		},
+		"Keep removed and created path": {
+			events: []types.FileEvent{
+				{Path: "/a", Op: types.FileEventOpRemove},
+				{Path: "/a", Op: types.FileEventOpCreate},
+			},
+			expectedPending: map[string]types.FileEventOp{"/a": types.FileEventOpRemove | types.FileEventOpCreate},
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			pending := map[string]types.FileEventOp{}
+			for _, event := range testCase.events {
+				mergeFileEvent(pending, event)
+			}
+			assert.Equal(t, testCase.expectedPending, pending, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
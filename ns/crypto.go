@@ -3,15 +3,55 @@ package ns
 import (
 	"fmt"
 	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
 
+	"github.com/longhorn/go-common-libs/multierr"
 	"github.com/longhorn/go-common-libs/types"
 	"github.com/longhorn/go-common-libs/utils"
 )
 
+const (
+	LuksTypeLuks1 = "luks1"
+	LuksTypeLuks2 = "luks2"
+)
+
+const (
+	luksKeySizeMax = 4096 // bits
+
+	// Minimum iteration counts enforced by cryptsetup for --pbkdf-force-iterations.
+	luksPBKDF2MinIterations = 1000
+	luksArgon2MinIterations = 4
+	luksPBKDFMaxIterations  = 1<<32 - 1
+
+	// Memory cost range accepted by cryptsetup for --pbkdf-memory.
+	luksArgon2MinMemoryKiB = 32
+	luksArgon2MaxMemoryKiB = 4 * 1024 * 1024
+)
+
+// luksHashes are the hash names accepted by cryptsetup for --hash.
+var luksHashes = []string{
+	"sha1", "sha224", "sha256", "sha384", "sha512",
+	"sha3-224", "sha3-256", "sha3-384", "sha3-512",
+	"ripemd160", "whirlpool", "sm3", "stribog256", "stribog512",
+	"blake2b-160", "blake2b-256", "blake2b-384", "blake2b-512",
+	"blake2s-128", "blake2s-160", "blake2s-224", "blake2s-256",
+}
+
+// luksCipherSpecRegexp matches the characters allowed in a cipher specification
+// such as aes-xts-plain64 or capi:xts(aes)-plain64.
+var luksCipherSpecRegexp = regexp.MustCompile(`^[a-z0-9_(),:-]+$`)
+
+// luksAeadCipherModeRegexp matches the cipher specifications with an AEAD mode
+// such as aes-gcm-random, chacha20-poly1305-random, aegis128-random or
+// capi:rfc7539(chacha20,poly1305)-random.
+var luksAeadCipherModeRegexp = regexp.MustCompile(`(^|[-(,:])(gcm|ccm|poly1305|aegis[0-9]*|rfc4106|rfc4309|rfc7539)([-(),]|$)`)
+
 // LuksFormatOptions defines optional parameters used when running cryptsetup luksFormat.
 type LuksFormatOptions struct {
 	KeyCipher            string
@@ -20,6 +60,155 @@ type LuksFormatOptions struct {
 	PBKDF                string
 	PBKDFForceIterations string // optional. PBKDF iteration count to force when deriving the key
 	PBKDFMemory          string // optional. Memory cost for PBKDF in KiB
+	Integrity            string // optional. Integrity algorithm for authenticated encryption (e.g. hmac-sha256, aead)
+	Type                 string // optional. LUKS version, luks1 or luks2 (default)
+	Offset               string // optional. Start offset of the data in 512-byte sectors
+	Header               string // optional. Path of a detached header, the device then contains only data
+}
+
+// NewLuksFormatOptions creates LuksFormatOptions from the crypto parameters keyed
+// by the CRYPTO_* keys (e.g. types.CryptoKeyCipher). The values are trimmed and
+// lowercased, missing keys are left empty so that cryptsetup defaults are used.
+func NewLuksFormatOptions(parameters map[string]string) *LuksFormatOptions {
+	normalize := func(key string) string {
+		return strings.ToLower(strings.TrimSpace(parameters[key]))
+	}
+
+	return &LuksFormatOptions{
+		KeyCipher:            normalize(types.CryptoKeyCipher),
+		KeyHash:              normalize(types.CryptoKeyHash),
+		KeySize:              normalize(types.CryptoKeySize),
+		PBKDF:                normalize(types.CryptoPBKDF),
+		PBKDFForceIterations: normalize(types.CryptoPBKDFForceIterations),
+		PBKDFMemory:          normalize(types.CryptoPBKDFMemory),
+		Integrity:            normalize(types.CryptoIntegrity),
+	}
+}
+
+// Validate checks the LuksFormatOptions before they are passed to cryptsetup.
+// Nil options are the cryptsetup defaults and are valid. Only the syntax of the
+// cipher is checked, because a cipher that is not registered in /proc/crypto
+// yet may still be loaded by the kernel on first use, see
+// ValidateWithCapabilities. The returned error is a multierr.MultiError keyed
+// by the field name.
+func (options *LuksFormatOptions) Validate() error {
+	if errs := options.validate(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateWithCapabilities is like Validate, but also rejects a cipher that is
+// not registered in the kernel ciphers of the capabilities. Use it when the
+// kernel modules of the ciphers are known to be loaded. Nil capabilities or
+// unknown kernel ciphers skip the check.
+func (options *LuksFormatOptions) ValidateWithCapabilities(capabilities *CryptsetupCapabilities) error {
+	errs := options.validate()
+	if options != nil && options.KeyCipher != "" && len(errs["KeyCipher"]) == 0 &&
+		capabilities != nil && !capabilities.IsKernelCipherAvailable(options.KeyCipher) {
+		errs.Append("KeyCipher", errors.Errorf("cipher %q is not registered in /proc/crypto", options.KeyCipher))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate checks the LuksFormatOptions and returns the errors keyed by the
+// field name.
+func (options *LuksFormatOptions) validate() multierr.MultiError {
+	errs := multierr.NewMultiError()
+	if options == nil {
+		return errs
+	}
+
+	if options.KeyCipher != "" {
+		if !luksCipherSpecRegexp.MatchString(options.KeyCipher) || strings.HasPrefix(options.KeyCipher, "-") || strings.Contains(options.KeyCipher, "--") {
+			errs.Append("KeyCipher", errors.Errorf("invalid cipher specification %q, expected <cipher>-<mode>-<iv> (e.g. aes-xts-plain64)", options.KeyCipher))
+		}
+	}
+
+	if options.KeyHash != "" && !utils.Contains(luksHashes, options.KeyHash) {
+		errs.Append("KeyHash", errors.Errorf("unknown hash %q, supported: %v", options.KeyHash, luksHashes))
+	}
+
+	if options.KeySize != "" {
+		keySize, err := strconv.Atoi(options.KeySize)
+		switch {
+		case err != nil:
+			errs.Append("KeySize", errors.Errorf("key size %q is not a number of bits", options.KeySize))
+		case keySize <= 0 || keySize > luksKeySizeMax || keySize%8 != 0:
+			errs.Append("KeySize", errors.Errorf("key size %v must be a multiple of 8 between 8 and %v bits", keySize, luksKeySizeMax))
+		}
+	}
+
+	switch options.Type {
+	case "", LuksTypeLuks1, LuksTypeLuks2:
+	default:
+		errs.Append("Type", errors.Errorf("unknown LUKS type %q, supported: %v", options.Type, []string{LuksTypeLuks1, LuksTypeLuks2}))
+	}
+
+	if options.Offset != "" {
+		if _, err := strconv.ParseUint(options.Offset, 10, 64); err != nil {
+			errs.Append("Offset", errors.Errorf("offset %q is not a number of sectors", options.Offset))
+		}
+	}
+
+	pbkdf := options.PBKDF
+	switch pbkdf {
+	case "", PBKDFTypePbkdf2:
+	case PBKDFTypeArgon2i, PBKDFTypeArgon2id:
+		if options.Type == LuksTypeLuks1 {
+			errs.Append("PBKDF", errors.Errorf("PBKDF %v is not supported by %v", pbkdf, LuksTypeLuks1))
+		}
+	default:
+		errs.Append("PBKDF", errors.Errorf("unknown PBKDF %q, supported: %v", pbkdf, []string{PBKDFTypePbkdf2, PBKDFTypeArgon2i, PBKDFTypeArgon2id}))
+	}
+
+	if options.PBKDFForceIterations != "" {
+		minIterations := uint64(luksArgon2MinIterations)
+		if pbkdf == PBKDFTypePbkdf2 {
+			minIterations = luksPBKDF2MinIterations
+		}
+		iterations, err := strconv.ParseUint(options.PBKDFForceIterations, 10, 64)
+		switch {
+		case err != nil:
+			errs.Append("PBKDFForceIterations", errors.Errorf("iterations %q is not a number", options.PBKDFForceIterations))
+		case iterations < minIterations || iterations > luksPBKDFMaxIterations:
+			errs.Append("PBKDFForceIterations", errors.Errorf("iterations %v must be between %v and %v", iterations, minIterations, uint64(luksPBKDFMaxIterations)))
+		}
+	}
+
+	if options.PBKDFMemory != "" {
+		memory, err := strconv.ParseUint(options.PBKDFMemory, 10, 64)
+		switch {
+		case pbkdf == PBKDFTypePbkdf2:
+			errs.Append("PBKDFMemory", errors.Errorf("memory cost is not applicable to PBKDF %v", pbkdf))
+		case err != nil:
+			errs.Append("PBKDFMemory", errors.Errorf("memory cost %q is not a number of KiB", options.PBKDFMemory))
+		case memory < luksArgon2MinMemoryKiB || memory > luksArgon2MaxMemoryKiB:
+			errs.Append("PBKDFMemory", errors.Errorf("memory cost %v KiB must be between %v and %v KiB", memory, luksArgon2MinMemoryKiB, luksArgon2MaxMemoryKiB))
+		}
+	}
+
+	if options.Integrity != "" {
+		if _, ok := types.LuksIntegrityTagSizes[options.Integrity]; !ok {
+			integrities := make([]string, 0, len(types.LuksIntegrityTagSizes))
+			for integrity := range types.LuksIntegrityTagSizes {
+				integrities = append(integrities, integrity)
+			}
+			sort.Strings(integrities)
+			errs.Append("Integrity", errors.Errorf("unknown integrity %q, supported: %v", options.Integrity, integrities))
+		} else if options.Type == LuksTypeLuks1 {
+			errs.Append("Integrity", errors.Errorf("integrity is not supported by %v", LuksTypeLuks1))
+		} else if options.Integrity == types.LuksIntegrityAead && !luksAeadCipherModeRegexp.MatchString(options.KeyCipher) {
+			// The cipher, including the default aes-xts-plain64, must authenticate the data.
+			errs.Append("Integrity", errors.Errorf("integrity %v requires an authenticated cipher such as aes-gcm-random", options.Integrity))
+		}
+	}
+
+	return errs
 }
 
 // LuksOpen runs cryptsetup luksOpen with the given passphrase and
@@ -37,10 +226,20 @@ func (nsexec *Executor) LuksClose(volume string, timeout time.Duration) (stdout
 
 // LuksFormat runs `cryptsetup luksFormat` on the specified device using the
 // provided passphrase and optional LuksFormatOptions (cipher, hash, key size, PBKDF settings, etc.).
+// The options are validated against the detected cryptsetup capabilities first.
 func (nsexec *Executor) LuksFormat(devicePath, passphrase string, options *LuksFormatOptions, timeout time.Duration) (stdout string, err error) {
+	if err := nsexec.validateLuksFormatOptions(options); err != nil {
+		return "", err
+	}
+
+	luksType := LuksTypeLuks2
+	if options != nil && options.Type != "" {
+		luksType = options.Type
+	}
+
 	args := []string{
 		"-q", "luksFormat",
-		"--type", "luks2",
+		"--type", luksType,
 	}
 	if options != nil {
 		if options.KeyCipher != "" {
@@ -61,6 +260,15 @@ func (nsexec *Executor) LuksFormat(devicePath, passphrase string, options *LuksF
 		if options.PBKDFMemory != "" {
 			args = append(args, "--pbkdf-memory", options.PBKDFMemory)
 		}
+		if options.Integrity != "" {
+			args = append(args, "--integrity", options.Integrity)
+		}
+		if options.Offset != "" {
+			args = append(args, "--offset", options.Offset)
+		}
+		if options.Header != "" {
+			args = append(args, "--header", options.Header)
+		}
 	}
 
 	args = append(args, devicePath, "-d", "-")
@@ -99,6 +307,13 @@ func (nsexec *Executor) IsLuks(devicePath string, timeout time.Duration) (bool,
 }
 
 func (nsexec *Executor) GetLuksBackendSize(size int64, encrypted bool, cliAPIVersion int) (int64, error) {
+	return nsexec.GetLuksBackendSizeWithOptions(size, encrypted, cliAPIVersion, nil)
+}
+
+// GetLuksBackendSizeWithOptions returns the backend size needed for a volume of
+// the given size formatted with the LuksFormatOptions. It includes the
+// dm-integrity metadata if the options enable integrity protection.
+func (nsexec *Executor) GetLuksBackendSizeWithOptions(size int64, encrypted bool, cliAPIVersion int, options *LuksFormatOptions) (int64, error) {
 	if !encrypted {
 		return size, nil
 	}
@@ -111,7 +326,10 @@ func (nsexec *Executor) GetLuksBackendSize(size int64, encrypted bool, cliAPIVer
 		return size, nil
 	}
 
-	return types.GetBackendSize(size, encrypted, cliAPIVersion), nil
+	if options == nil {
+		return types.GetBackendSize(size, encrypted, cliAPIVersion), nil
+	}
+	return types.GetBackendSizeWithIntegrity(size, encrypted, cliAPIVersion, options.KeyCipher, options.Integrity), nil
 }
 
 func (nsexec *Executor) IsLuksFixed16MiBHeaderSize() (bool, error) {
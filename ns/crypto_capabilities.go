@@ -0,0 +1,238 @@
+package ns
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/multierr"
+	"github.com/longhorn/go-common-libs/sys"
+	"github.com/longhorn/go-common-libs/utils"
+)
+
+const (
+	// LUKS2 and the argon2 PBKDFs were introduced in cryptsetup 2.0.0. See: https://www.kernel.org/pub/linux/utils/cryptsetup/v2.0/v2.0.0-ReleaseNotes.
+	cryptsetupMinVersionLuks2 = "2.0.0"
+	// LUKS2 online re-encryption was introduced in cryptsetup 2.2.0. See: https://www.kernel.org/pub/linux/utils/cryptsetup/v2.2/v2.2.0-ReleaseNotes.
+	cryptsetupMinVersionReencrypt = "2.2.0"
+)
+
+const (
+	PBKDFTypePbkdf2   = "pbkdf2"
+	PBKDFTypeArgon2i  = "argon2i"
+	PBKDFTypeArgon2id = "argon2id"
+)
+
+// CryptsetupCapabilities describes what the cryptsetup binary and the kernel of
+// the namespace the Executor runs commands in are able to do.
+type CryptsetupCapabilities struct {
+	Version              string   // Version of the cryptsetup binary (e.g. 2.4.3).
+	PBKDFs               []string // Supported PBKDF types (e.g. pbkdf2, argon2i, argon2id).
+	IsLuks2Supported     bool     // Whether LUKS2 devices can be formatted.
+	IsReencryptSupported bool     // Whether LUKS2 devices can be re-encrypted.
+	IsTokenSupported     bool     // Whether LUKS2 tokens can be managed.
+
+	// KernelCiphers are the names of the cipher algorithms registered in the
+	// kernel, as listed in /proc/crypto. It is empty if /proc/crypto cannot be read.
+	KernelCiphers []string
+}
+
+// GetCryptsetupCapabilities detects the cryptsetup capabilities. The result is
+// cached in the Executor once it is detected successfully.
+func (nsexec *Executor) GetCryptsetupCapabilities() (*CryptsetupCapabilities, error) {
+	nsexec.cryptsetupCapabilitiesMu.Lock()
+	defer nsexec.cryptsetupCapabilitiesMu.Unlock()
+
+	if nsexec.cryptsetupCapabilities != nil {
+		return nsexec.cryptsetupCapabilities, nil
+	}
+
+	capabilities, err := nsexec.detectCryptsetupCapabilities()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect cryptsetup capabilities")
+	}
+
+	nsexec.cryptsetupCapabilities = capabilities
+	return capabilities, nil
+}
+
+func (nsexec *Executor) detectCryptsetupCapabilities() (*CryptsetupCapabilities, error) {
+	version, err := nsexec.getCryptsetupVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	help, err := nsexec.Cryptsetup([]string{"--help"}, time.Minute)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get cryptsetup help on host")
+	}
+
+	capabilities, err := parseCryptsetupCapabilities(version, help)
+	if err != nil {
+		return nil, err
+	}
+
+	// The kernel crypto algorithms are global, so reading them from the proc
+	// directory of the Executor gives the same view as the target namespace.
+	algorithms, err := sys.GetKernelCryptoAlgorithms(nsexec.processDir)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get kernel crypto algorithms, skipping cipher validation")
+		return capabilities, nil
+	}
+	for _, algorithm := range algorithms {
+		if isKernelCipherType(algorithm.Type) && !utils.Contains(capabilities.KernelCiphers, algorithm.Name) {
+			capabilities.KernelCiphers = append(capabilities.KernelCiphers, algorithm.Name)
+		}
+	}
+	return capabilities, nil
+}
+
+// isKernelCipherType checks if the /proc/crypto algorithm type is a cipher.
+func isKernelCipherType(algorithmType string) bool {
+	switch algorithmType {
+	case "cipher", "skcipher", "lskcipher", "aead":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseCryptsetupCapabilities builds the capabilities from the cryptsetup
+// version and the output of `cryptsetup --help`, which lists the supported
+// actions and the compiled-in defaults, e.g.:
+//
+//	<action> is one of:
+//		open <device> [--type <type>] [<name>] - open device as <name>
+//		reencrypt <device> - reencrypt LUKS2 device
+//		token <add|remove|import|export> <device> - manipulate tokens
+//	...
+//	Default PBKDF for LUKS2: argon2id
+func parseCryptsetupCapabilities(version, help string) (*CryptsetupCapabilities, error) {
+	capabilities := &CryptsetupCapabilities{
+		Version: version,
+		PBKDFs:  []string{PBKDFTypePbkdf2},
+	}
+
+	isLuks2Version, err := utils.IsVersionAtLeast(version, cryptsetupMinVersionLuks2)
+	if err != nil {
+		return nil, err
+	}
+	isReencryptVersion, err := utils.IsVersionAtLeast(version, cryptsetupMinVersionReencrypt)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := map[string]bool{}
+	defaultLuks2PBKDF := ""
+	inActions := false
+	for _, line := range strings.Split(help, "\n") {
+		if strings.HasPrefix(line, "<action> is one of:") {
+			inActions = true
+			continue
+		}
+		if inActions {
+			if !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, " ") {
+				inActions = false
+			} else if fields := strings.Fields(line); len(fields) > 0 {
+				actions[fields[0]] = true
+			}
+		}
+		if value, found := strings.CutPrefix(line, "Default PBKDF for LUKS2:"); found {
+			defaultLuks2PBKDF, _, _ = strings.Cut(strings.TrimSpace(value), ",")
+		}
+	}
+
+	capabilities.IsLuks2Supported = isLuks2Version
+	capabilities.IsReencryptSupported = isReencryptVersion && actions["reencrypt"]
+	capabilities.IsTokenSupported = isLuks2Version && actions["token"]
+
+	// The argon2 PBKDFs are not available when cryptsetup is built without
+	// them or runs in FIPS mode, in which case pbkdf2 is the LUKS2 default.
+	if isLuks2Version && defaultLuks2PBKDF != PBKDFTypePbkdf2 {
+		capabilities.PBKDFs = append(capabilities.PBKDFs, PBKDFTypeArgon2i, PBKDFTypeArgon2id)
+	}
+
+	return capabilities, nil
+}
+
+// IsKernelCipherAvailable checks if the cipher of a cryptsetup cipher
+// specification (e.g. aes-xts-plain64 or capi:xts(aes)-plain64) is registered
+// in the kernel. It returns true if the kernel ciphers are unknown.
+// A cipher that is not registered may still be usable, because the modules and
+// template instances such as xts(serpent) are only registered on first use.
+func (c *CryptsetupCapabilities) IsKernelCipherAvailable(cipherSpec string) bool {
+	return isKernelCipherAvailable(c.KernelCiphers, cipherSpec)
+}
+
+func isKernelCipherAvailable(kernelCiphers []string, cipherSpec string) bool {
+	if len(kernelCiphers) == 0 {
+		return true
+	}
+
+	var candidates []string
+	if capi, found := strings.CutPrefix(cipherSpec, "capi:"); found {
+		name, _, _ := strings.Cut(capi, "-")
+		base := name
+		if i := strings.LastIndex(base, "("); i >= 0 {
+			base = strings.TrimRight(base[i+1:], ")")
+		}
+		candidates = append(candidates, name, base)
+	} else {
+		parts := strings.Split(cipherSpec, "-")
+		if len(parts) > 1 {
+			candidates = append(candidates, parts[1]+"("+parts[0]+")")
+		}
+		candidates = append(candidates, parts[0])
+	}
+
+	for _, candidate := range candidates {
+		if utils.Contains(kernelCiphers, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateLuksFormatOptions checks if the LuksFormatOptions are valid and can
+// be used with these capabilities. The cipher is not checked against the kernel
+// ciphers, see IsKernelCipherAvailable. The returned error is a
+// multierr.MultiError keyed by the field name.
+func (c *CryptsetupCapabilities) ValidateLuksFormatOptions(options *LuksFormatOptions) error {
+	errs := multierr.NewMultiError()
+
+	if !c.IsLuks2Supported && (options == nil || options.Type != LuksTypeLuks1) {
+		errs.Append("Type", errors.Errorf("LUKS2 is not supported by cryptsetup %v", c.Version))
+	}
+
+	if options != nil {
+		optionErrs := options.validate()
+		if options.PBKDF != "" && len(optionErrs["PBKDF"]) == 0 && !utils.Contains(c.PBKDFs, options.PBKDF) {
+			optionErrs.Append("PBKDF", errors.Errorf("PBKDF %q is not supported by cryptsetup %v, supported: %v", options.PBKDF, c.Version, c.PBKDFs))
+		}
+		errs.AppendMultiError(optionErrs)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateLuksFormatOptions validates the LuksFormatOptions against the
+// detected cryptsetup capabilities. The validation is skipped if the
+// capabilities cannot be detected, leaving it to cryptsetup to reject them.
+func (nsexec *Executor) validateLuksFormatOptions(options *LuksFormatOptions) error {
+	capabilities, err := nsexec.GetCryptsetupCapabilities()
+	if err != nil {
+		logrus.WithError(err).Warn("Skipping LUKS format options validation")
+		return nil
+	}
+
+	if options != nil && options.KeyCipher != "" && !capabilities.IsKernelCipherAvailable(options.KeyCipher) {
+		logrus.Warnf("Cipher %v is not registered in /proc/crypto yet, the kernel may load it on first use", options.KeyCipher)
+	}
+
+	return errors.Wrap(capabilities.ValidateLuksFormatOptions(options), "invalid LUKS format options")
+}
@@ -0,0 +1,213 @@
+package ns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/multierr"
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const mockCryptsetupHelp = `cryptsetup 2.4.3 flags: UDEV BLKID KEYRING FIPS KERNEL_CAPI PWQUALITY
+Usage: cryptsetup [OPTION...] <action> <action-specific>
+
+<action> is one of:
+	open <device> [--type <type>] [<name>] - open device as <name>
+	close <name> - close device (remove mapping)
+	luksFormat <device> [<new key file>] - formats a LUKS device
+	reencrypt <device> - reencrypt LUKS2 device
+	token <add|remove|import|export> <device> - Manipulate LUKS2 tokens
+
+You can also use old <action> syntax aliases:
+
+Default compiled-in metadata format is LUKS2 (for luksFormat action).
+
+Default PBKDF for LUKS1: pbkdf2, iteration time: 2000 (ms)
+Default PBKDF for LUKS2: argon2id
+	Iteration time: 2000, Memory required: 1048576kB, Parallel threads: 4
+`
+
+func TestParseCryptsetupCapabilities(t *testing.T) {
+	type testCase struct {
+		version string
+		help    string
+
+		expected    *CryptsetupCapabilities
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"cryptsetup 2.4.3": {
+			version: "2.4.3",
+			help:    mockCryptsetupHelp,
+			expected: &CryptsetupCapabilities{
+				Version:              "2.4.3",
+				PBKDFs:               []string{PBKDFTypePbkdf2, PBKDFTypeArgon2i, PBKDFTypeArgon2id},
+				IsLuks2Supported:     true,
+				IsReencryptSupported: true,
+				IsTokenSupported:     true,
+			},
+		},
+		"cryptsetup 1.7.5": {
+			version: "1.7.5",
+			help:    "<action> is one of:\n\topen <device> - open device\n",
+			expected: &CryptsetupCapabilities{
+				Version: "1.7.5",
+				PBKDFs:  []string{PBKDFTypePbkdf2},
+			},
+		},
+		"FIPS mode": {
+			version: "2.6.1",
+			help:    "Default PBKDF for LUKS2: pbkdf2, iteration time: 2000 (ms)\n",
+			expected: &CryptsetupCapabilities{
+				Version:          "2.6.1",
+				PBKDFs:           []string{PBKDFTypePbkdf2},
+				IsLuks2Supported: true,
+			},
+		},
+		"Invalid version": {
+			version:     "invalid",
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			capabilities, err := parseCryptsetupCapabilities(testCase.version, testCase.help)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, capabilities, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestIsKernelCipherAvailable(t *testing.T) {
+	type testCase struct {
+		kernelCiphers []string
+		cipherSpec    string
+
+		expected bool
+	}
+	testCases := map[string]testCase{
+		"Unknown kernel ciphers": {
+			cipherSpec: "serpent-xts-plain64",
+			expected:   true,
+		},
+		"Base cipher registered": {
+			kernelCiphers: []string{"aes", "cbc(aes)"},
+			cipherSpec:    "aes-xts-plain64",
+			expected:      true,
+		},
+		"Mode instance registered": {
+			kernelCiphers: []string{"xts(serpent)"},
+			cipherSpec:    "serpent-xts-plain64",
+			expected:      true,
+		},
+		"Kernel crypto API format": {
+			kernelCiphers: []string{"aes"},
+			cipherSpec:    "capi:xts(aes)-plain64",
+			expected:      true,
+		},
+		"Cipher not registered": {
+			kernelCiphers: []string{"aes"},
+			cipherSpec:    "twofish-xts-plain64",
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			capabilities := &CryptsetupCapabilities{KernelCiphers: testCase.kernelCiphers}
+			assert.Equal(t, testCase.expected, capabilities.IsKernelCipherAvailable(testCase.cipherSpec), Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestValidateLuksFormatOptionsWithCapabilities(t *testing.T) {
+	capabilities := &CryptsetupCapabilities{
+		Version:          "2.6.1",
+		PBKDFs:           []string{PBKDFTypePbkdf2},
+		IsLuks2Supported: true,
+		KernelCiphers:    []string{"aes"},
+	}
+
+	type testCase struct {
+		options *LuksFormatOptions
+
+		expectedReasons []string
+	}
+	testCases := map[string]testCase{
+		"Without options": {},
+		"Valid options": {
+			options: &LuksFormatOptions{KeyCipher: "aes-xts-plain64", PBKDF: PBKDFTypePbkdf2},
+		},
+		"Unsupported PBKDF": {
+			options:         &LuksFormatOptions{KeyCipher: "aes-xts-plain64", PBKDF: PBKDFTypeArgon2id},
+			expectedReasons: []string{"PBKDF"},
+		},
+		"Cipher not registered yet": {
+			options: &LuksFormatOptions{KeyCipher: "twofish-xts-plain64", PBKDF: PBKDFTypePbkdf2},
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			err := capabilities.ValidateLuksFormatOptions(testCase.options)
+			if len(testCase.expectedReasons) == 0 {
+				assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			multiErr, ok := err.(multierr.MultiError)
+			assert.True(t, ok, Commentf(test.ErrResultFmt, testName))
+			for _, reason := range testCase.expectedReasons {
+				assert.NotEmpty(t, multiErr.ErrorByReason(reason), Commentf(test.ErrResultFmt, testName))
+			}
+		})
+	}
+}
+
+func TestGetCryptsetupCapabilities(t *testing.T) {
+	namespaces := []types.Namespace{types.NamespaceMnt, types.NamespaceIpc}
+	nsexec, err := NewNamespaceExecutor(types.ProcessNone, types.HostProcDirectory, namespaces)
+	assert.NoError(t, err)
+
+	procDir := t.TempDir()
+	err = os.WriteFile(filepath.Join(procDir, types.SysProcCrypto), []byte("name : aes\ntype : cipher\n\nname : sha256\ntype : shash\n"), 0644)
+	assert.NoError(t, err)
+	nsexec.processDir = procDir
+
+	fakeExecutor := &fake.Executor{
+		Results: []fake.ExecutorResult{
+			{Output: "cryptsetup 2.4.3\n"},
+			{Output: mockCryptsetupHelp},
+		},
+	}
+	nsexec.executor = fakeExecutor
+
+	capabilities, err := nsexec.GetCryptsetupCapabilities()
+	assert.NoError(t, err)
+	assert.Equal(t, "2.4.3", capabilities.Version)
+	assert.Equal(t, []string{"aes"}, capabilities.KernelCiphers)
+
+	// The capabilities are cached, so no more commands are executed.
+	_, err = nsexec.GetCryptsetupCapabilities()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fakeExecutor.GetCallCount())
+
+	// The options are validated before formatting.
+	_, err = nsexec.LuksFormat("/dev/sda", "", &LuksFormatOptions{KeySize: "257"}, types.LuksTimeout)
+	assert.Error(t, err)
+	assert.Equal(t, 2, fakeExecutor.GetCallCount())
+
+	output, err := nsexec.LuksFormat("/dev/sda", "", &LuksFormatOptions{KeyCipher: "aes-xts-plain64"}, types.LuksTimeout)
+	assert.NoError(t, err)
+	assert.Equal(t, "output", output)
+
+	// A cipher that is not registered yet is left to the kernel to load.
+	_, err = nsexec.LuksFormat("/dev/sda", "", &LuksFormatOptions{KeyCipher: "twofish-xts-plain64"}, types.LuksTimeout)
+	assert.NoError(t, err)
+}
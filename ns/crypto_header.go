@@ -0,0 +1,289 @@
+package ns
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const (
+	luksSectorSize = 512
+
+	// Default data offset of a LUKS1 device formatted by cryptsetup, aligned to 1 MiB.
+	luks1DefaultDataOffset = 2 * 1024 * 1024
+)
+
+// LuksHeaderInfo describes the data segment of a LUKS device.
+type LuksHeaderInfo struct {
+	Version    int    // LUKS version, 1 or 2.
+	DataOffset int64  // Offset of the data segment in bytes.
+	SectorSize int64  // Encryption sector size in bytes.
+	Cipher     string // Cipher of the data segment (e.g. aes-xts-plain64).
+	Integrity  string // Integrity algorithm in the cryptsetup --integrity notation (e.g. hmac-sha256), or empty.
+}
+
+// GetBackendSize returns the size of the backend device needed to expose
+// volumeSize bytes of decrypted data with this header layout.
+func (info *LuksHeaderInfo) GetBackendSize(volumeSize int64) int64 {
+	if volumeSize <= 0 {
+		return volumeSize
+	}
+	return volumeSize + info.DataOffset + types.GetLuksIntegrityOverhead(volumeSize, info.Cipher, info.Integrity)
+}
+
+// GetHeaderInfo returns the header layout a device formatted by LuksFormat
+// with these options will have, without formatting any device. Nil options
+// are the cryptsetup defaults.
+func (options *LuksFormatOptions) GetHeaderInfo() (*LuksHeaderInfo, error) {
+	if options == nil {
+		options = &LuksFormatOptions{}
+	}
+
+	info := &LuksHeaderInfo{
+		Version:    2,
+		DataOffset: types.Luks2EncryptionHeaderSize,
+		SectorSize: luksSectorSize,
+		Cipher:     options.KeyCipher,
+		Integrity:  options.Integrity,
+	}
+
+	if options.Type == LuksTypeLuks1 {
+		info.Version = 1
+		info.DataOffset = luks1DefaultDataOffset
+	}
+
+	// A detached header leaves the whole device to the data.
+	if options.Header != "" {
+		info.DataOffset = 0
+	}
+
+	if options.Offset != "" {
+		offset, err := strconv.ParseInt(options.Offset, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid offset %q", options.Offset)
+		}
+		info.DataOffset = offset * luksSectorSize
+	}
+
+	return info, nil
+}
+
+// luks2Metadata is the part of the LUKS2 JSON metadata describing the data segments.
+type luks2Metadata struct {
+	Segments map[string]struct {
+		Type       string `json:"type"`
+		Offset     string `json:"offset"`
+		Encryption string `json:"encryption"`
+		SectorSize int64  `json:"sector_size"`
+		Integrity  *struct {
+			Type string `json:"type"`
+		} `json:"integrity"`
+	} `json:"segments"`
+}
+
+// ParseLuks2JSONMetadata parses the header layout from the LUKS2 JSON metadata
+// printed by `cryptsetup luksDump --dump-json-metadata`.
+func ParseLuks2JSONMetadata(metadata string) (*LuksHeaderInfo, error) {
+	var parsed luks2Metadata
+	if err := json.Unmarshal([]byte(metadata), &parsed); err != nil {
+		return nil, errors.Wrap(err, "failed to parse LUKS2 JSON metadata")
+	}
+
+	// The segments are keyed by their index, the first crypt segment is the data segment.
+	keys := make([]int, 0, len(parsed.Segments))
+	for key := range parsed.Segments {
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid LUKS2 segment key %q", key)
+		}
+		keys = append(keys, index)
+	}
+	sort.Ints(keys)
+
+	for _, key := range keys {
+		segment := parsed.Segments[strconv.Itoa(key)]
+		if segment.Type != "crypt" {
+			continue
+		}
+
+		offset, err := strconv.ParseInt(segment.Offset, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid LUKS2 segment offset %q", segment.Offset)
+		}
+
+		info := &LuksHeaderInfo{
+			Version:    2,
+			DataOffset: offset,
+			SectorSize: segment.SectorSize,
+			Cipher:     segment.Encryption,
+		}
+		if segment.Integrity != nil {
+			info.Integrity = normalizeLuksIntegrity(segment.Integrity.Type)
+		}
+		return info, nil
+	}
+
+	return nil, errors.New("failed to find LUKS2 crypt segment in JSON metadata")
+}
+
+// ParseLuksDump parses the header layout from the text printed by `cryptsetup luksDump`.
+// It supports both LUKS1 and LUKS2 headers, e.g.:
+//
+//	Version:       	1
+//	Cipher name:   	aes
+//	Cipher mode:   	xts-plain64
+//	Payload offset:	4096
+//
+//	Version:       	2
+//	Data segments:
+//	  0: crypt
+//		offset: 16777216 [bytes]
+//		cipher: aes-xts-plain64
+//		sector: 512 [bytes]
+//		integrity: hmac(sha256)
+func ParseLuksDump(dump string) (*LuksHeaderInfo, error) {
+	info := &LuksHeaderInfo{SectorSize: luksSectorSize}
+	var cipherName, cipherMode string
+	hasOffset := false
+	inSegments := false
+	segmentCount := 0
+
+	parseBytes := func(value string) (int64, error) {
+		value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "[bytes]"))
+		return strconv.ParseInt(value, 10, 64)
+	}
+
+	for _, line := range strings.Split(dump, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		// Section headers of LUKS2 dumps are not indented.
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && strings.HasSuffix(trimmed, ":") {
+			inSegments = trimmed == "Data segments:"
+			continue
+		}
+
+		key, value, found := strings.Cut(trimmed, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if inSegments {
+			if _, err := strconv.Atoi(key); err == nil {
+				// Only the first segment is the data segment.
+				segmentCount++
+				continue
+			}
+			if segmentCount != 1 {
+				continue
+			}
+
+			var err error
+			switch key {
+			case "offset":
+				info.DataOffset, err = parseBytes(value)
+				hasOffset = err == nil
+			case "cipher":
+				info.Cipher = value
+			case "sector":
+				info.SectorSize, err = parseBytes(value)
+			case "integrity":
+				info.Integrity = normalizeLuksIntegrity(value)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid LUKS2 segment %v %q", key, value)
+			}
+			continue
+		}
+
+		switch key {
+		case "Version":
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid LUKS version %q", value)
+			}
+			info.Version = version
+		case "Cipher name":
+			cipherName = value
+		case "Cipher mode":
+			cipherMode = value
+		case "Payload offset":
+			sectors, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid LUKS1 payload offset %q", value)
+			}
+			info.DataOffset = sectors * luksSectorSize
+			hasOffset = true
+		}
+	}
+
+	if info.Version == 0 {
+		return nil, errors.New("failed to find LUKS version in luksDump output")
+	}
+	if !hasOffset {
+		return nil, errors.New("failed to find data offset in luksDump output")
+	}
+	if info.Version == 1 && cipherName != "" {
+		info.Cipher = cipherName + "-" + cipherMode
+	}
+	return info, nil
+}
+
+// normalizeLuksIntegrity converts the kernel crypto API notation of the
+// integrity stored in the header (e.g. hmac(sha256)) to the cryptsetup
+// --integrity notation (e.g. hmac-sha256).
+func normalizeLuksIntegrity(integrity string) string {
+	integrity = strings.TrimSpace(integrity)
+	if integrity == "" || integrity == "(none)" || integrity == "none" {
+		return ""
+	}
+	if name, inner, found := strings.Cut(integrity, "("); found {
+		return name + "-" + strings.TrimSuffix(inner, ")")
+	}
+	return integrity
+}
+
+// GetLuksHeaderInfo reads the header layout of the LUKS device or detached
+// header at devicePath. It uses the LUKS2 JSON metadata when cryptsetup
+// supports dumping it, and falls back to the text dump otherwise.
+func (nsexec *Executor) GetLuksHeaderInfo(devicePath string, timeout time.Duration) (*LuksHeaderInfo, error) {
+	output, err := nsexec.Cryptsetup([]string{"luksDump", "--dump-json-metadata", devicePath}, timeout)
+	if err == nil {
+		info, parseErr := ParseLuks2JSONMetadata(output)
+		if parseErr == nil {
+			return info, nil
+		}
+		logrus.WithError(parseErr).Debugf("Failed to parse LUKS2 JSON metadata of %v, falling back to luksDump", devicePath)
+	} else {
+		// LUKS1 headers and cryptsetup older than 2.4.0 cannot dump the JSON metadata.
+		logrus.WithError(err).Debugf("Failed to dump LUKS2 JSON metadata of %v, falling back to luksDump", devicePath)
+	}
+
+	output, err = nsexec.Cryptsetup([]string{"luksDump", devicePath}, timeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dump LUKS header of %v", devicePath)
+	}
+	return ParseLuksDump(output)
+}
+
+// GetLuksBackendSizeFromHeader returns the backend size needed to expose
+// volumeSize bytes of decrypted data, using the actual header layout of the
+// LUKS device or detached header at devicePath.
+func (nsexec *Executor) GetLuksBackendSizeFromHeader(devicePath string, volumeSize int64, timeout time.Duration) (int64, error) {
+	info, err := nsexec.GetLuksHeaderInfo(devicePath, timeout)
+	if err != nil {
+		return 0, err
+	}
+	return info.GetBackendSize(volumeSize), nil
+}
@@ -0,0 +1,232 @@
+package ns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const mockLuks2JSONMetadata = `{
+  "keyslots": {},
+  "tokens": {},
+  "segments": {
+    "0": {
+      "type": "crypt",
+      "offset": "16777216",
+      "size": "dynamic",
+      "iv_tweak": "0",
+      "encryption": "aes-xts-plain64",
+      "sector_size": 512
+    }
+  },
+  "digests": {},
+  "config": {"json_size": "12288", "keyslots_size": "16744448"}
+}`
+
+const mockLuks2Dump = `LUKS header information
+Version:       	2
+Epoch:         	3
+Metadata area: 	16384 [bytes]
+Keyslots area: 	16744448 [bytes]
+UUID:          	6d1f0d4e-4ac8-4c61-9a3c-2bb3bfa8a3a1
+Label:         	(no label)
+Subsystem:     	(no subsystem)
+Flags:       	(no flags)
+
+Data segments:
+  0: crypt
+	offset: 33554432 [bytes]
+	length: (whole device)
+	cipher: aes-gcm-random
+	sector: 4096 [bytes]
+	integrity: aead
+
+Keyslots:
+  0: luks2
+	Key:        512 bits
+	Cipher:     aes-xts-plain64
+`
+
+const mockLuks1Dump = `LUKS header information for /dev/sda
+
+Version:       	1
+Cipher name:   	aes
+Cipher mode:   	xts-plain64
+Hash spec:     	sha256
+Payload offset:	4096
+MK bits:       	512
+`
+
+func TestParseLuks2JSONMetadata(t *testing.T) {
+	type testCase struct {
+		metadata string
+
+		expected    *LuksHeaderInfo
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Default header": {
+			metadata: mockLuks2JSONMetadata,
+			expected: &LuksHeaderInfo{Version: 2, DataOffset: 16777216, SectorSize: 512, Cipher: "aes-xts-plain64"},
+		},
+		"Integrity": {
+			metadata: `{"segments": {"0": {"type": "crypt", "offset": "4194304", "encryption": "aes-xts-random", "sector_size": 512, "integrity": {"type": "hmac(sha256)"}}}}`,
+			expected: &LuksHeaderInfo{Version: 2, DataOffset: 4194304, SectorSize: 512, Cipher: "aes-xts-random", Integrity: "hmac-sha256"},
+		},
+		"No crypt segment": {
+			metadata:    `{"segments": {"0": {"type": "linear", "offset": "0"}}}`,
+			expectError: true,
+		},
+		"Invalid JSON": {
+			metadata:    "not json",
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			info, err := ParseLuks2JSONMetadata(testCase.metadata)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, info, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestParseLuksDump(t *testing.T) {
+	type testCase struct {
+		dump string
+
+		expected    *LuksHeaderInfo
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"LUKS2 with custom offset and integrity": {
+			dump:     mockLuks2Dump,
+			expected: &LuksHeaderInfo{Version: 2, DataOffset: 33554432, SectorSize: 4096, Cipher: "aes-gcm-random", Integrity: "aead"},
+		},
+		"LUKS1": {
+			dump:     mockLuks1Dump,
+			expected: &LuksHeaderInfo{Version: 1, DataOffset: 2097152, SectorSize: 512, Cipher: "aes-xts-plain64"},
+		},
+		"Missing offset": {
+			dump:        "Version:       \t2\n",
+			expectError: true,
+		},
+		"Not a LUKS dump": {
+			dump:        "output",
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			info, err := ParseLuksDump(testCase.dump)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, info, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestLuksFormatOptionsGetHeaderInfo(t *testing.T) {
+	const volumeSize = int64(1024 * 1024 * 1024)
+
+	type testCase struct {
+		options *LuksFormatOptions
+
+		expectedOffset      int64
+		expectedBackendSize int64
+	}
+	testCases := map[string]testCase{
+		"Nil options": {
+			expectedOffset:      types.Luks2EncryptionHeaderSize,
+			expectedBackendSize: volumeSize + types.Luks2EncryptionHeaderSize,
+		},
+		"Default LUKS2": {
+			options:             &LuksFormatOptions{},
+			expectedOffset:      types.Luks2EncryptionHeaderSize,
+			expectedBackendSize: volumeSize + types.Luks2EncryptionHeaderSize,
+		},
+		"LUKS1": {
+			options:             &LuksFormatOptions{Type: LuksTypeLuks1},
+			expectedOffset:      2 * 1024 * 1024,
+			expectedBackendSize: volumeSize + 2*1024*1024,
+		},
+		"Custom offset": {
+			options:             &LuksFormatOptions{Offset: "65536"},
+			expectedOffset:      32 * 1024 * 1024,
+			expectedBackendSize: volumeSize + 32*1024*1024,
+		},
+		"Detached header": {
+			options:             &LuksFormatOptions{Header: "/var/lib/longhorn/header.img"},
+			expectedBackendSize: volumeSize,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			info, err := testCase.options.GetHeaderInfo()
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expectedOffset, info.DataOffset, Commentf(test.ErrResultFmt, testName))
+			assert.Equal(t, testCase.expectedBackendSize, info.GetBackendSize(volumeSize), Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestGetLuksBackendSizeFromHeader(t *testing.T) {
+	const volumeSize = int64(1024 * 1024 * 1024)
+
+	type testCase struct {
+		mockResults []fake.ExecutorResult
+
+		expected    int64
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"JSON metadata": {
+			mockResults: []fake.ExecutorResult{{Output: mockLuks2JSONMetadata}},
+			expected:    volumeSize + 16777216,
+		},
+		"Fallback to text dump": {
+			mockResults: []fake.ExecutorResult{
+				{Err: fmt.Errorf("unknown option --dump-json-metadata")},
+				{Output: mockLuks1Dump},
+			},
+			expected: volumeSize + 2097152,
+		},
+		"Failed to dump": {
+			mockResults: []fake.ExecutorResult{
+				{Err: fmt.Errorf("failed")},
+				{Err: fmt.Errorf("failed")},
+			},
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			namespaces := []types.Namespace{types.NamespaceMnt, types.NamespaceIpc}
+			nsexec, err := NewNamespaceExecutor(types.ProcessNone, types.HostProcDirectory, namespaces)
+			assert.Nil(t, err)
+
+			nsexec.executor = &fake.Executor{Results: testCase.mockResults}
+
+			size, err := nsexec.GetLuksBackendSizeFromHeader("/dev/sda", volumeSize, types.LuksTimeout)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, size, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
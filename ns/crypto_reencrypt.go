@@ -0,0 +1,218 @@
+package ns
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// LuksReencryptOptions defines optional parameters used when running cryptsetup reencrypt.
+type LuksReencryptOptions struct {
+	KeyCipher            string
+	KeyHash              string
+	KeySize              string
+	PBKDF                string
+	PBKDFForceIterations string // optional. PBKDF iteration count to force when deriving the new key
+	PBKDFMemory          string // optional. Memory cost for PBKDF in KiB
+
+	// ActiveName is the name of the active dm-crypt mapping of the device. When set,
+	// the device is re-encrypted online while it stays in use.
+	ActiveName string
+	// Resilience is the mode used to protect the hotzone against a crash
+	// (checksum, journal, datashift or none). Empty means the cryptsetup default.
+	Resilience string
+	// HotzoneSize is the maximal size of the area re-encrypted in one step (e.g. "64M").
+	HotzoneSize string
+	// ProgressFrequency is how often cryptsetup reports progress. Zero means every second.
+	ProgressFrequency time.Duration
+}
+
+// LuksReencryptProgress is a single progress report printed by cryptsetup reencrypt.
+type LuksReencryptProgress struct {
+	Percent             float64
+	ETA                 string
+	WrittenBytes        int64
+	SpeedBytesPerSecond int64
+}
+
+// luksReencryptProgressRegexp matches the progress lines printed by cryptsetup, e.g.:
+//
+//	Progress:  29.1%, ETA 00:37,  596 MiB written, speed 162.4 MiB/s
+var luksReencryptProgressRegexp = regexp.MustCompile(`Progress:\s*([\d.]+)%,\s*ETA\s+([\d:]+),\s*([\d.]+)\s*([KMGT]iB|B) written,\s*speed\s+([\d.]+)\s*([KMGT]iB|B)/s`)
+
+// ParseLuksReencryptProgress parses a progress line printed by cryptsetup reencrypt.
+// It returns false if the line is not a progress report.
+func ParseLuksReencryptProgress(line string) (progress LuksReencryptProgress, ok bool) {
+	matches := luksReencryptProgressRegexp.FindStringSubmatch(line)
+	if matches == nil {
+		return progress, false
+	}
+
+	percent, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return progress, false
+	}
+	written, err := parseCryptsetupSize(matches[3], matches[4])
+	if err != nil {
+		return progress, false
+	}
+	speed, err := parseCryptsetupSize(matches[5], matches[6])
+	if err != nil {
+		return progress, false
+	}
+
+	return LuksReencryptProgress{
+		Percent:             percent,
+		ETA:                 matches[2],
+		WrittenBytes:        written,
+		SpeedBytesPerSecond: speed,
+	}, true
+}
+
+// parseCryptsetupSize converts a size printed by cryptsetup with a binary unit to bytes.
+func parseCryptsetupSize(value, unit string) (int64, error) {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	multiplier := map[string]float64{
+		"B":   1,
+		"KiB": 1 << 10,
+		"MiB": 1 << 20,
+		"GiB": 1 << 30,
+		"TiB": 1 << 40,
+	}[unit]
+	if multiplier == 0 {
+		return 0, fmt.Errorf("unknown size unit %q", unit)
+	}
+	return int64(number * multiplier), nil
+}
+
+// buildLuksReencryptArgs returns the cryptsetup reencrypt arguments for the device.
+// The mode is either empty, "--init-only" or "--resume-only".
+func buildLuksReencryptArgs(devicePath, mode string, options *LuksReencryptOptions) []string {
+	args := []string{"-q", "reencrypt"}
+	if mode != "" {
+		args = append(args, mode)
+	}
+
+	progressFrequency := time.Second
+	if options != nil {
+		// The new key parameters are stored in the header when the re-encryption is initialized.
+		if mode != "--resume-only" {
+			if options.KeyCipher != "" {
+				args = append(args, "--cipher", options.KeyCipher)
+			}
+			if options.KeyHash != "" {
+				args = append(args, "--hash", options.KeyHash)
+			}
+			if options.KeySize != "" {
+				args = append(args, "--key-size", options.KeySize)
+			}
+			if options.PBKDF != "" {
+				args = append(args, "--pbkdf", options.PBKDF)
+			}
+			if options.PBKDFForceIterations != "" {
+				args = append(args, "--pbkdf-force-iterations", options.PBKDFForceIterations)
+			}
+			if options.PBKDFMemory != "" {
+				args = append(args, "--pbkdf-memory", options.PBKDFMemory)
+			}
+		}
+		if options.Resilience != "" {
+			args = append(args, "--resilience", options.Resilience)
+		}
+		if options.HotzoneSize != "" {
+			args = append(args, "--hotzone-size", options.HotzoneSize)
+		}
+		if options.ActiveName != "" {
+			args = append(args, "--active-name", options.ActiveName)
+		}
+		if options.ProgressFrequency > 0 {
+			progressFrequency = options.ProgressFrequency
+		}
+	}
+
+	if mode != "--init-only" {
+		seconds := int(progressFrequency.Round(time.Second).Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		args = append(args, "--progress-frequency", strconv.Itoa(seconds))
+	}
+
+	return append(args, devicePath, "-d", "-")
+}
+
+// LuksReencryptInit runs `cryptsetup reencrypt --init-only` on the specified device.
+// It only writes the re-encryption metadata into the LUKS2 header, the data is
+// re-encrypted later by LuksReencryptResume.
+func (nsexec *Executor) LuksReencryptInit(devicePath, passphrase string, options *LuksReencryptOptions, timeout time.Duration) (stdout string, err error) {
+	args := buildLuksReencryptArgs(devicePath, "--init-only", options)
+	return nsexec.CryptsetupWithPassphrase(passphrase, args, timeout)
+}
+
+// LuksReencrypt runs `cryptsetup reencrypt` on the specified device until the whole
+// device is re-encrypted with the key parameters in options. progressFn, if not nil,
+// is called with every progress report.
+//
+// Cancelling ctx interrupts cryptsetup, which stops after the current hotzone and
+// leaves the re-encryption state in the LUKS2 header. The operation can then be
+// continued with LuksReencryptResume.
+func (nsexec *Executor) LuksReencrypt(ctx context.Context, devicePath, passphrase string, options *LuksReencryptOptions, progressFn func(LuksReencryptProgress)) (stdout string, err error) {
+	args := buildLuksReencryptArgs(devicePath, "", options)
+	return nsexec.cryptsetupWithProgress(ctx, passphrase, args, progressFn)
+}
+
+// LuksReencryptResume resumes an initialized or interrupted re-encryption of the
+// specified device. See LuksReencrypt for the meaning of ctx and progressFn.
+func (nsexec *Executor) LuksReencryptResume(ctx context.Context, devicePath, passphrase string, options *LuksReencryptOptions, progressFn func(LuksReencryptProgress)) (stdout string, err error) {
+	args := buildLuksReencryptArgs(devicePath, "--resume-only", options)
+	return nsexec.cryptsetupWithProgress(ctx, passphrase, args, progressFn)
+}
+
+// IsLuksReencryptInProgress checks if the LUKS2 header of the device contains an
+// unfinished re-encryption that can be resumed with LuksReencryptResume.
+func (nsexec *Executor) IsLuksReencryptInProgress(devicePath string, timeout time.Duration) (bool, error) {
+	args := []string{"luksDump", devicePath}
+	output, err := nsexec.Cryptsetup(args, timeout)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to dump LUKS header of %v", devicePath)
+	}
+
+	// An unfinished re-encryption is recorded as a header requirement, e.g.:
+	//
+	//	Requirements:	online-reencrypt-v2
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if found && strings.TrimSpace(key) == "Requirements" && strings.Contains(value, "online-reencrypt") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cryptsetupWithProgress runs cryptsetup with passphrase and reports every
+// re-encryption progress line printed by it to progressFn.
+func (nsexec *Executor) cryptsetupWithProgress(ctx context.Context, passphrase string, args []string, progressFn func(LuksReencryptProgress)) (stdout string, err error) {
+	lineFn := func(line string) {
+		progress, ok := ParseLuksReencryptProgress(line)
+		if !ok {
+			logrus.Tracef("Ignoring cryptsetup output line %q", line)
+			return
+		}
+		if progressFn != nil {
+			progressFn(progress)
+		}
+	}
+	return nsexec.ExecuteWithStdinStream(ctx, nil, types.BinaryCryptsetup, args, passphrase, lineFn)
+}
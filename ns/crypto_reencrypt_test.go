@@ -0,0 +1,168 @@
+package ns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestParseLuksReencryptProgress(t *testing.T) {
+	type testCase struct {
+		line string
+
+		expected   LuksReencryptProgress
+		expectedOK bool
+	}
+	testCases := map[string]testCase{
+		"Progress line": {
+			line: "Progress:  29.1%, ETA 00:37,  596 MiB written, speed 162.4 MiB/s",
+			expected: LuksReencryptProgress{
+				Percent:             29.1,
+				ETA:                 "00:37",
+				WrittenBytes:        596 << 20,
+				SpeedBytesPerSecond: 170288742, // 162.4 MiB,
+			},
+			expectedOK: true,
+		},
+		"Finished": {
+			line: "Finished, time 01:02.345, 1024 MiB written, speed  16.4 MiB/s",
+		},
+		"Not a progress line": {
+			line: "Enter passphrase for key slot 0:",
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			progress, ok := ParseLuksReencryptProgress(testCase.line)
+			assert.Equal(t, testCase.expectedOK, ok, Commentf(test.ErrResultFmt, testName))
+			assert.Equal(t, testCase.expected, progress, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestBuildLuksReencryptArgs(t *testing.T) {
+	type testCase struct {
+		mode    string
+		options *LuksReencryptOptions
+
+		expected []string
+	}
+	testCases := map[string]testCase{
+		"Default": {
+			expected: []string{"-q", "reencrypt", "--progress-frequency", "1", "/dev/sda", "-d", "-"},
+		},
+		"Init with new cipher": {
+			mode: "--init-only",
+			options: &LuksReencryptOptions{
+				KeyCipher:  "aes-xts-plain64",
+				KeySize:    "512",
+				Resilience: "checksum",
+			},
+			expected: []string{"-q", "reencrypt", "--init-only", "--cipher", "aes-xts-plain64", "--key-size", "512", "--resilience", "checksum", "/dev/sda", "-d", "-"},
+		},
+		"Resume online ignores key parameters": {
+			mode: "--resume-only",
+			options: &LuksReencryptOptions{
+				KeyCipher:   "aes-xts-plain64",
+				ActiveName:  "vol",
+				HotzoneSize: "64M",
+			},
+			expected: []string{"-q", "reencrypt", "--resume-only", "--hotzone-size", "64M", "--active-name", "vol", "--progress-frequency", "1", "/dev/sda", "-d", "-"},
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			args := buildLuksReencryptArgs("/dev/sda", testCase.mode, testCase.options)
+			assert.Equal(t, testCase.expected, args, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestLuksReencrypt(t *testing.T) {
+	type testCase struct {
+		cancelled bool
+
+		mockOutput string
+
+		expectedProgress []LuksReencryptProgress
+		expectError      bool
+	}
+	testCases := map[string]testCase{
+		"Reports progress": {
+			mockOutput: "Progress:  50.0%, ETA 00:01,  512 MiB written, speed 512.0 MiB/s\nFinished, time 00:02.000, 1024 MiB written, speed 512.0 MiB/s\n",
+			expectedProgress: []LuksReencryptProgress{
+				{Percent: 50, ETA: "00:01", WrittenBytes: 512 << 20, SpeedBytesPerSecond: 512 << 20},
+			},
+		},
+		"Cancelled": {
+			cancelled:   true,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			namespaces := []types.Namespace{types.NamespaceMnt, types.NamespaceIpc}
+			nsexec, err := NewNamespaceExecutor(types.ProcessNone, types.HostProcDirectory, namespaces)
+			assert.Nil(t, err)
+
+			nsexec.executor = &fake.Executor{
+				Results: []fake.ExecutorResult{{Output: testCase.mockOutput}},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if testCase.cancelled {
+				cancel()
+			}
+
+			var progress []LuksReencryptProgress
+			_, err = nsexec.LuksReencrypt(ctx, "/dev/sda", "", nil, func(p LuksReencryptProgress) {
+				progress = append(progress, p)
+			})
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedProgress, progress, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestIsLuksReencryptInProgress(t *testing.T) {
+	type testCase struct {
+		mockOutput string
+
+		expected bool
+	}
+	testCases := map[string]testCase{
+		"In progress": {
+			mockOutput: "LUKS header information\nVersion:       \t2\nRequirements:\tonline-reencrypt-v2\n",
+			expected:   true,
+		},
+		"Not in progress": {
+			mockOutput: "LUKS header information\nVersion:       \t2\nFlags:       \t(no flags)\n",
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			namespaces := []types.Namespace{types.NamespaceMnt, types.NamespaceIpc}
+			nsexec, err := NewNamespaceExecutor(types.ProcessNone, types.HostProcDirectory, namespaces)
+			assert.Nil(t, err)
+
+			nsexec.executor = &fake.Executor{
+				Results: []fake.ExecutorResult{{Output: testCase.mockOutput}},
+			}
+
+			inProgress, err := nsexec.IsLuksReencryptInProgress("/dev/sda", types.LuksTimeout)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, inProgress, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
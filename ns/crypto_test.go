@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
 
+	"github.com/longhorn/go-common-libs/test"
 	"github.com/longhorn/go-common-libs/test/fake"
 	"github.com/longhorn/go-common-libs/types"
 )
@@ -110,3 +112,214 @@ func TestLuksStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestNewLuksFormatOptions(t *testing.T) {
+	type testCase struct {
+		parameters map[string]string
+
+		expected *LuksFormatOptions
+	}
+	testCases := map[string]testCase{
+		"Empty parameters": {
+			parameters: map[string]string{},
+			expected:   &LuksFormatOptions{},
+		},
+		"Normalized parameters": {
+			parameters: map[string]string{
+				types.CryptoKeyProvider:          "secret",
+				types.CryptoKeyCipher:            " AES-XTS-PLAIN64 ",
+				types.CryptoKeyHash:              "SHA256",
+				types.CryptoKeySize:              "256",
+				types.CryptoPBKDF:                "argon2id",
+				types.CryptoPBKDFForceIterations: "4",
+				types.CryptoPBKDFMemory:          "65536",
+			},
+			expected: &LuksFormatOptions{
+				KeyCipher:            "aes-xts-plain64",
+				KeyHash:              "sha256",
+				KeySize:              "256",
+				PBKDF:                "argon2id",
+				PBKDFForceIterations: "4",
+				PBKDFMemory:          "65536",
+			},
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			options := NewLuksFormatOptions(testCase.parameters)
+			assert.Equal(t, testCase.expected, options, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestLuksFormatOptionsValidate(t *testing.T) {
+	type testCase struct {
+		options *LuksFormatOptions
+
+		expectedReasons []string
+	}
+	testCases := map[string]testCase{
+		"Nil options": {},
+		"Empty options": {
+			options: &LuksFormatOptions{},
+		},
+		"Valid options": {
+			options: &LuksFormatOptions{
+				KeyCipher:            "aes-xts-plain64",
+				KeyHash:              "sha256",
+				KeySize:              "512",
+				PBKDF:                "argon2id",
+				PBKDFForceIterations: "4",
+				PBKDFMemory:          "65536",
+			},
+		},
+		"Invalid key size": {
+			options:         &LuksFormatOptions{KeySize: "256bit"},
+			expectedReasons: []string{"KeySize"},
+		},
+		"Key size not a multiple of 8": {
+			options:         &LuksFormatOptions{KeySize: "257"},
+			expectedReasons: []string{"KeySize"},
+		},
+		"Unknown hash and PBKDF": {
+			options: &LuksFormatOptions{
+				KeyCipher: "twofish-xts-plain64",
+				KeyHash:   "sha257",
+				PBKDF:     "scrypt",
+			},
+			expectedReasons: []string{"KeyHash", "PBKDF"},
+		},
+		"Malformed cipher": {
+			options:         &LuksFormatOptions{KeyCipher: "aes xts"},
+			expectedReasons: []string{"KeyCipher"},
+		},
+		"Too few PBKDF2 iterations": {
+			options:         &LuksFormatOptions{PBKDF: "pbkdf2", PBKDFForceIterations: "10"},
+			expectedReasons: []string{"PBKDFForceIterations"},
+		},
+		"Memory cost for PBKDF2": {
+			options:         &LuksFormatOptions{PBKDF: "pbkdf2", PBKDFMemory: "65536"},
+			expectedReasons: []string{"PBKDFMemory"},
+		},
+		"Unknown integrity": {
+			options:         &LuksFormatOptions{Integrity: "hmac-md5"},
+			expectedReasons: []string{"Integrity"},
+		},
+		"AEAD integrity without cipher": {
+			options:         &LuksFormatOptions{Integrity: "aead"},
+			expectedReasons: []string{"Integrity"},
+		},
+		"AEAD integrity with non-AEAD cipher": {
+			options:         &LuksFormatOptions{KeyCipher: "aes-xts-plain64", Integrity: "aead"},
+			expectedReasons: []string{"Integrity"},
+		},
+		"AEAD integrity": {
+			options: &LuksFormatOptions{KeyCipher: "aes-gcm-random", Integrity: "aead"},
+		},
+		"AEAD integrity with kernel crypto API cipher": {
+			options: &LuksFormatOptions{KeyCipher: "capi:rfc7539(chacha20,poly1305)-random", Integrity: "aead"},
+		},
+		"AEAD integrity with AEGIS": {
+			options: &LuksFormatOptions{KeyCipher: "aegis128-random", Integrity: "aead"},
+		},
+		"LUKS1 with argon2 and integrity": {
+			options:         &LuksFormatOptions{Type: LuksTypeLuks1, PBKDF: "argon2id", Integrity: "hmac-sha256"},
+			expectedReasons: []string{"PBKDF", "Integrity"},
+		},
+		"Invalid type and offset": {
+			options:         &LuksFormatOptions{Type: "luks3", Offset: "1M"},
+			expectedReasons: []string{"Type", "Offset"},
+		},
+		"Memory cost out of range": {
+			options:         &LuksFormatOptions{PBKDF: "argon2i", PBKDFMemory: "16"},
+			expectedReasons: []string{"PBKDFMemory"},
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			err := testCase.options.Validate()
+			assert.Equal(t, testCase.expectedReasons == nil, err == nil, Commentf(test.ErrErrorFmt, testName, err))
+
+			errs := testCase.options.validate()
+			reasons := []string{}
+			for reason := range errs {
+				reasons = append(reasons, reason)
+			}
+			expectedReasons := testCase.expectedReasons
+			if expectedReasons == nil {
+				expectedReasons = []string{}
+			}
+			assert.ElementsMatch(t, expectedReasons, reasons, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestLuksFormatOptionsValidateWithCapabilities(t *testing.T) {
+	capabilities := &CryptsetupCapabilities{KernelCiphers: []string{"aes", "xts(aes)"}}
+
+	err := (&LuksFormatOptions{KeyCipher: "aes-xts-plain64"}).ValidateWithCapabilities(capabilities)
+	assert.NoError(t, err)
+
+	err = (&LuksFormatOptions{KeyCipher: "serpent-xts-plain64"}).ValidateWithCapabilities(capabilities)
+	assert.Error(t, err)
+
+	err = (&LuksFormatOptions{KeyCipher: "serpent-xts-plain64"}).ValidateWithCapabilities(&CryptsetupCapabilities{})
+	assert.NoError(t, err, "unknown kernel ciphers should skip the check")
+
+	err = (&LuksFormatOptions{KeyCipher: "serpent-xts-plain64"}).ValidateWithCapabilities(nil)
+	assert.NoError(t, err, "nil capabilities should skip the check")
+
+	var options *LuksFormatOptions
+	err = options.ValidateWithCapabilities(capabilities)
+	assert.NoError(t, err)
+}
+
+func TestGetLuksBackendSizeWithOptions(t *testing.T) {
+	const volumeSize = int64(1024 * 1024 * 1024)
+
+	type testCase struct {
+		encrypted bool
+		options   *LuksFormatOptions
+
+		expected int64
+	}
+	testCases := map[string]testCase{
+		"Not encrypted": {
+			expected: volumeSize,
+		},
+		"Encrypted without integrity": {
+			encrypted: true,
+			expected:  volumeSize + types.Luks2EncryptionHeaderSize,
+		},
+		"Encrypted with AEAD integrity": {
+			encrypted: true,
+			options:   &LuksFormatOptions{KeyCipher: "aes-gcm-random", Integrity: types.LuksIntegrityAead},
+			// 64 areas of 16 MiB data with 28 bytes tags per 512 bytes sector, 8 MiB journal and 4 KiB superblock
+			expected: volumeSize + types.Luks2EncryptionHeaderSize + 64*917504 + 8*1024*1024 + 4096,
+		},
+		"Encrypted with HMAC integrity": {
+			encrypted: true,
+			options:   &LuksFormatOptions{KeyCipher: "aes-xts-random", Integrity: types.LuksIntegrityHmacSha256},
+			// 64 areas of 16 MiB data with 48 bytes tags per 512 bytes sector, 8 MiB journal and 4 KiB superblock
+			expected: volumeSize + types.Luks2EncryptionHeaderSize + 64*1572864 + 8*1024*1024 + 4096,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			namespaces := []types.Namespace{types.NamespaceMnt, types.NamespaceIpc}
+			nsexec, err := NewNamespaceExecutor(types.ProcessNone, types.HostProcDirectory, namespaces)
+			assert.Nil(t, err)
+
+			nsexec.executor = &fake.Executor{
+				Results: []fake.ExecutorResult{
+					{Output: "cryptsetup 2.4.3\n"},
+					{Output: ""},
+				},
+			}
+
+			size, err := nsexec.GetLuksBackendSizeWithOptions(volumeSize, testCase.encrypted, types.CliAPIVersionForSupportingExtendLuks2HeaderSize, testCase.options)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, size, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package ns
 
 import (
+	"context"
+	"fmt"
 	"path/filepath"
 	"regexp"
 	"sync"
@@ -32,6 +34,9 @@ type Executor struct {
 	processDir  string            // The process directory.
 
 	executor exec.ExecuteInterface // An interface for executing commands. This allows mocking for unit tests.
+
+	cryptsetupCapabilitiesMu sync.Mutex
+	cryptsetupCapabilities   *CryptsetupCapabilities // Cached once detected successfully.
 }
 
 // NewNamespaceExecutor creates a new namespace executor for the given process name,
@@ -119,6 +124,28 @@ func (nsexec *Executor) ExecuteWithStdinPipe(envs []string, binary string, args
 	})
 }
 
+// ExecuteWithStdinStream executes the command in the namespace with stdin and
+// calls lineFn for every line of output while the command is running. The
+// command is interrupted when ctx is done. It fails with ErrNotSupported if the
+// underlying executor cannot stream output.
+// If NsDirectory is empty, it will execute the command in the current namespace.
+func (nsexec *Executor) ExecuteWithStdinStream(ctx context.Context, envs []string, binary string, args []string, stdinString string, lineFn func(line string)) (string, error) {
+	streamExecutor, ok := nsexec.executor.(exec.StreamExecuteInterface)
+	if !ok {
+		return "", fmt.Errorf("streaming command output with executor %T %w", nsexec.executor, types.ErrNotSupported)
+	}
+
+	// The command may run for hours, so it must not hold the lock, or a
+	// refresh of a stale namespace directory would block all other commands.
+	return nsexec.retryOnStaleNsDir(func() (string, error) {
+		nsexec.mu.RLock()
+		cmdArgs := nsexec.prepareCommandArgs(binary, args, envs)
+		nsexec.mu.RUnlock()
+
+		return streamExecutor.ExecuteWithStdinStream(ctx, types.NsBinary, cmdArgs, stdinString, lineFn)
+	})
+}
+
 // staleNsDirPattern matches nsenter errors when the namespace directory no
 // longer exists, e.g.:
 //
@@ -139,12 +166,21 @@ func (nsexec *Executor) isNsDirStaleError(err error) bool {
 // becomes stale (e.g., iscsid restarted), nsenter fails with "No such file or
 // directory". This method retries after refreshing the namespace directory.
 func (nsexec *Executor) executeWithRetry(execFn func() (string, error)) (output string, err error) {
+	return nsexec.retryOnStaleNsDir(func() (string, error) {
+		nsexec.mu.RLock()
+		defer nsexec.mu.RUnlock()
+
+		return execFn()
+	})
+}
+
+// retryOnStaleNsDir retries execFn after refreshing the namespace directory
+// while it fails with a stale namespace directory. Unlike executeWithRetry, it
+// does not hold the lock while execFn runs.
+func (nsexec *Executor) retryOnStaleNsDir(execFn func() (string, error)) (output string, err error) {
 	retryErr := retry.Do(
 		func() error {
-			nsexec.mu.RLock()
 			output, err = execFn()
-			nsexec.mu.RUnlock()
-
 			return err
 		},
 		retry.Attempts(maxNsDirRefreshRetries),
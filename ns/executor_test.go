@@ -1,14 +1,17 @@
 package ns
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/longhorn/go-common-libs/exec"
 	"github.com/longhorn/go-common-libs/test/fake"
 	"github.com/longhorn/go-common-libs/types"
 )
@@ -296,3 +299,41 @@ func TestExecuteRetryOnStaleNsDir(t *testing.T) {
 		assert.Greater(t, mock.GetCallCount(), goroutines, "retries should produce more calls than goroutine count")
 	})
 }
+
+func TestExecuteWithStdinStream(t *testing.T) {
+	t.Run("streams without holding the lock", func(t *testing.T) {
+		mock := &fake.Executor{Results: []fake.ExecutorResult{{Output: "line1\nline2"}}}
+		nsexec := &Executor{
+			namespaces:  []types.Namespace{types.NamespaceMnt},
+			nsDirectory: "/host/proc/12345",
+			executor:    mock,
+		}
+
+		var lines []string
+		output, err := nsexec.ExecuteWithStdinStream(context.Background(), nil, "cryptsetup", []string{"reencrypt"}, "passphrase", func(line string) {
+			// A refresh of a stale namespace directory must not wait for the command.
+			locked := nsexec.mu.TryLock()
+			assert.True(t, locked, "lock is held while the command is running")
+			if locked {
+				nsexec.mu.Unlock()
+			}
+			lines = append(lines, line)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "line1\nline2", output)
+		assert.Equal(t, []string{"line1", "line2"}, lines)
+	})
+
+	t.Run("executor without streaming", func(t *testing.T) {
+		mock := &fake.Executor{}
+		nsexec := &Executor{
+			namespaces:  []types.Namespace{types.NamespaceMnt},
+			nsDirectory: "/host/proc/12345",
+			executor:    struct{ exec.ExecuteInterface }{mock},
+		}
+
+		_, err := nsexec.ExecuteWithStdinStream(context.Background(), nil, "cryptsetup", []string{"reencrypt"}, "passphrase", nil)
+		assert.True(t, errors.Is(err, types.ErrNotSupported))
+		assert.Equal(t, 0, mock.GetCallCount())
+	})
+}
@@ -1,6 +1,7 @@
 package ns
 
 import (
+	"context"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -21,6 +22,49 @@ func CopyDirectory(source, destination string, overWrite bool) (err error) {
 		err = errors.Wrapf(err, "failed to copy host content from %v to %v", source, destination)
 	}()
 
+	if err := checkCopyDirectoryPaths(source, destination); err != nil {
+		return err
+	}
+
+	fn := func() (interface{}, error) {
+		return "", io.CopyFiles(source, destination, overWrite)
+	}
+
+	_, err = RunFunc(fn, 0)
+	return err
+}
+
+// CopyDirectoryWithOptions switches to the host namespace and copies the
+// content from source to destination with io.CopyFilesWithOptions, which
+// supports cancellation, progress, bandwidth limit and checksums.
+// Top level directory is prohibited.
+func CopyDirectoryWithOptions(ctx context.Context, source, destination string, options *io.CopyOptions) (result *types.CopyResult, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to copy host content from %v to %v", source, destination)
+	}()
+
+	if err := checkCopyDirectoryPaths(source, destination); err != nil {
+		return nil, err
+	}
+
+	fn := func() (interface{}, error) {
+		return io.CopyFilesWithOptions(ctx, source, destination, options)
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ableToCast bool
+	result, ableToCast = rawResult.(*types.CopyResult)
+	if !ableToCast {
+		return nil, errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+	}
+	return result, nil
+}
+
+func checkCopyDirectoryPaths(source, destination string) error {
 	srcDir, err := filepath.Abs(filepath.Clean(source))
 	if err != nil {
 		return err
@@ -34,13 +78,7 @@ func CopyDirectory(source, destination string, overWrite bool) (err error) {
 	if strings.Count(srcDir, "/") < 2 || strings.Count(dstDir, "/") < 2 {
 		return errors.Errorf("prohibit copying the content for the top level of directory %v or %v", srcDir, dstDir)
 	}
-
-	fn := func() (interface{}, error) {
-		return "", io.CopyFiles(source, destination, overWrite)
-	}
-
-	_, err = RunFunc(fn, 0)
-	return err
+	return nil
 }
 
 // CreateDirectory switches to the host namespace and creates a directory at
@@ -296,3 +334,65 @@ func GetDiskStat(path string) (*types.DiskStat, error) {
 	}
 	return &result, nil
 }
+
+// GetDiskStatWithQuota switches to the host namespace and returns the disk
+// stat of the disk at the specified path, with the project quota of the path.
+func GetDiskStatWithQuota(path string) (result *types.DiskStat, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get disk stat with quota %s", path)
+	}()
+
+	fn := func() (interface{}, error) {
+		return io.GetDiskStatWithQuota(path)
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	diskStat, ableToCast := rawResult.(types.DiskStat)
+	if !ableToCast {
+		return nil, errors.Errorf(types.ErrNamespaceCastResultFmt, diskStat, rawResult)
+	}
+	return &diskStat, nil
+}
+
+// GetProjectQuota switches to the host namespace and returns the project
+// quota of the specified path. It returns nil if the path has no project ID.
+func GetProjectQuota(path string) (result *types.ProjectQuota, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get project quota %s", path)
+	}()
+
+	fn := func() (interface{}, error) {
+		return io.GetProjectQuota(path)
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ableToCast bool
+	result, ableToCast = rawResult.(*types.ProjectQuota)
+	if !ableToCast {
+		return nil, errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+	}
+	return result, nil
+}
+
+// SetProjectQuota switches to the host namespace and limits the project
+// quota of the specified directory.
+func SetProjectQuota(directory string, projectID uint32, hardLimitBytes, hardLimitInodes int64) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to set project quota %s", directory)
+	}()
+
+	fn := func() (interface{}, error) {
+		return nil, io.SetProjectQuota(directory, projectID, hardLimitBytes, hardLimitInodes)
+	}
+
+	_, err = RunFunc(fn, 0)
+	return err
+}
@@ -1,6 +1,7 @@
 package ns
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -21,6 +22,25 @@ func testCaseCopyDirectory(t *testing.T) map[string]testCaseNamespaceMethods {
 			mockError:   fmt.Errorf("failed"),
 			expectError: true,
 		},
+		"CopyDirectoryWithOptions/Success": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return CopyDirectoryWithOptions(context.Background(), "/tmp/test", "/tmp/test", nil)
+			},
+			mockResult: &types.CopyResult{CopiedFiles: 1},
+		},
+		"CopyDirectoryWithOptions/Prohibit top level directory": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return CopyDirectoryWithOptions(context.Background(), "/", "/tmp/test", nil)
+			},
+			expectError: true,
+		},
+		"CopyDirectoryWithOptions/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return CopyDirectoryWithOptions(context.Background(), "/tmp/test", "/tmp/test", nil)
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
 	}
 }
 
@@ -105,6 +125,48 @@ func testCaseGetEmptyFiles(t *testing.T) map[string]testCaseNamespaceMethods {
 	}
 }
 
+func testCaseGetProjectQuota(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"GetProjectQuota/Success": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetProjectQuota("test")
+			},
+			mockResult: &types.ProjectQuota{},
+		},
+		"GetProjectQuota/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetProjectQuota("test")
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+		"GetProjectQuota/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetProjectQuota("test")
+			},
+			mockResult:  int(1),
+			expectError: true,
+		},
+	}
+}
+
+func testCaseSetProjectQuota(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"SetProjectQuota/Success": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return nil, SetProjectQuota("test", 1, 1024, 10)
+			},
+		},
+		"SetProjectQuota/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return nil, SetProjectQuota("test", 1, 1024, 10)
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+	}
+}
+
 func testCaseGetFileInfo(t *testing.T) map[string]testCaseNamespaceMethods {
 	fakeDir := fake.CreateTempDirectory("", t)
 	defer func() {
@@ -230,3 +292,28 @@ func testCaseGetDiskStat(t *testing.T) map[string]testCaseNamespaceMethods {
 		},
 	}
 }
+
+func testCaseGetDiskStatWithQuota(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"GetDiskStatWithQuota/Success": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetDiskStatWithQuota("test")
+			},
+			mockResult: types.DiskStat{ProjectQuota: &types.ProjectQuota{ProjectID: 1}},
+		},
+		"GetDiskStatWithQuota/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetDiskStatWithQuota("test")
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+		"GetDiskStatWithQuota/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetDiskStatWithQuota("test")
+			},
+			mockResult:  int(1),
+			expectError: true,
+		},
+	}
+}
@@ -129,7 +129,13 @@ func TestNamespaceMethods(t *testing.T) {
 		testCaseKernelRelease(t),
 		testCaseSync(t),
 		testCaseGetOSDistro(t),
+		testCaseGetOSRelease(t),
 		testCaseGetSystemBlockDevices(t),
+		testCaseListBlockDevices(t),
+		testCaseResolveBlockDeviceStack(t),
+		testCaseGetKernelFeatureState(t),
+		testCaseGetKernelConfigMap(t),
+		testCaseGetSystemDefaultNFSVersion(t),
 		testCaseResolveBlockDeviceToPhysicalDevice(t),
 		testCaseCopyDirectory(t),
 		testCaseCreateDirectory(t),
@@ -143,6 +149,9 @@ func TestNamespaceMethods(t *testing.T) {
 		testCaseWriteFile(t),
 		testCaseDeletePath(t),
 		testCaseGetDiskStat(t),
+		testCaseGetDiskStatWithQuota(t),
+		testCaseGetProjectQuota(t),
+		testCaseSetProjectQuota(t),
 	}
 	testCases := make(map[string]testCaseNamespaceMethods)
 	for _, testMethod := range testMethods {
@@ -0,0 +1,81 @@
+package ns
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/sys"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// LoadKernelModule runs modprobe in the namespace to load the kernel module
+// and its dependencies, with the optional module parameters (e.g. key=value).
+func (nsexec *Executor) LoadKernelModule(moduleName string, parameters []string, timeout time.Duration) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to load kernel module %s", moduleName)
+	}()
+
+	args := append([]string{moduleName}, parameters...)
+	_, err = nsexec.Execute(nil, types.BinaryModprobe, args, timeout)
+	return err
+}
+
+// GetKernelFeatureState switches to the host namespace and returns the
+// availability of the kernel feature of the config key provided by the module.
+func GetKernelFeatureState(configMap map[string]string, configKey, moduleName string) (result types.KernelFeatureState, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get kernel feature %s state", configKey)
+	}()
+
+	fn := func() (interface{}, error) {
+		return sys.GetKernelFeatureState(configMap, configKey, moduleName)
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return "", err
+	}
+
+	var ableToCast bool
+	result, ableToCast = rawResult.(types.KernelFeatureState)
+	if !ableToCast {
+		return "", errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+	}
+	return result, nil
+}
+
+// GetKernelConfigMap switches to the host namespace and reads the config of the
+// running kernel from /boot, or from /proc/config.gz if /boot has no config.
+func GetKernelConfigMap() (result map[string]string, err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to get kernel config map")
+	}()
+
+	fn := func() (interface{}, error) {
+		kernelVersion, err := sys.GetKernelRelease()
+		if err != nil {
+			return nil, err
+		}
+
+		configMap, err := sys.GetBootKernelConfigMap(types.SysBootDirectory, kernelVersion)
+		if err != nil {
+			logrus.WithError(err).Debugf("Failed to get kernel config map from %v, falling back to %v", types.SysBootDirectory, types.SysProcDirectory)
+			return sys.GetProcKernelConfigMap(types.SysProcDirectory)
+		}
+		return configMap, nil
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ableToCast bool
+	result, ableToCast = rawResult.(map[string]string)
+	if !ableToCast {
+		return nil, errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package ns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestLoadKernelModule(t *testing.T) {
+	namespaces := []types.Namespace{types.NamespaceMnt}
+	nsexec, err := NewNamespaceExecutor(types.ProcessNone, types.HostProcDirectory, namespaces)
+	assert.Nil(t, err)
+
+	nsexec.executor = &fake.Executor{}
+
+	err = nsexec.LoadKernelModule("nvme_tcp", []string{"so_priority=1"}, types.ExecuteDefaultTimeout)
+	assert.Nil(t, err)
+}
+
+func testCaseGetKernelFeatureState(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"GetKernelFeatureState/Success": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetKernelFeatureState(map[string]string{}, "CONFIG_DM_CRYPT", "dm_crypt")
+			},
+			mockResult: types.KernelFeatureStateLoaded,
+			expected:   types.KernelFeatureStateLoaded,
+		},
+		"GetKernelFeatureState/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetKernelFeatureState(map[string]string{}, "CONFIG_DM_CRYPT", "dm_crypt")
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+		"GetKernelFeatureState/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetKernelFeatureState(map[string]string{}, "CONFIG_DM_CRYPT", "dm_crypt")
+			},
+			mockResult:  "invalid",
+			expectError: true,
+		},
+	}
+}
+
+func testCaseGetKernelConfigMap(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"GetKernelConfigMap/Success": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetKernelConfigMap()
+			},
+			mockResult: map[string]string{"CONFIG_DM_CRYPT": "m"},
+			expected:   map[string]string{"CONFIG_DM_CRYPT": "m"},
+		},
+		"GetKernelConfigMap/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetKernelConfigMap()
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+		"GetKernelConfigMap/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetKernelConfigMap()
+			},
+			mockResult:  "invalid",
+			expectError: true,
+		},
+	}
+}
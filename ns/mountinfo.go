@@ -0,0 +1,19 @@
+package ns
+
+import (
+	"github.com/longhorn/go-common-libs/proc"
+	"github.com/longhorn/go-common-libs/sys"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetHostMountInfo returns the mounts in the host mount namespace. The
+// mountinfo of a host process is read through the host proc directory, so
+// there is no need to switch to the namespace.
+func GetHostMountInfo() ([]types.MountInfo, error) {
+	return getHostMountInfo(types.HostProcDirectory)
+}
+
+func getHostMountInfo(procDirectory string) ([]types.MountInfo, error) {
+	pid := proc.GetHostNamespacePID(procDirectory)
+	return sys.GetMountInfo(procDirectory, int(pid))
+}
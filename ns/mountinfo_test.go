@@ -0,0 +1,35 @@
+package ns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/longhorn/go-common-libs/test/fake"
+)
+
+func TestGetHostMountInfo(t *testing.T) {
+	fakeProcDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeProcDir)
+	}()
+
+	_, err := getHostMountInfo(fakeProcDir)
+	assert.Error(t, err)
+
+	// Without a container runtime process, the host process falls back to pid 1.
+	err = os.MkdirAll(filepath.Join(fakeProcDir, "1"), 0755)
+	assert.NoError(t, err)
+	mountInfo := "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n" +
+		"30 22 8:17 / /var/lib/longhorn rw,relatime shared:20 - xfs /dev/sdb1 rw\n"
+	err = os.WriteFile(filepath.Join(fakeProcDir, "1", "mountinfo"), []byte(mountInfo), 0644)
+	assert.NoError(t, err)
+
+	mounts, err := getHostMountInfo(fakeProcDir)
+	assert.NoError(t, err)
+	assert.Len(t, mounts, 2)
+	assert.Equal(t, "/var/lib/longhorn", mounts[1].MountPoint)
+	assert.Equal(t, 20, mounts[1].SharedPeerGroup)
+}
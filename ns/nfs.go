@@ -0,0 +1,43 @@
+package ns
+
+import (
+	"github.com/cockroachdb/errors"
+
+	"github.com/longhorn/go-common-libs/nfs"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// nfsVersion is the NFS version returned from the host namespace.
+type nfsVersion struct {
+	major int
+	minor int
+}
+
+// GetSystemDefaultNFSVersion switches to the host namespace and reads the
+// system default NFS version from /etc/nfsmount.conf. Like
+// nfs.GetSystemDefaultNFSVersion, the error wraps ErrNotConfigured if the
+// default version is not overridden.
+func GetSystemDefaultNFSVersion() (major, minor int, err error) {
+	defer func() {
+		err = errors.Wrap(err, "failed to get system default NFS version")
+	}()
+
+	fn := func() (interface{}, error) {
+		major, minor, err := nfs.GetSystemDefaultNFSVersion(types.SysEtcDirectory)
+		if err != nil {
+			return nil, err
+		}
+		return nfsVersion{major: major, minor: minor}, nil
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	result, ableToCast := rawResult.(nfsVersion)
+	if !ableToCast {
+		return 0, 0, errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+	}
+	return result.major, result.minor, nil
+}
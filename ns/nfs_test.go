@@ -0,0 +1,37 @@
+package ns
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func testCaseGetSystemDefaultNFSVersion(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"GetSystemDefaultNFSVersion/Success": {
+			method: func(args ...interface{}) (interface{}, error) {
+				major, minor, err := GetSystemDefaultNFSVersion()
+				return []int{major, minor}, err
+			},
+			mockResult: nfsVersion{major: 4, minor: 2},
+			expected:   []int{4, 2},
+		},
+		"GetSystemDefaultNFSVersion/Not configured": {
+			method: func(args ...interface{}) (interface{}, error) {
+				_, _, err := GetSystemDefaultNFSVersion()
+				return nil, err
+			},
+			mockError:   fmt.Errorf("not overridden: %w", types.ErrNotConfigured),
+			expectError: true,
+		},
+		"GetSystemDefaultNFSVersion/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				_, _, err := GetSystemDefaultNFSVersion()
+				return nil, err
+			},
+			mockResult:  "invalid",
+			expectError: true,
+		},
+	}
+}
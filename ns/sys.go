@@ -61,9 +61,18 @@ func GetKernelRelease() (string, error) {
 }
 
 // GetOSDistro switches to the host namespace and retrieves the OS distro.
-func GetOSDistro() (result string, err error) {
+func GetOSDistro() (string, error) {
+	osRelease, err := GetOSRelease()
+	if err != nil {
+		return "", err
+	}
+	return osRelease.ID, nil
+}
+
+// GetOSRelease switches to the host namespace and retrieves the OS release.
+func GetOSRelease() (result *types.OSRelease, err error) {
 	defer func() {
-		err = errors.Wrapf(err, "failed to get host OS distro")
+		err = errors.Wrapf(err, "failed to get host OS release")
 	}()
 
 	fn := func() (interface{}, error) {
@@ -72,16 +81,15 @@ func GetOSDistro() (result string, err error) {
 
 	rawResult, err := RunFunc(fn, 0)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	var ableToCast bool
-	result, ableToCast = rawResult.(string)
+	content, ableToCast := rawResult.(string)
 	if !ableToCast {
-		return "", errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+		return nil, errors.Errorf(types.ErrNamespaceCastResultFmt, content, rawResult)
 	}
 
-	return sys.GetOSDistro(result)
+	return sys.ParseOSRelease(content)
 }
 
 // Sync switches to the host namespace and calls sync.
@@ -123,6 +131,30 @@ func GetSystemBlockDevices() (result map[string]types.BlockDeviceInfo, err error
 	return result, nil
 }
 
+// ListBlockDevices switches to the host namespace and retrieves the block
+// device inventory.
+func ListBlockDevices() (result map[string]types.BlockDevice, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to list block devices")
+	}()
+
+	fn := func() (interface{}, error) {
+		return sys.ListBlockDevices()
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ableToCast bool
+	result, ableToCast = rawResult.(map[string]types.BlockDevice)
+	if !ableToCast {
+		return nil, errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+	}
+	return result, nil
+}
+
 // ResolveBlockDeviceToPhysicalDevice switches to the host namespace and resolves
 // a block device to its physical device.
 func ResolveBlockDeviceToPhysicalDevice(blockDevice string) (result string, err error) {
@@ -146,3 +178,27 @@ func ResolveBlockDeviceToPhysicalDevice(blockDevice string) (result string, err
 	}
 	return result, nil
 }
+
+// ResolveBlockDeviceStack switches to the host namespace and resolves the
+// stack of the block device down to its disks or partitions.
+func ResolveBlockDeviceStack(device string) (result *types.BlockDeviceStack, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to resolve block device stack of %s", device)
+	}()
+
+	fn := func() (interface{}, error) {
+		return sys.ResolveBlockDeviceStack(device)
+	}
+
+	rawResult, err := RunFunc(fn, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var ableToCast bool
+	result, ableToCast = rawResult.(*types.BlockDeviceStack)
+	if !ableToCast {
+		return nil, errors.Errorf(types.ErrNamespaceCastResultFmt, result, rawResult)
+	}
+	return result, nil
+}
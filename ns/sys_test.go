@@ -97,6 +97,34 @@ ID_LIKE="suse"`,
 	}
 }
 
+func testCaseGetOSRelease(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"GetOSRelease/Talos": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetOSRelease()
+			},
+			mockResult: `NAME="Talos"
+ID=talos
+VERSION_ID=v1.7.0`,
+			expected: &types.OSRelease{Name: "Talos", ID: "talos", VersionID: "v1.7.0"},
+		},
+		"GetOSRelease/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetOSRelease()
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+		"GetOSRelease/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return GetOSRelease()
+			},
+			mockResult:  1,
+			expectError: true,
+		},
+	}
+}
+
 func testCaseGetSystemBlockDevices(t *testing.T) map[string]testCaseNamespaceMethods {
 	return map[string]testCaseNamespaceMethods{
 		"GetSystemBlockDevices/Local": {
@@ -125,6 +153,34 @@ func testCaseGetSystemBlockDevices(t *testing.T) map[string]testCaseNamespaceMet
 	}
 }
 
+func testCaseListBlockDevices(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"ListBlockDevices/Local": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return ListBlockDevices()
+			},
+			mockResult: map[string]types.BlockDevice{
+				"sda": {BlockDeviceInfo: types.BlockDeviceInfo{Name: "sda", Major: 8, Minor: 0}},
+			},
+			mockError: nil,
+		},
+		"ListBlockDevices/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return ListBlockDevices()
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+		"ListBlockDevices/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return ListBlockDevices()
+			},
+			mockResult:  "invalid",
+			expectError: true,
+		},
+	}
+}
+
 func testCaseResolveBlockDeviceToPhysicalDevice(t *testing.T) map[string]testCaseNamespaceMethods {
 	return map[string]testCaseNamespaceMethods{
 		"ResolveBlockDeviceToPhysicalDevice/Local": {
@@ -150,3 +206,29 @@ func testCaseResolveBlockDeviceToPhysicalDevice(t *testing.T) map[string]testCas
 		},
 	}
 }
+
+func testCaseResolveBlockDeviceStack(t *testing.T) map[string]testCaseNamespaceMethods {
+	return map[string]testCaseNamespaceMethods{
+		"ResolveBlockDeviceStack/Local": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return ResolveBlockDeviceStack("/dev/mapper/vg0-lv0")
+			},
+			mockResult: &types.BlockDeviceStack{Name: "dm-0"},
+			expected:   &types.BlockDeviceStack{Name: "dm-0"},
+		},
+		"ResolveBlockDeviceStack/Failed to run": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return ResolveBlockDeviceStack("/dev/mapper/vg0-lv0")
+			},
+			mockError:   fmt.Errorf("failed"),
+			expectError: true,
+		},
+		"ResolveBlockDeviceStack/Failed to cast result": {
+			method: func(args ...interface{}) (interface{}, error) {
+				return ResolveBlockDeviceStack("/dev/mapper/vg0-lv0")
+			},
+			mockResult:  "invalid",
+			expectError: true,
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package ns
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+
+	"github.com/longhorn/go-common-libs/io"
+	"github.com/longhorn/go-common-libs/proc"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// NewWatcher watches the paths in the host mount namespace. The paths are
+// resolved in the root directory of the host process, so the Watcher does not
+// need to stay in the namespace, and the events are reported with the host
+// paths. Absolute symbolic links are still resolved in the current namespace.
+func NewWatcher(ctx context.Context, paths []string, options *io.WatcherOptions) (*io.Watcher, error) {
+	return newWatcher(ctx, types.HostProcDirectory, paths, options)
+}
+
+func newWatcher(ctx context.Context, procDirectory string, paths []string, options *io.WatcherOptions) (*io.Watcher, error) {
+	watcherOptions := io.WatcherOptions{}
+	if options != nil {
+		watcherOptions = *options
+	}
+
+	pid := proc.GetHostNamespacePID(procDirectory)
+	watcherOptions.RootDirectory = filepath.Join(procDirectory, strconv.FormatUint(pid, 10), "root")
+	return io.NewWatcher(ctx, paths, &watcherOptions)
+}
@@ -0,0 +1,51 @@
+package ns
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/longhorn/go-common-libs/io"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestNewWatcher(t *testing.T) {
+	fakeProcDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeProcDir)
+	}()
+
+	// Without a container runtime process, the host process falls back to pid 1.
+	hostRootDir := filepath.Join(fakeProcDir, "1", "root")
+	err := os.MkdirAll(filepath.Join(hostRootDir, "data"), 0755)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	watcher, err := newWatcher(ctx, fakeProcDir, []string{"/data"}, &io.WatcherOptions{Debounce: 10 * time.Millisecond})
+	assert.NoError(t, err)
+	defer watcher.Close()
+
+	err = os.WriteFile(filepath.Join(hostRootDir, "data", "file"), []byte("content"), 0644)
+	assert.NoError(t, err)
+
+	var op types.FileEventOp
+	for op&types.FileEventOpCreate == 0 {
+		select {
+		case batch := <-watcher.Events():
+			for _, event := range batch {
+				if event.Path == "/data/file" {
+					op |= event.Op
+				}
+			}
+		case <-ctx.Done():
+			assert.Fail(t, "Timed out waiting for events")
+			return
+		}
+	}
+}
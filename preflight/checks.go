@@ -0,0 +1,269 @@
+package preflight
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/nfs"
+	"github.com/longhorn/go-common-libs/ns"
+	"github.com/longhorn/go-common-libs/types"
+	"github.com/longhorn/go-common-libs/utils"
+)
+
+const (
+	// The v2 data engine needs 2 GiB of huge pages for SPDK.
+	defaultHugepagesMinimumSize = 2 * 1024 * 1024 * 1024
+	// LUKS2 was introduced in cryptsetup 2.0.0.
+	defaultCryptsetupMinimumVersion = "2.0.0"
+)
+
+// DefaultChecks returns the checks of the Longhorn node prerequisites, which
+// run the commands with the executor in the host namespace. The kernel module
+// checks use the config of the host kernel, read when the checks are created.
+func DefaultChecks(executor Executor) []Check {
+	kernelConfigMap, err := ns.GetKernelConfigMap()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to get kernel config map, checking the kernel modules without it")
+	}
+
+	return []Check{
+		NewBinaryCheck(executor, "iscsiadm", []string{"--version"}, true,
+			"Install open-iscsi (iscsi-initiator-utils) on the node and start iscsid"),
+		NewBinaryCheck(executor, types.NsBinary, []string{"-V"}, true,
+			"Install util-linux on the node"),
+		NewCryptsetupVersionCheck(executor, defaultCryptsetupMinimumVersion, false),
+		NewKernelModuleCheck(kernelConfigMap, "CONFIG_ISCSI_TCP", "iscsi_tcp", true),
+		NewKernelModuleCheck(kernelConfigMap, "CONFIG_DM_CRYPT", "dm_crypt", false),
+		NewKernelModuleCheck(kernelConfigMap, "CONFIG_NVME_TCP", "nvme_tcp", false),
+		NewHostNFSVersionCheck(4, 0),
+		NewOSDistroCheck(nil),
+		NewHugepagesCheck(types.SysProcDirectory, defaultHugepagesMinimumSize),
+	}
+}
+
+// NewBinaryCheck checks that the binary can be run with the versionArgs.
+func NewBinaryCheck(executor Executor, binary string, versionArgs []string, required bool, remediation string) Check {
+	return Check{
+		Name:     "binary/" + binary,
+		Required: required,
+		Run: func(ctx context.Context) (*types.PreflightResult, error) {
+			output, err := executor.Execute(nil, binary, versionArgs, types.ExecuteDefaultTimeout)
+			if err != nil {
+				return notPass(remediation, "%v cannot be run: %v", binary, err)
+			}
+			return pass("%v is available: %v", binary, firstLine(output))
+		},
+	}
+}
+
+// NewCryptsetupVersionCheck checks that cryptsetup is at least the minimum version.
+func NewCryptsetupVersionCheck(executor Executor, minVersion string, required bool) Check {
+	remediation := fmt.Sprintf("Install cryptsetup %v or newer on the node", minVersion)
+	return Check{
+		Name:     "binary/" + types.BinaryCryptsetup,
+		Required: required,
+		Run: func(ctx context.Context) (*types.PreflightResult, error) {
+			output, err := executor.Execute(nil, types.BinaryCryptsetup, []string{"--version"}, types.ExecuteDefaultTimeout)
+			if err != nil {
+				return notPass(remediation, "%v cannot be run: %v", types.BinaryCryptsetup, err)
+			}
+
+			// e.g. cryptsetup 2.4.3
+			var version string
+			for _, field := range strings.Fields(output) {
+				if utils.IsVersionValid(field) {
+					version = field
+					break
+				}
+			}
+			if version == "" {
+				return nil, errors.Errorf("failed to parse cryptsetup version from %q", output)
+			}
+
+			isAtLeast, err := utils.IsVersionAtLeast(version, minVersion)
+			if err != nil {
+				return nil, err
+			}
+			if !isAtLeast {
+				return notPass(remediation, "%v %v is older than %v", types.BinaryCryptsetup, version, minVersion)
+			}
+			return pass("%v %v is available", types.BinaryCryptsetup, version)
+		},
+	}
+}
+
+// NewKernelModuleCheck checks that the kernel feature of the config key is
+// built in, or built as a module that is loaded. The configMap can be nil,
+// then the state of the module decides.
+func NewKernelModuleCheck(configMap map[string]string, configKey, moduleName string, required bool) Check {
+	return newKernelModuleCheck(configMap, configKey, moduleName, required, ns.GetKernelFeatureState)
+}
+
+func newKernelModuleCheck(configMap map[string]string, configKey, moduleName string, required bool,
+	getKernelFeatureStateFn func(map[string]string, string, string) (types.KernelFeatureState, error)) Check {
+	return Check{
+		Name:     "kernel-module/" + moduleName,
+		Required: required,
+		Run: func(ctx context.Context) (*types.PreflightResult, error) {
+			state, err := getKernelFeatureStateFn(configMap, configKey, moduleName)
+			if err != nil {
+				return nil, err
+			}
+
+			switch state {
+			case types.KernelFeatureStateBuiltin, types.KernelFeatureStateLoaded:
+				return pass("kernel module %v is %v", moduleName, state)
+			case types.KernelFeatureStateNotLoaded:
+				return notPass(fmt.Sprintf("Load the module with `modprobe %v`, and add it to /etc/modules-load.d to load it on boot", moduleName),
+					"kernel module %v is not loaded", moduleName)
+			default:
+				return notPass(fmt.Sprintf("Use a kernel built with %v", configKey),
+					"kernel module %v is not available", moduleName)
+			}
+		},
+	}
+}
+
+// NewNFSVersionCheck checks that the system default NFS version in the
+// nfsmount.conf under configDir is not overridden with a version older than
+// the minimum. If configDir is empty, it will be /etc by default.
+func NewNFSVersionCheck(configDir string, minMajor, minMinor int) Check {
+	return newNFSVersionCheck(minMajor, minMinor, func() (int, int, error) {
+		return nfs.GetSystemDefaultNFSVersion(configDir)
+	})
+}
+
+// NewHostNFSVersionCheck is like NewNFSVersionCheck, but reads the
+// nfsmount.conf under /etc in the host namespace.
+func NewHostNFSVersionCheck(minMajor, minMinor int) Check {
+	return newNFSVersionCheck(minMajor, minMinor, ns.GetSystemDefaultNFSVersion)
+}
+
+func newNFSVersionCheck(minMajor, minMinor int, getSystemDefaultNFSVersionFn func() (int, int, error)) Check {
+	return Check{
+		Name: "nfs-version",
+		Run: func(ctx context.Context) (*types.PreflightResult, error) {
+			major, minor, err := getSystemDefaultNFSVersionFn()
+			if errors.Is(err, types.ErrNotConfigured) {
+				return pass("system default NFS version is not overridden")
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if major < minMajor || (major == minMajor && minor < minMinor) {
+				return notPass(fmt.Sprintf("Set Defaultvers to %v.%v or newer in %v, or remove it", minMajor, minMinor, types.NFSMountFileName),
+					"system default NFS version %v.%v is older than %v.%v", major, minor, minMajor, minMinor)
+			}
+			return pass("system default NFS version is %v.%v", major, minor)
+		},
+	}
+}
+
+// NewOSDistroCheck checks that the host OS distro is not one of the
+// unsupported distros.
+func NewOSDistroCheck(unsupportedDistros []string) Check {
+	return newOSDistroCheck(unsupportedDistros, ns.GetOSDistro)
+}
+
+func newOSDistroCheck(unsupportedDistros []string, getOSDistroFn func() (string, error)) Check {
+	return Check{
+		Name:     "os-distro",
+		Required: true,
+		Run: func(ctx context.Context) (*types.PreflightResult, error) {
+			distro, err := getOSDistroFn()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, unsupportedDistro := range unsupportedDistros {
+				if distro == unsupportedDistro {
+					return notPass("Use a supported OS distro on the node", "OS distro %v is not supported", distro)
+				}
+			}
+			return pass("OS distro is %v", distro)
+		},
+	}
+}
+
+// NewHugepagesCheck checks that at least minSize bytes of huge pages of the
+// default size are reserved, by reading <procDir>/meminfo.
+func NewHugepagesCheck(procDir string, minSize int64) Check {
+	return Check{
+		Name: "hugepages",
+		Run: func(ctx context.Context) (*types.PreflightResult, error) {
+			totalPages, pageSize, err := getMeminfoHugepages(procDir)
+			if err != nil {
+				return nil, err
+			}
+
+			size := totalPages * pageSize
+			if size < minSize {
+				pages := minSize / pageSize
+				if minSize%pageSize != 0 {
+					pages++
+				}
+				return notPass(fmt.Sprintf("Reserve %v huge pages with `sysctl -w vm.nr_hugepages=%v`, and persist it in /etc/sysctl.d", pages, pages),
+					"%v bytes of huge pages are reserved, %v bytes are required", size, minSize)
+			}
+			return pass("%v bytes of huge pages are reserved", size)
+		},
+	}
+}
+
+// getMeminfoHugepages returns the number and the size in bytes of the default
+// huge pages from <procDir>/meminfo.
+func getMeminfoHugepages(procDir string) (totalPages, pageSize int64, err error) {
+	meminfoPath := filepath.Join(procDir, "meminfo")
+	file, err := os.Open(meminfoPath)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to open %v", meminfoPath)
+	}
+	defer file.Close() // nolint: errcheck // we don't care about errors here, we just want to close the file.
+
+	// e.g. HugePages_Total:    1024
+	//      Hugepagesize:       2048 kB
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch key {
+		case "HugePages_Total":
+			totalPages, err = strconv.ParseInt(fields[0], 10, 64)
+		case "Hugepagesize":
+			pageSize, err = strconv.ParseInt(fields[0], 10, 64)
+			pageSize *= 1024
+		}
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "invalid %v in %v", key, meminfoPath)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to read %v", meminfoPath)
+	}
+	if pageSize == 0 {
+		return 0, 0, errors.Errorf("huge pages are not supported by the kernel, no Hugepagesize in %v", meminfoPath)
+	}
+	return totalPages, pageSize, nil
+}
+
+// firstLine returns the first line of the output.
+func firstLine(output string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	return line
+}
@@ -0,0 +1,114 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// Executor runs commands, usually in the host namespace, e.g. ns.Executor.
+type Executor interface {
+	Execute(envs []string, binary string, args []string, timeout time.Duration) (string, error)
+}
+
+// Check is a node prerequisite check.
+type Check struct {
+	Name string
+	// Required checks fail when the prerequisite is missing, the others only warn.
+	Required bool
+	// Run checks the prerequisite. It returns a pass result, or a result with
+	// the failure message and the remediation hint, whose Status is then set by
+	// Required. It returns an error if the check could not run.
+	Run func(ctx context.Context) (*types.PreflightResult, error)
+}
+
+// RunChecks runs the checks in order and returns their results. A check that
+// cannot run, including the checks not run before ctx is done, does not pass
+// with the error as the message. Like a missing prerequisite, it only fails if
+// the check is required, and warns otherwise.
+func RunChecks(ctx context.Context, checks []Check) []types.PreflightResult {
+	results := make([]types.PreflightResult, 0, len(checks))
+	for _, check := range checks {
+		results = append(results, runCheck(ctx, check))
+	}
+	return results
+}
+
+func runCheck(ctx context.Context, check Check) (result types.PreflightResult) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = types.PreflightResult{
+				Name:    check.Name,
+				Status:  check.getNotPassStatus(),
+				Message: fmt.Sprintf("check panicked: %v", recovered),
+			}
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return types.PreflightResult{
+			Name:    check.Name,
+			Status:  check.getNotPassStatus(),
+			Message: fmt.Sprintf("check did not run: %v", err),
+		}
+	}
+
+	checkResult, err := check.Run(ctx)
+	if err != nil {
+		return types.PreflightResult{
+			Name:    check.Name,
+			Status:  check.getNotPassStatus(),
+			Message: fmt.Sprintf("failed to run check: %v", err),
+		}
+	}
+
+	result = *checkResult
+	result.Name = check.Name
+	if result.Status != types.PreflightStatusPass {
+		result.Status = check.getNotPassStatus()
+	}
+	return result
+}
+
+// getNotPassStatus returns the status of the check when it does not pass.
+func (check Check) getNotPassStatus() types.PreflightStatus {
+	if check.Required {
+		return types.PreflightStatusFail
+	}
+	return types.PreflightStatusWarn
+}
+
+// GetStatus returns the overall status of the results, which is the worst
+// status of them.
+func GetStatus(results []types.PreflightResult) types.PreflightStatus {
+	status := types.PreflightStatusPass
+	for _, result := range results {
+		switch result.Status {
+		case types.PreflightStatusFail:
+			return types.PreflightStatusFail
+		case types.PreflightStatusWarn:
+			status = types.PreflightStatusWarn
+		}
+	}
+	return status
+}
+
+// pass returns a pass result with the message.
+func pass(format string, args ...interface{}) (*types.PreflightResult, error) {
+	return &types.PreflightResult{
+		Status:  types.PreflightStatusPass,
+		Message: fmt.Sprintf(format, args...),
+	}, nil
+}
+
+// notPass returns a result that did not pass, with the message and the
+// remediation hint. The status is set by RunChecks.
+func notPass(remediation, format string, args ...interface{}) (*types.PreflightResult, error) {
+	return &types.PreflightResult{
+		Status:      types.PreflightStatusFail,
+		Message:     fmt.Sprintf(format, args...),
+		Remediation: remediation,
+	}, nil
+}
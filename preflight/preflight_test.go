@@ -0,0 +1,205 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestRunChecks(t *testing.T) {
+	checks := []Check{
+		{
+			Name: "pass",
+			Run: func(ctx context.Context) (*types.PreflightResult, error) {
+				return pass("ok")
+			},
+		},
+		{
+			Name: "warn",
+			Run: func(ctx context.Context) (*types.PreflightResult, error) {
+				return notPass("fix it", "not ok")
+			},
+		},
+		{
+			Name:     "fail",
+			Required: true,
+			Run: func(ctx context.Context) (*types.PreflightResult, error) {
+				return notPass("fix it", "not ok")
+			},
+		},
+		{
+			Name: "error",
+			Run: func(ctx context.Context) (*types.PreflightResult, error) {
+				return nil, fmt.Errorf("failed")
+			},
+		},
+		{
+			Name:     "required error",
+			Required: true,
+			Run: func(ctx context.Context) (*types.PreflightResult, error) {
+				return nil, fmt.Errorf("failed")
+			},
+		},
+		{
+			Name: "panic",
+			Run: func(ctx context.Context) (*types.PreflightResult, error) {
+				panic("unexpected")
+			},
+		},
+	}
+
+	results := RunChecks(context.Background(), checks)
+	assert.Equal(t, []types.PreflightResult{
+		{Name: "pass", Status: types.PreflightStatusPass, Message: "ok"},
+		{Name: "warn", Status: types.PreflightStatusWarn, Message: "not ok", Remediation: "fix it"},
+		{Name: "fail", Status: types.PreflightStatusFail, Message: "not ok", Remediation: "fix it"},
+		{Name: "error", Status: types.PreflightStatusWarn, Message: "failed to run check: failed"},
+		{Name: "required error", Status: types.PreflightStatusFail, Message: "failed to run check: failed"},
+		{Name: "panic", Status: types.PreflightStatusWarn, Message: "check panicked: unexpected"},
+	}, results)
+	assert.Equal(t, types.PreflightStatusFail, GetStatus(results))
+	assert.Equal(t, types.PreflightStatusWarn, GetStatus(results[:2]))
+	assert.Equal(t, types.PreflightStatusWarn, GetStatus([]types.PreflightResult{results[0], results[3]}))
+	assert.Equal(t, types.PreflightStatusPass, GetStatus(results[:1]))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = RunChecks(ctx, checks[:3])
+	assert.Equal(t, types.PreflightStatusWarn, results[0].Status)
+	assert.Equal(t, types.PreflightStatusFail, results[2].Status)
+}
+
+func TestChecks(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	writeFile := func(path, content string) string {
+		path = filepath.Join(fakeDir, path)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(path, []byte(content), 0644)
+		assert.NoError(t, err)
+		return filepath.Dir(path)
+	}
+
+	getKernelFeatureState := func(state types.KernelFeatureState) func(map[string]string, string, string) (types.KernelFeatureState, error) {
+		return func(map[string]string, string, string) (types.KernelFeatureState, error) {
+			return state, nil
+		}
+	}
+
+	type testCase struct {
+		check Check
+
+		expectedStatus types.PreflightStatus
+		expectRunError bool
+	}
+	testCases := map[string]testCase{
+		"Binary available": {
+			check:          NewBinaryCheck(&fake.Executor{}, "iscsiadm", []string{"--version"}, true, "install"),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"Binary missing": {
+			check: NewBinaryCheck(&fake.Executor{Results: []fake.ExecutorResult{{Err: fmt.Errorf("not found")}}},
+				"iscsiadm", []string{"--version"}, true, "install"),
+			expectedStatus: types.PreflightStatusFail,
+		},
+		"Cryptsetup new enough": {
+			check: NewCryptsetupVersionCheck(&fake.Executor{Results: []fake.ExecutorResult{{Output: "cryptsetup 2.4.3\n"}}},
+				"2.0.0", false),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"Cryptsetup too old": {
+			check: NewCryptsetupVersionCheck(&fake.Executor{Results: []fake.ExecutorResult{{Output: "cryptsetup 1.7.5\n"}}},
+				"2.0.0", false),
+			expectedStatus: types.PreflightStatusWarn,
+		},
+		"Cryptsetup version unknown": {
+			check:          NewCryptsetupVersionCheck(&fake.Executor{}, "2.0.0", false),
+			expectedStatus: types.PreflightStatusWarn,
+			expectRunError: true,
+		},
+		"Kernel module loaded": {
+			check:          newKernelModuleCheck(nil, "CONFIG_DM_CRYPT", "dm_crypt", true, getKernelFeatureState(types.KernelFeatureStateLoaded)),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"Kernel module not loaded": {
+			check:          newKernelModuleCheck(nil, "CONFIG_DM_CRYPT", "dm_crypt", false, getKernelFeatureState(types.KernelFeatureStateNotLoaded)),
+			expectedStatus: types.PreflightStatusWarn,
+		},
+		"Kernel module unavailable": {
+			check:          newKernelModuleCheck(nil, "CONFIG_ISCSI_TCP", "iscsi_tcp", true, getKernelFeatureState(types.KernelFeatureStateUnavailable)),
+			expectedStatus: types.PreflightStatusFail,
+		},
+		"NFS version not overridden": {
+			check:          NewNFSVersionCheck(filepath.Join(fakeDir, "not-exist"), 4, 0),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"NFS version too old": {
+			check:          NewNFSVersionCheck(writeFile("etc-old/"+types.NFSMountFileName, "[ NFSMount_Global_Options ]\nDefaultvers=3\n"), 4, 0),
+			expectedStatus: types.PreflightStatusWarn,
+		},
+		"NFS version new enough": {
+			check:          NewNFSVersionCheck(writeFile("etc-new/"+types.NFSMountFileName, "[ NFSMount_Global_Options ]\nDefaultvers=4.2\n"), 4, 0),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"Host NFS version not overridden": {
+			check: newNFSVersionCheck(4, 0, func() (int, int, error) {
+				return 0, 0, errors.Wrap(fmt.Errorf("not overridden: %w", types.ErrNotConfigured), "failed to get system default NFS version")
+			}),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"Host NFS version too old": {
+			check:          newNFSVersionCheck(4, 1, func() (int, int, error) { return 4, 0, nil }),
+			expectedStatus: types.PreflightStatusWarn,
+		},
+		"OS distro supported": {
+			check:          newOSDistroCheck([]string{"unsupported"}, func() (string, error) { return "ubuntu", nil }),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"OS distro unsupported": {
+			check:          newOSDistroCheck([]string{"unsupported"}, func() (string, error) { return "unsupported", nil }),
+			expectedStatus: types.PreflightStatusFail,
+		},
+		"Hugepages reserved": {
+			check:          NewHugepagesCheck(writeFile("proc-reserved/meminfo", "HugePages_Total:    1024\nHugepagesize:       2048 kB\n"), 2*1024*1024*1024),
+			expectedStatus: types.PreflightStatusPass,
+		},
+		"Hugepages not reserved": {
+			check:          NewHugepagesCheck(writeFile("proc-none/meminfo", "HugePages_Total:       0\nHugepagesize:       2048 kB\n"), 2*1024*1024*1024),
+			expectedStatus: types.PreflightStatusWarn,
+		},
+		"Hugepages not supported": {
+			check:          NewHugepagesCheck(writeFile("proc-unsupported/meminfo", "MemTotal:       8000000 kB\n"), 2*1024*1024*1024),
+			expectedStatus: types.PreflightStatusWarn,
+			expectRunError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			results := RunChecks(context.Background(), []Check{testCase.check})
+			assert.Len(t, results, 1)
+			assert.Equal(t, testCase.expectedStatus, results[0].Status, Commentf(test.ErrResultFmt, testName))
+			if testCase.expectRunError {
+				assert.True(t, strings.HasPrefix(results[0].Message, "failed to run check"), Commentf(test.ErrResultFmt, testName))
+				return
+			}
+			if results[0].Status == types.PreflightStatusWarn || (results[0].Status == types.PreflightStatusFail && testCase.check.Required) {
+				assert.NotEmpty(t, results[0].Remediation, Commentf(test.ErrResultFmt, testName))
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+	"github.com/longhorn/go-common-libs/utils"
+)
+
+// blockDeviceTransportPatterns maps the components of the resolved sysfs
+// path of a device to its transport. The first match wins, so the more
+// specific buses come first, e.g. virtio-scsi disks are scsi and multipath
+// NVMe namespaces under the virtual nvme-subsystem are nvme.
+var blockDeviceTransportPatterns = []struct {
+	component string
+	transport types.BlockDeviceTransport
+}{
+	{"/nvme/", types.BlockDeviceTransportNvme},
+	{"/nvme-subsystem/", types.BlockDeviceTransportNvme},
+	{"/devices/virtual/", types.BlockDeviceTransportVirtual},
+	{"/target", types.BlockDeviceTransportScsi},
+	{"/mmc_host/", types.BlockDeviceTransportMmc},
+	{"/virtio", types.BlockDeviceTransportVirtio},
+}
+
+// ListBlockDevices returns the block devices in /sys/class/block, with their
+// sizes, stacking and queue parameters, keyed by the device names.
+func ListBlockDevices() (map[string]types.BlockDevice, error) {
+	return listBlockDevices(types.SysClassBlockDirectory, os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+}
+
+// listBlockDevices returns the block devices in the sysClassBlockDirectory.
+// It injects the readDirFn, readFileFn and evalSymlinksFn for testing.
+func listBlockDevices(sysClassBlockDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error),
+	evalSymlinksFn func(string) (string, error)) (devices map[string]types.BlockDevice, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to list block devices in %v", sysClassBlockDirectory)
+	}()
+
+	deviceInfos, err := getSystemBlockDeviceInfo(sysClassBlockDirectory, readDirFn, readFileFn)
+	if err != nil {
+		return nil, err
+	}
+
+	devices = make(map[string]types.BlockDevice, len(deviceInfos))
+	for name, deviceInfo := range deviceInfos {
+		devicePath := filepath.Join(sysClassBlockDirectory, name)
+		device := types.BlockDevice{
+			BlockDeviceInfo: deviceInfo,
+			Holders:         readSysfsDirectoryNames(filepath.Join(devicePath, "holders"), readDirFn),
+			Slaves:          readSysfsDirectoryNames(filepath.Join(devicePath, "slaves"), readDirFn),
+		}
+
+		// The size is always in 512-byte sectors, regardless of the block size.
+		device.Size = utils.ReadSysfsInt64(filepath.Join(devicePath, "size"), readFileFn) * 512
+		device.IsReadOnly = utils.ReadSysfsString(filepath.Join(devicePath, "ro"), readFileFn) == "1"
+
+		realPath, err := evalSymlinksFn(devicePath)
+		isResolved := err == nil
+		if !isResolved {
+			logrus.WithError(err).Debugf("Failed to resolve sysfs path of block device %v", name)
+			realPath = devicePath
+		}
+		device.Transport = getBlockDeviceTransport(realPath)
+
+		// A partition shares the disk properties and queue of its disk, which
+		// is the parent directory in the resolved sysfs path. The parent is
+		// unknown if the path cannot be resolved.
+		diskPath := devicePath
+		if _, err := readFileFn(filepath.Join(devicePath, "partition")); err == nil {
+			device.IsPartition = true
+			if isResolved {
+				device.Parent = filepath.Base(filepath.Dir(realPath))
+				diskPath = filepath.Join(sysClassBlockDirectory, device.Parent)
+			}
+		}
+
+		device.IsRemovable = utils.ReadSysfsString(filepath.Join(diskPath, "removable"), readFileFn) == "1"
+		device.IsRotational = utils.ReadSysfsString(filepath.Join(diskPath, "queue", "rotational"), readFileFn) == "1"
+		device.LogicalBlockSize = utils.ReadSysfsInt64(filepath.Join(diskPath, "queue", "logical_block_size"), readFileFn)
+		device.PhysicalBlockSize = utils.ReadSysfsInt64(filepath.Join(diskPath, "queue", "physical_block_size"), readFileFn)
+		device.DiscardGranularity = utils.ReadSysfsInt64(filepath.Join(diskPath, "queue", "discard_granularity"), readFileFn)
+		device.MaxSectorsKB = utils.ReadSysfsInt64(filepath.Join(diskPath, "queue", "max_sectors_kb"), readFileFn)
+		device.WWN = readFirstSysfsString(readFileFn,
+			filepath.Join(diskPath, "wwid"),
+			filepath.Join(diskPath, "device", "wwid"))
+		device.Serial = readFirstSysfsString(readFileFn,
+			filepath.Join(diskPath, "device", "serial"),
+			filepath.Join(diskPath, "serial"))
+
+		devices[name] = device
+	}
+
+	for name, device := range devices {
+		if !device.IsPartition {
+			continue
+		}
+		parent, ok := devices[device.Parent]
+		if !ok {
+			continue
+		}
+		parent.Partitions = append(parent.Partitions, name)
+		sort.Strings(parent.Partitions)
+		devices[device.Parent] = parent
+	}
+	return devices, nil
+}
+
+// getBlockDeviceTransport returns the transport of the device from its
+// resolved sysfs path.
+func getBlockDeviceTransport(realPath string) types.BlockDeviceTransport {
+	for _, pattern := range blockDeviceTransportPatterns {
+		if strings.Contains(realPath, pattern.component) {
+			return pattern.transport
+		}
+	}
+	return types.BlockDeviceTransportUnknown
+}
+
+// readSysfsDirectoryNames returns the sorted entry names of the sysfs
+// directory, or nil if it cannot be read.
+func readSysfsDirectoryNames(path string, readDirFn func(string) ([]os.DirEntry, error)) []string {
+	entries, err := readDirFn(path)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readFirstSysfsString returns the first non-empty sysfs attribute of the paths.
+func readFirstSysfsString(readFileFn func(string) ([]byte, error), paths ...string) string {
+	for _, path := range paths {
+		if value := utils.ReadSysfsString(path, readFileFn); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestListBlockDevices(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	// A partitioned NVMe disk with an LVM volume on its partition, and a
+	// virtio-scsi disk.
+	nvmePath := "devices/pci0000:00/0000:00:01.0/nvme/nvme0/nvme0n1"
+	scsiPath := "devices/pci0000:00/0000:00:02.0/virtio2/host0/target0:0:0/0:0:0:0/block/sda"
+	dmPath := "devices/virtual/block/dm-0"
+	files := map[string]string{
+		nvmePath + "/dev":                          "259:0\n",
+		nvmePath + "/size":                         "2097152\n",
+		nvmePath + "/ro":                           "0\n",
+		nvmePath + "/removable":                    "0\n",
+		nvmePath + "/wwid":                         "eui.0025388b91b2c1a1\n",
+		nvmePath + "/device/serial":                "S4EWNX0N\n",
+		nvmePath + "/queue/rotational":             "0\n",
+		nvmePath + "/queue/logical_block_size":     "512\n",
+		nvmePath + "/queue/physical_block_size":    "4096\n",
+		nvmePath + "/queue/discard_granularity":    "4096\n",
+		nvmePath + "/queue/max_sectors_kb":         "1280\n",
+		nvmePath + "/nvme0n1p1/dev":                "259:1\n",
+		nvmePath + "/nvme0n1p1/size":               "1048576\n",
+		nvmePath + "/nvme0n1p1/ro":                 "0\n",
+		nvmePath + "/nvme0n1p1/partition":          "1\n",
+		nvmePath + "/nvme0n1p1/holders/dm-0/.keep": "",
+		scsiPath + "/dev":                          "8:0\n",
+		scsiPath + "/size":                         "4194304\n",
+		scsiPath + "/ro":                           "1\n",
+		scsiPath + "/removable":                    "1\n",
+		scsiPath + "/device/wwid":                  "naa.600508b1001c\n",
+		scsiPath + "/queue/rotational":             "1\n",
+		scsiPath + "/queue/logical_block_size":     "512\n",
+		scsiPath + "/queue/physical_block_size":    "512\n",
+		scsiPath + "/queue/discard_granularity":    "0\n",
+		scsiPath + "/queue/max_sectors_kb":         "512\n",
+		dmPath + "/dev":                            "252:0\n",
+		dmPath + "/size":                           "524288\n",
+		dmPath + "/ro":                             "0\n",
+		dmPath + "/removable":                      "0\n",
+		dmPath + "/queue/rotational":               "0\n",
+		dmPath + "/queue/logical_block_size":       "512\n",
+		dmPath + "/queue/physical_block_size":      "4096\n",
+		dmPath + "/slaves/nvme0n1p1/.keep":         "",
+	}
+	for path, content := range files {
+		path = filepath.Join(fakeDir, path)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(path, []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	sysClassBlockDir := filepath.Join(fakeDir, "class", "block")
+	err := os.MkdirAll(sysClassBlockDir, 0755)
+	assert.NoError(t, err)
+	for name, path := range map[string]string{
+		"nvme0n1":   nvmePath,
+		"nvme0n1p1": nvmePath + "/nvme0n1p1",
+		"sda":       scsiPath,
+		"dm-0":      dmPath,
+	} {
+		err := os.Symlink(filepath.Join("..", "..", path), filepath.Join(sysClassBlockDir, name))
+		assert.NoError(t, err)
+	}
+
+	expected := map[string]types.BlockDevice{
+		"nvme0n1": {
+			BlockDeviceInfo:    types.BlockDeviceInfo{Name: "nvme0n1", Major: 259, Minor: 0},
+			Size:               1024 * 1024 * 1024,
+			Partitions:         []string{"nvme0n1p1"},
+			LogicalBlockSize:   512,
+			PhysicalBlockSize:  4096,
+			DiscardGranularity: 4096,
+			MaxSectorsKB:       1280,
+			WWN:                "eui.0025388b91b2c1a1",
+			Serial:             "S4EWNX0N",
+			Transport:          types.BlockDeviceTransportNvme,
+		},
+		"nvme0n1p1": {
+			BlockDeviceInfo:    types.BlockDeviceInfo{Name: "nvme0n1p1", Major: 259, Minor: 1},
+			Size:               512 * 1024 * 1024,
+			IsPartition:        true,
+			Parent:             "nvme0n1",
+			Holders:            []string{"dm-0"},
+			LogicalBlockSize:   512,
+			PhysicalBlockSize:  4096,
+			DiscardGranularity: 4096,
+			MaxSectorsKB:       1280,
+			WWN:                "eui.0025388b91b2c1a1",
+			Serial:             "S4EWNX0N",
+			Transport:          types.BlockDeviceTransportNvme,
+		},
+		"sda": {
+			BlockDeviceInfo:   types.BlockDeviceInfo{Name: "sda", Major: 8, Minor: 0},
+			Size:              2 * 1024 * 1024 * 1024,
+			IsRemovable:       true,
+			IsReadOnly:        true,
+			IsRotational:      true,
+			LogicalBlockSize:  512,
+			PhysicalBlockSize: 512,
+			MaxSectorsKB:      512,
+			WWN:               "naa.600508b1001c",
+			Transport:         types.BlockDeviceTransportScsi,
+		},
+		"dm-0": {
+			BlockDeviceInfo:   types.BlockDeviceInfo{Name: "dm-0", Major: 252, Minor: 0},
+			Size:              256 * 1024 * 1024,
+			Slaves:            []string{"nvme0n1p1"},
+			LogicalBlockSize:  512,
+			PhysicalBlockSize: 4096,
+			Transport:         types.BlockDeviceTransportVirtual,
+		},
+	}
+
+	devices, err := listBlockDevices(sysClassBlockDir, os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+	assert.NoError(t, err, Commentf(test.ErrErrorFmt, "listBlockDevices", err))
+	assert.Equal(t, expected, devices, Commentf(test.ErrResultFmt, "listBlockDevices"))
+
+	// The parent of a partition is unknown if its sysfs path cannot be resolved.
+	evalSymlinksFn := func(path string) (string, error) {
+		if filepath.Base(path) == "nvme0n1p1" {
+			return "", os.ErrPermission
+		}
+		return filepath.EvalSymlinks(path)
+	}
+	devices, err = listBlockDevices(sysClassBlockDir, os.ReadDir, os.ReadFile, evalSymlinksFn)
+	assert.NoError(t, err, Commentf(test.ErrErrorFmt, "listBlockDevices", err))
+	assert.True(t, devices["nvme0n1p1"].IsPartition)
+	assert.Empty(t, devices["nvme0n1p1"].Parent)
+	assert.Empty(t, devices["nvme0n1"].Partitions)
+
+	_, err = listBlockDevices(filepath.Join(fakeDir, "not-exist"), os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package sys
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetKernelCryptoAlgorithms reads the crypto algorithms currently registered in the kernel from procfs mounted at
+// procDir. If the procDir is empty, it points to /proc by default.
+//
+// Note that the kernel registers template instances (e.g. xts(aes)) and algorithms provided by modules only after
+// their first use, so a missing entry does not necessarily mean the algorithm is unavailable.
+func GetKernelCryptoAlgorithms(procDir string) (algorithms []types.KernelCryptoAlgorithm, err error) {
+	if procDir == "" {
+		procDir = types.SysProcDirectory
+	}
+
+	defer func() {
+		err = errors.Wrapf(err, "failed to get kernel crypto algorithms from %s", procDir)
+	}()
+
+	cryptoPath := filepath.Join(procDir, types.SysProcCrypto)
+	cryptoFile, err := os.Open(cryptoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := cryptoFile.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close crypto file %s", cryptoPath)
+		}
+	}()
+	return parseKernelCryptoAlgorithms(cryptoFile)
+}
+
+// parseKernelCryptoAlgorithms parses the /proc/crypto content. Each algorithm is a block of "key : value" lines and
+// the blocks are separated by empty lines.
+func parseKernelCryptoAlgorithms(contentReader io.Reader) ([]types.KernelCryptoAlgorithm, error) {
+	var algorithms []types.KernelCryptoAlgorithm
+	var current *types.KernelCryptoAlgorithm
+
+	flush := func() {
+		if current != nil && current.Name != "" {
+			algorithms = append(algorithms, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(contentReader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		key, val, parsable := strings.Cut(line, ":")
+		if !parsable {
+			return nil, errors.Errorf("failed to parse crypto line %q", line)
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+
+		if current == nil {
+			current = &types.KernelCryptoAlgorithm{}
+		}
+		switch key {
+		case "name":
+			current.Name = val
+		case "driver":
+			current.Driver = val
+		case "module":
+			current.Module = val
+		case "type":
+			current.Type = val
+		case "priority":
+			priority, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid priority %q of %s", val, current.Name)
+			}
+			current.Priority = priority
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return algorithms, nil
+}
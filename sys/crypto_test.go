@@ -0,0 +1,71 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestGetKernelCryptoAlgorithms(t *testing.T) {
+	type testCase struct {
+		mockFileContent string
+
+		expected    []types.KernelCryptoAlgorithm
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Read crypto algorithms": {
+			mockFileContent: `name         : xts(aes)
+driver       : xts-aes-aesni
+module       : aesni_intel
+priority     : 401
+refcnt       : 1
+selftest     : passed
+internal     : no
+type         : skcipher
+
+name         : sha256
+driver       : sha256-generic
+module       : kernel
+priority     : 100
+type         : shash
+`,
+			expected: []types.KernelCryptoAlgorithm{
+				{Name: "xts(aes)", Driver: "xts-aes-aesni", Module: "aesni_intel", Type: "skcipher", Priority: 401},
+				{Name: "sha256", Driver: "sha256-generic", Module: "kernel", Type: "shash", Priority: 100},
+			},
+		},
+		"Empty crypto file": {
+			mockFileContent: "",
+		},
+		"Invalid content": {
+			mockFileContent: "name : aes\ninvalid\n",
+			expectError:     true,
+		},
+		"Invalid priority": {
+			mockFileContent: "name : aes\npriority : high\n",
+			expectError:     true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			procDir := t.TempDir()
+			err := os.WriteFile(filepath.Join(procDir, types.SysProcCrypto), []byte(testCase.mockFileContent), 0644)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+
+			algorithms, err := GetKernelCryptoAlgorithms(procDir)
+			if testCase.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, algorithms, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
@@ -38,8 +38,8 @@ func findBlockDeviceForMountWithDeps(
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		fields := strings.Fields(scanner.Text())
-		if len(fields) >= 2 && fields[1] == mountPath {
-			device := fields[0]
+		if len(fields) >= 2 && UnescapeMountPath(fields[1]) == mountPath {
+			device := UnescapeMountPath(fields[0])
 
 			// Skip pseudo-filesystems that don't have /dev/ prefix
 			if !strings.HasPrefix(device, "/dev/") {
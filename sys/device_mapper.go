@@ -0,0 +1,201 @@
+package sys
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/longhorn/go-common-libs/types"
+	"github.com/longhorn/go-common-libs/utils"
+)
+
+const deviceMapperDirectory = "/dev/mapper"
+
+// deviceMapperUUIDPrefixes maps the prefixes the device mapper owners put in
+// the UUIDs of their devices to the stack types.
+var deviceMapperUUIDPrefixes = []struct {
+	prefix    string
+	stackType types.BlockDeviceStackType
+}{
+	{"CRYPT-", types.BlockDeviceStackTypeCrypt},
+	{"LVM-", types.BlockDeviceStackTypeLvm},
+	{"mpath-", types.BlockDeviceStackTypeMultipath},
+	{"part", types.BlockDeviceStackTypePartition}, // kpartx partitions, e.g. part1-mpath-...
+}
+
+// ResolveBlockDeviceStack returns the stack of the block device by walking
+// the slaves in /sys/class/block, down to the disks or partitions at the
+// bottom. The device can be given as /dev/<name>, /dev/mapper/<dm name>, or
+// any symlink to them.
+func ResolveBlockDeviceStack(device string) (*types.BlockDeviceStack, error) {
+	return resolveBlockDeviceStack(device, types.SysClassBlockDirectory, os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+}
+
+// ResolveBlockDeviceToPhysicalDevices returns the paths of the devices at the
+// bottom of the stack of the block device, e.g. the partitions under an LVM
+// volume group or the paths of a multipath device. It returns the device
+// itself if it is not stacked on other devices.
+func ResolveBlockDeviceToPhysicalDevices(device string) ([]string, error) {
+	stack, err := ResolveBlockDeviceStack(device)
+	if err != nil {
+		return nil, err
+	}
+	return getBlockDeviceStackLeaves(stack), nil
+}
+
+// GetDeviceMapperAliases returns the /dev/mapper paths of the device mapper
+// devices, keyed by the kernel names (e.g. dm-0).
+func GetDeviceMapperAliases() (map[string]string, error) {
+	return getDeviceMapperAliases(types.SysClassBlockDirectory, os.ReadDir, os.ReadFile)
+}
+
+// getDeviceMapperAliases returns the /dev/mapper paths of the device mapper
+// devices in the sysClassBlockDirectory.
+// It injects the readDirFn and readFileFn for testing.
+func getDeviceMapperAliases(sysClassBlockDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error)) (map[string]string, error) {
+	entries, err := readDirFn(sysClassBlockDirectory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %v", sysClassBlockDirectory)
+	}
+
+	aliases := map[string]string{}
+	for _, entry := range entries {
+		dmName := utils.ReadSysfsString(filepath.Join(sysClassBlockDirectory, entry.Name(), "dm", "name"), readFileFn)
+		if dmName == "" {
+			continue
+		}
+		aliases[entry.Name()] = filepath.Join(deviceMapperDirectory, dmName)
+	}
+	return aliases, nil
+}
+
+// resolveBlockDeviceStack returns the stack of the block device.
+// It injects the readDirFn, readFileFn and evalSymlinksFn for testing.
+func resolveBlockDeviceStack(device, sysClassBlockDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error),
+	evalSymlinksFn func(string) (string, error)) (stack *types.BlockDeviceStack, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to resolve block device stack of %v", device)
+	}()
+
+	name, err := getBlockDeviceKernelName(device, sysClassBlockDirectory, readDirFn, readFileFn, evalSymlinksFn)
+	if err != nil {
+		return nil, err
+	}
+
+	stack, err = getBlockDeviceStack(name, sysClassBlockDirectory, readDirFn, readFileFn, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+// getBlockDeviceKernelName returns the kernel name of the device. The
+// /dev/mapper entries are usually symlinks to /dev/dm-*, but they can be
+// device nodes in containers, so the dm names are looked up as a fallback.
+func getBlockDeviceKernelName(device, sysClassBlockDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error),
+	evalSymlinksFn func(string) (string, error)) (string, error) {
+	if realDevice, err := evalSymlinksFn(device); err == nil {
+		device = realDevice
+	}
+
+	name := filepath.Base(device)
+	if filepath.Dir(device) != deviceMapperDirectory {
+		if _, err := readFileFn(filepath.Join(sysClassBlockDirectory, name, "dev")); err != nil {
+			return "", errors.Wrapf(err, "failed to find block device %v", name)
+		}
+		return name, nil
+	}
+
+	aliases, err := getDeviceMapperAliases(sysClassBlockDirectory, readDirFn, readFileFn)
+	if err != nil {
+		return "", err
+	}
+	for kernelName, alias := range aliases {
+		if alias == device {
+			return kernelName, nil
+		}
+	}
+	return "", fmt.Errorf("failed to find device mapper device %v", device)
+}
+
+func getBlockDeviceStack(name, sysClassBlockDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error),
+	visited map[string]bool) (*types.BlockDeviceStack, error) {
+	if visited[name] {
+		return nil, fmt.Errorf("found a loop in the block device stack at %v", name)
+	}
+	visited[name] = true
+	defer delete(visited, name)
+
+	devicePath := filepath.Join(sysClassBlockDirectory, name)
+	stack := &types.BlockDeviceStack{
+		Name:   name,
+		Path:   filepath.Join("/dev", name),
+		Type:   types.BlockDeviceStackTypeDisk,
+		DMName: utils.ReadSysfsString(filepath.Join(devicePath, "dm", "name"), readFileFn),
+		DMUUID: utils.ReadSysfsString(filepath.Join(devicePath, "dm", "uuid"), readFileFn),
+	}
+
+	switch {
+	case stack.DMName != "":
+		stack.Path = filepath.Join(deviceMapperDirectory, stack.DMName)
+		stack.Type = getDeviceMapperStackType(stack.DMUUID)
+	case utils.ReadSysfsString(filepath.Join(devicePath, "md", "level"), readFileFn) != "":
+		stack.Type = types.BlockDeviceStackTypeRaid
+	case utils.ReadSysfsString(filepath.Join(devicePath, "partition"), readFileFn) != "":
+		stack.Type = types.BlockDeviceStackTypePartition
+	}
+
+	for _, slaveName := range readSysfsDirectoryNames(filepath.Join(devicePath, "slaves"), readDirFn) {
+		slave, err := getBlockDeviceStack(slaveName, sysClassBlockDirectory, readDirFn, readFileFn, visited)
+		if err != nil {
+			return nil, err
+		}
+		stack.Slaves = append(stack.Slaves, *slave)
+	}
+	return stack, nil
+}
+
+// getDeviceMapperStackType returns the stack type of the device mapper
+// device from the prefix of its UUID.
+func getDeviceMapperStackType(dmUUID string) types.BlockDeviceStackType {
+	for _, uuidPrefix := range deviceMapperUUIDPrefixes {
+		if strings.HasPrefix(dmUUID, uuidPrefix.prefix) {
+			return uuidPrefix.stackType
+		}
+	}
+	return types.BlockDeviceStackTypeDeviceMapper
+}
+
+// getBlockDeviceStackLeaves returns the paths of the devices at the bottom
+// of the stack, without duplicates.
+func getBlockDeviceStackLeaves(stack *types.BlockDeviceStack) []string {
+	var leaves []string
+	seen := map[string]bool{}
+
+	var walk func(node *types.BlockDeviceStack)
+	walk = func(node *types.BlockDeviceStack) {
+		if len(node.Slaves) == 0 {
+			if !seen[node.Path] {
+				seen[node.Path] = true
+				leaves = append(leaves, node.Path)
+			}
+			return
+		}
+		for i := range node.Slaves {
+			walk(&node.Slaves[i])
+		}
+	}
+	walk(stack)
+	return leaves
+}
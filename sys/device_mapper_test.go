@@ -0,0 +1,164 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestResolveBlockDeviceStack(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	// dm-1 (LVM) -> dm-0 (dm-crypt) -> md0 (RAID1) -> sda1, sdb1
+	// dm-2 (multipath) -> sdc, sdd
+	// dm-3 and dm-4 are slaves of each other.
+	files := map[string]string{
+		"dm-0/dev":         "252:0",
+		"dm-0/dm/name":     "luks-data",
+		"dm-0/dm/uuid":     "CRYPT-LUKS2-1234-luks-data",
+		"dm-0/slaves/md0":  "",
+		"dm-1/dev":         "252:1",
+		"dm-1/dm/name":     "vg0-lv0",
+		"dm-1/dm/uuid":     "LVM-abcd",
+		"dm-1/slaves/dm-0": "",
+		"dm-2/dev":         "252:2",
+		"dm-2/dm/name":     "mpatha",
+		"dm-2/dm/uuid":     "mpath-3600508b1001c",
+		"dm-2/slaves/sdc":  "",
+		"dm-2/slaves/sdd":  "",
+		"dm-3/dev":         "252:3",
+		"dm-3/dm/name":     "loop-a",
+		"dm-3/slaves/dm-4": "",
+		"dm-4/dev":         "252:4",
+		"dm-4/dm/name":     "loop-b",
+		"dm-4/slaves/dm-3": "",
+		"md0/dev":          "9:0",
+		"md0/md/level":     "raid1",
+		"md0/slaves/sda1":  "",
+		"md0/slaves/sdb1":  "",
+		"sda1/dev":         "8:1",
+		"sda1/partition":   "1",
+		"sdb1/dev":         "8:17",
+		"sdb1/partition":   "1",
+		"sdc/dev":          "8:32",
+		"sdd/dev":          "8:48",
+	}
+	for path, content := range files {
+		path = filepath.Join(fakeDir, path)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(path, []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	raidStack := types.BlockDeviceStack{
+		Name: "md0",
+		Path: "/dev/md0",
+		Type: types.BlockDeviceStackTypeRaid,
+		Slaves: []types.BlockDeviceStack{
+			{Name: "sda1", Path: "/dev/sda1", Type: types.BlockDeviceStackTypePartition},
+			{Name: "sdb1", Path: "/dev/sdb1", Type: types.BlockDeviceStackTypePartition},
+		},
+	}
+	lvmStack := types.BlockDeviceStack{
+		Name:   "dm-1",
+		Path:   "/dev/mapper/vg0-lv0",
+		Type:   types.BlockDeviceStackTypeLvm,
+		DMName: "vg0-lv0",
+		DMUUID: "LVM-abcd",
+		Slaves: []types.BlockDeviceStack{
+			{
+				Name:   "dm-0",
+				Path:   "/dev/mapper/luks-data",
+				Type:   types.BlockDeviceStackTypeCrypt,
+				DMName: "luks-data",
+				DMUUID: "CRYPT-LUKS2-1234-luks-data",
+				Slaves: []types.BlockDeviceStack{raidStack},
+			},
+		},
+	}
+
+	type testCase struct {
+		device string
+
+		expectedStack  *types.BlockDeviceStack
+		expectedLeaves []string
+		expectError    bool
+	}
+	testCases := map[string]testCase{
+		"LVM on dm-crypt on RAID by mapper path": {
+			device:         "/dev/mapper/vg0-lv0",
+			expectedStack:  &lvmStack,
+			expectedLeaves: []string{"/dev/sda1", "/dev/sdb1"},
+		},
+		"LVM on dm-crypt on RAID by kernel name": {
+			device:         "/dev/dm-1",
+			expectedStack:  &lvmStack,
+			expectedLeaves: []string{"/dev/sda1", "/dev/sdb1"},
+		},
+		"Multipath": {
+			device: "/dev/mapper/mpatha",
+			expectedStack: &types.BlockDeviceStack{
+				Name:   "dm-2",
+				Path:   "/dev/mapper/mpatha",
+				Type:   types.BlockDeviceStackTypeMultipath,
+				DMName: "mpatha",
+				DMUUID: "mpath-3600508b1001c",
+				Slaves: []types.BlockDeviceStack{
+					{Name: "sdc", Path: "/dev/sdc", Type: types.BlockDeviceStackTypeDisk},
+					{Name: "sdd", Path: "/dev/sdd", Type: types.BlockDeviceStackTypeDisk},
+				},
+			},
+			expectedLeaves: []string{"/dev/sdc", "/dev/sdd"},
+		},
+		"Disk": {
+			device:         "/dev/sdc",
+			expectedStack:  &types.BlockDeviceStack{Name: "sdc", Path: "/dev/sdc", Type: types.BlockDeviceStackTypeDisk},
+			expectedLeaves: []string{"/dev/sdc"},
+		},
+		"Loop in stack": {
+			device:      "/dev/dm-3",
+			expectError: true,
+		},
+		"Unknown mapper device": {
+			device:      "/dev/mapper/unknown",
+			expectError: true,
+		},
+		"Unknown device": {
+			device:      "/dev/sdz",
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			stack, err := resolveBlockDeviceStack(testCase.device, fakeDir, os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expectedStack, stack, Commentf(test.ErrResultFmt, testName))
+			assert.Equal(t, testCase.expectedLeaves, getBlockDeviceStackLeaves(stack), Commentf(test.ErrResultFmt, testName))
+		})
+	}
+
+	aliases, err := getDeviceMapperAliases(fakeDir, os.ReadDir, os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"dm-0": "/dev/mapper/luks-data",
+		"dm-1": "/dev/mapper/vg0-lv0",
+		"dm-2": "/dev/mapper/mpatha",
+		"dm-3": "/dev/mapper/loop-a",
+		"dm-4": "/dev/mapper/loop-b",
+	}, aliases)
+}
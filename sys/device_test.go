@@ -29,6 +29,16 @@ func TestFindBlockDeviceForMountWithFile(t *testing.T) {
 			},
 			expected: "/dev/sdb1",
 		},
+		{
+			name: "mount path with escaped space",
+			mountsContent: `/dev/sda1 / ext4 rw,relatime 0 0
+/dev/sdb1 /var/lib/longhorn\040disk ext4 rw,relatime 0 0`,
+			mountPath: "/var/lib/longhorn disk",
+			resolveDevice: func(device string) (string, error) {
+				return device, nil
+			},
+			expected: "/dev/sdb1",
+		},
 		{
 			name: "mount path not found",
 			mountsContent: `/dev/sda1 / ext4 rw,relatime 0 0
@@ -0,0 +1,319 @@
+package sys
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const diskSectorSize = 512
+
+// GetDiskIOStats returns the I/O counters of the block devices in
+// /proc/diskstats, keyed by the device names.
+func GetDiskIOStats() (map[string]types.DiskIOStats, error) {
+	return getDiskIOStats(types.SysProcDirectory, os.ReadFile)
+}
+
+// getDiskIOStats returns the I/O counters of the block devices in the
+// diskstats of the procDir.
+// It injects the readFileFn for testing.
+func getDiskIOStats(procDir string, readFileFn func(string) ([]byte, error)) (stats map[string]types.DiskIOStats, err error) {
+	diskstatsPath := filepath.Join(procDir, types.SysProcDiskstats)
+
+	defer func() {
+		err = errors.Wrapf(err, "failed to get disk I/O stats from %s", diskstatsPath)
+	}()
+
+	content, err := readFileFn(diskstatsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now()
+	stats = map[string]types.DiskIOStats{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		stat, err := parseDiskstatsLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		stat.Timestamp = timestamp
+		stats[stat.Name] = *stat
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// parseDiskstatsLine parses a line of /proc/diskstats, which has the major
+// and minor numbers and the name of the device followed by its counters.
+func parseDiskstatsLine(line string) (*types.DiskIOStats, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid diskstats line %q", line)
+	}
+
+	major, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid major number in diskstats line %q", line)
+	}
+	minor, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid minor number in diskstats line %q", line)
+	}
+
+	stat, err := parseDiskIOCounters(fields[3:])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid diskstats line %q", line)
+	}
+	stat.BlockDeviceInfo = types.BlockDeviceInfo{
+		Name:  fields[2],
+		Major: major,
+		Minor: minor,
+	}
+	return stat, nil
+}
+
+// GetBlockDeviceIOStats returns the I/O counters of the block device from
+// /sys/class/block/<name>/stat. Unlike GetDiskIOStats, it only reads the
+// counters of the device, and does not fill the major and minor numbers.
+func GetBlockDeviceIOStats(name string) (*types.DiskIOStats, error) {
+	return getBlockDeviceIOStats(types.SysClassBlockDirectory, name, os.ReadFile)
+}
+
+// getBlockDeviceIOStats returns the I/O counters of the block device in the sysClassBlockDirectory.
+// It injects the readFileFn for testing.
+func getBlockDeviceIOStats(sysClassBlockDirectory, name string, readFileFn func(string) ([]byte, error)) (stat *types.DiskIOStats, err error) {
+	statPath := filepath.Join(sysClassBlockDirectory, name, "stat")
+
+	defer func() {
+		err = errors.Wrapf(err, "failed to get I/O stats of block device %v from %v", name, statPath)
+	}()
+
+	content, err := readFileFn(statPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err = parseDiskIOCounters(strings.Fields(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	stat.Name = name
+	stat.Timestamp = time.Now()
+	return stat, nil
+}
+
+// parseDiskIOCounters parses the counters of a block device, in the order of
+// the kernel Documentation/block/stat.rst. There are 11 counters before Linux
+// 4.18, 15 with the discard counters and 17 with the flush counters since 5.5.
+func parseDiskIOCounters(fields []string) (*types.DiskIOStats, error) {
+	if len(fields) < 11 {
+		return nil, fmt.Errorf("expected at least 11 counters, got %d", len(fields))
+	}
+
+	counters := make([]uint64, 17)
+	for i := 0; i < len(fields) && i < len(counters); i++ {
+		counter, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid counter %q", fields[i])
+		}
+		counters[i] = counter
+	}
+
+	milliseconds := func(counter uint64) time.Duration {
+		return time.Duration(counter) * time.Millisecond
+	}
+	return &types.DiskIOStats{
+		ReadsCompleted:    counters[0],
+		ReadsMerged:       counters[1],
+		SectorsRead:       counters[2],
+		ReadTime:          milliseconds(counters[3]),
+		WritesCompleted:   counters[4],
+		WritesMerged:      counters[5],
+		SectorsWritten:    counters[6],
+		WriteTime:         milliseconds(counters[7]),
+		IOsInProgress:     counters[8],
+		IOTime:            milliseconds(counters[9]),
+		WeightedIOTime:    milliseconds(counters[10]),
+		DiscardsCompleted: counters[11],
+		DiscardsMerged:    counters[12],
+		SectorsDiscarded:  counters[13],
+		DiscardTime:       milliseconds(counters[14]),
+		FlushesCompleted:  counters[15],
+		FlushTime:         milliseconds(counters[16]),
+	}, nil
+}
+
+// CalculateDiskIORates returns the I/O rates of a block device between the
+// previous and the current samples of its counters. It fails if the current
+// sample is not later than the previous one, or if the counters went
+// backwards, e.g. because the device was recreated with the same name.
+func CalculateDiskIORates(previous, current *types.DiskIOStats) (*types.DiskIORates, error) {
+	interval := current.Timestamp.Sub(previous.Timestamp)
+	if interval <= 0 {
+		return nil, fmt.Errorf("sample of block device %v at %v is not later than %v", current.Name, current.Timestamp, previous.Timestamp)
+	}
+
+	if current.ReadsCompleted < previous.ReadsCompleted ||
+		current.WritesCompleted < previous.WritesCompleted ||
+		current.SectorsRead < previous.SectorsRead ||
+		current.SectorsWritten < previous.SectorsWritten ||
+		current.ReadTime < previous.ReadTime ||
+		current.WriteTime < previous.WriteTime ||
+		current.IOTime < previous.IOTime {
+		return nil, fmt.Errorf("counters of block device %v went backwards", current.Name)
+	}
+
+	reads := current.ReadsCompleted - previous.ReadsCompleted
+	writes := current.WritesCompleted - previous.WritesCompleted
+	seconds := interval.Seconds()
+
+	rates := &types.DiskIORates{
+		BlockDeviceInfo:     current.BlockDeviceInfo,
+		ReadIOPS:            float64(reads) / seconds,
+		WriteIOPS:           float64(writes) / seconds,
+		ReadBytesPerSecond:  float64((current.SectorsRead-previous.SectorsRead)*diskSectorSize) / seconds,
+		WriteBytesPerSecond: float64((current.SectorsWritten-previous.SectorsWritten)*diskSectorSize) / seconds,
+		Utilization:         min(float64(current.IOTime-previous.IOTime)/float64(interval), 1),
+		InFlight:            current.IOsInProgress,
+		Interval:            interval,
+		Timestamp:           current.Timestamp,
+	}
+	if reads > 0 {
+		rates.ReadLatency = (current.ReadTime - previous.ReadTime) / time.Duration(reads)
+	}
+	if writes > 0 {
+		rates.WriteLatency = (current.WriteTime - previous.WriteTime) / time.Duration(writes)
+	}
+	return rates, nil
+}
+
+// DiskIOSampler samples /proc/diskstats at an interval and keeps the I/O
+// rates of the block devices over the latest interval.
+type DiskIOSampler struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	getStatsFn func() (map[string]types.DiskIOStats, error)
+
+	lock     sync.RWMutex
+	previous map[string]types.DiskIOStats
+	rates    map[string]types.DiskIORates
+}
+
+// NewDiskIOSampler takes the first sample from /proc/diskstats and keeps
+// sampling at the interval until ctx is done or the sampler is closed. The
+// rates are available after the first interval.
+func NewDiskIOSampler(ctx context.Context, interval time.Duration) (*DiskIOSampler, error) {
+	return newDiskIOSampler(ctx, interval, GetDiskIOStats)
+}
+
+// newDiskIOSampler starts a sampler of the stats returned by getStatsFn.
+// It injects the getStatsFn for testing.
+func newDiskIOSampler(ctx context.Context, interval time.Duration, getStatsFn func() (map[string]types.DiskIOStats, error)) (*DiskIOSampler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid disk I/O sampling interval %v", interval)
+	}
+
+	stats, err := getStatsFn()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DiskIOSampler{
+		getStatsFn: getStatsFn,
+		previous:   stats,
+		rates:      map[string]types.DiskIORates{},
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sample()
+			}
+		}
+	}()
+	return s, nil
+}
+
+// sample takes a sample and replaces the rates with the ones since the
+// previous sample. The devices that disappeared are dropped, and the devices
+// that appeared have rates after the next sample.
+func (s *DiskIOSampler) sample() {
+	stats, err := s.getStatsFn()
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to sample disk I/O stats")
+		return
+	}
+
+	rates := make(map[string]types.DiskIORates, len(stats))
+	for name, current := range stats {
+		previous, ok := s.previous[name]
+		if !ok {
+			continue
+		}
+		rate, err := CalculateDiskIORates(&previous, &current)
+		if err != nil {
+			logrus.WithError(err).Debug("Skipped disk I/O rates")
+			continue
+		}
+		rates[name] = *rate
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.previous = stats
+	s.rates = rates
+}
+
+// Latest returns the I/O rates of the block devices over the latest interval,
+// keyed by the device names.
+func (s *DiskIOSampler) Latest() map[string]types.DiskIORates {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	rates := make(map[string]types.DiskIORates, len(s.rates))
+	for name, rate := range s.rates {
+		rates[name] = rate
+	}
+	return rates
+}
+
+// LatestForDevice returns the I/O rates of the block device over the latest
+// interval, and false if there are none yet.
+func (s *DiskIOSampler) LatestForDevice(name string) (types.DiskIORates, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	rate, ok := s.rates[name]
+	return rate, ok
+}
+
+// Close stops the sampling and waits for the sampling goroutine to exit.
+func (s *DiskIOSampler) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
@@ -0,0 +1,279 @@
+package sys
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestGetDiskIOStats(t *testing.T) {
+	type testCase struct {
+		diskstats string
+
+		expected    map[string]types.DiskIOStats
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Counters of all kernel versions": {
+			diskstats: "" +
+				" 259       0 nvme0n1 1000 10 80000 500 2000 20 160000 4000 3 3000 4500 5 0 40 1 6 2\n" +
+				"   8       0 sda 100 1 800 50 200 2 1600 400 0 300 450\n" +
+				" 253       0 dm-0 10 0 80 5 20 0 160 40 0 30 45 1 0 8 1\n",
+			expected: map[string]types.DiskIOStats{
+				"nvme0n1": {
+					BlockDeviceInfo:   types.BlockDeviceInfo{Name: "nvme0n1", Major: 259, Minor: 0},
+					ReadsCompleted:    1000,
+					ReadsMerged:       10,
+					SectorsRead:       80000,
+					ReadTime:          500 * time.Millisecond,
+					WritesCompleted:   2000,
+					WritesMerged:      20,
+					SectorsWritten:    160000,
+					WriteTime:         4 * time.Second,
+					IOsInProgress:     3,
+					IOTime:            3 * time.Second,
+					WeightedIOTime:    4500 * time.Millisecond,
+					DiscardsCompleted: 5,
+					SectorsDiscarded:  40,
+					DiscardTime:       time.Millisecond,
+					FlushesCompleted:  6,
+					FlushTime:         2 * time.Millisecond,
+				},
+				"sda": {
+					BlockDeviceInfo: types.BlockDeviceInfo{Name: "sda", Major: 8, Minor: 0},
+					ReadsCompleted:  100,
+					ReadsMerged:     1,
+					SectorsRead:     800,
+					ReadTime:        50 * time.Millisecond,
+					WritesCompleted: 200,
+					WritesMerged:    2,
+					SectorsWritten:  1600,
+					WriteTime:       400 * time.Millisecond,
+					IOTime:          300 * time.Millisecond,
+					WeightedIOTime:  450 * time.Millisecond,
+				},
+				"dm-0": {
+					BlockDeviceInfo:   types.BlockDeviceInfo{Name: "dm-0", Major: 253, Minor: 0},
+					ReadsCompleted:    10,
+					SectorsRead:       80,
+					ReadTime:          5 * time.Millisecond,
+					WritesCompleted:   20,
+					SectorsWritten:    160,
+					WriteTime:         40 * time.Millisecond,
+					IOTime:            30 * time.Millisecond,
+					WeightedIOTime:    45 * time.Millisecond,
+					DiscardsCompleted: 1,
+					SectorsDiscarded:  8,
+					DiscardTime:       time.Millisecond,
+				},
+			},
+		},
+		"Too few counters": {
+			diskstats:   "   8       0 sda 100 1 800 50\n",
+			expectError: true,
+		},
+		"Invalid counter": {
+			diskstats:   "   8       0 sda 100 1 800 50 200 2 1600 400 0 300 -1\n",
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			fakeProcDir := fake.CreateTempDirectory("", t)
+			defer func() {
+				_ = os.RemoveAll(fakeProcDir)
+			}()
+			err := os.WriteFile(filepath.Join(fakeProcDir, types.SysProcDiskstats), []byte(testCase.diskstats), 0644)
+			assert.NoError(t, err)
+
+			stats, err := getDiskIOStats(fakeProcDir, os.ReadFile)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			for name, stat := range stats {
+				assert.False(t, stat.Timestamp.IsZero(), Commentf(test.ErrResultFmt, testName))
+				stat.Timestamp = time.Time{}
+				stats[name] = stat
+			}
+			assert.Equal(t, testCase.expected, stats, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+
+	_, err := getDiskIOStats("/not-exist", os.ReadFile)
+	assert.Error(t, err)
+}
+
+func TestGetBlockDeviceIOStats(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	err := os.MkdirAll(filepath.Join(fakeDir, "sda"), 0755)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(fakeDir, "sda", "stat"),
+		[]byte("     100        1      800       50      200        2     1600      400        0      300      450\n"), 0644)
+	assert.NoError(t, err)
+
+	stat, err := getBlockDeviceIOStats(fakeDir, "sda", os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "sda", stat.Name)
+	assert.Equal(t, uint64(100), stat.ReadsCompleted)
+	assert.Equal(t, uint64(1600), stat.SectorsWritten)
+	assert.Equal(t, 450*time.Millisecond, stat.WeightedIOTime)
+
+	_, err = getBlockDeviceIOStats(fakeDir, "sdb", os.ReadFile)
+	assert.Error(t, err)
+}
+
+func TestCalculateDiskIORates(t *testing.T) {
+	now := time.Now()
+	previous := types.DiskIOStats{
+		BlockDeviceInfo: types.BlockDeviceInfo{Name: "sda", Major: 8, Minor: 0},
+		ReadsCompleted:  100,
+		SectorsRead:     800,
+		ReadTime:        time.Second,
+		WritesCompleted: 200,
+		SectorsWritten:  1600,
+		WriteTime:       time.Second,
+		IOTime:          time.Second,
+		Timestamp:       now,
+	}
+
+	type testCase struct {
+		current types.DiskIOStats
+
+		expected    *types.DiskIORates
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Busy": {
+			current: types.DiskIOStats{
+				BlockDeviceInfo: previous.BlockDeviceInfo,
+				ReadsCompleted:  300,
+				SectorsRead:     4896,
+				ReadTime:        2 * time.Second,
+				WritesCompleted: 200,
+				SectorsWritten:  1600,
+				WriteTime:       time.Second,
+				IOsInProgress:   4,
+				IOTime:          2 * time.Second,
+				Timestamp:       now.Add(2 * time.Second),
+			},
+			expected: &types.DiskIORates{
+				BlockDeviceInfo:    previous.BlockDeviceInfo,
+				ReadIOPS:           100,
+				ReadBytesPerSecond: 1024 * 1024,
+				ReadLatency:        5 * time.Millisecond,
+				Utilization:        0.5,
+				InFlight:           4,
+				Interval:           2 * time.Second,
+				Timestamp:          now.Add(2 * time.Second),
+			},
+		},
+		"Utilization above interval": {
+			current: types.DiskIOStats{
+				BlockDeviceInfo: previous.BlockDeviceInfo,
+				ReadsCompleted:  100,
+				SectorsRead:     800,
+				ReadTime:        time.Second,
+				WritesCompleted: 200,
+				SectorsWritten:  1600,
+				WriteTime:       time.Second,
+				IOTime:          3 * time.Second,
+				Timestamp:       now.Add(time.Second),
+			},
+			expected: &types.DiskIORates{
+				BlockDeviceInfo: previous.BlockDeviceInfo,
+				Utilization:     1,
+				Interval:        time.Second,
+				Timestamp:       now.Add(time.Second),
+			},
+		},
+		"Not later": {
+			current: types.DiskIOStats{
+				BlockDeviceInfo: previous.BlockDeviceInfo,
+				Timestamp:       now,
+			},
+			expectError: true,
+		},
+		"Counters went backwards": {
+			current: types.DiskIOStats{
+				BlockDeviceInfo: previous.BlockDeviceInfo,
+				ReadsCompleted:  10,
+				Timestamp:       now.Add(time.Second),
+			},
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			rates, err := CalculateDiskIORates(&previous, &testCase.current)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, rates, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestDiskIOSampler(t *testing.T) {
+	var lock sync.Mutex
+	samples := 0
+	start := time.Now()
+	getStatsFn := func() (map[string]types.DiskIOStats, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		samples++
+		stats := map[string]types.DiskIOStats{
+			"sda": {
+				BlockDeviceInfo: types.BlockDeviceInfo{Name: "sda", Major: 8, Minor: 0},
+				ReadsCompleted:  uint64(samples * 10),
+				Timestamp:       start.Add(time.Duration(samples) * time.Second),
+			},
+		}
+		if samples > 1 {
+			// A device that appears after the first sample has no rates until it is sampled twice.
+			stats["sdb"] = types.DiskIOStats{
+				BlockDeviceInfo: types.BlockDeviceInfo{Name: "sdb", Major: 8, Minor: 16},
+				Timestamp:       start.Add(time.Duration(samples) * time.Second),
+			}
+		}
+		return stats, nil
+	}
+
+	_, err := newDiskIOSampler(context.Background(), 0, getStatsFn)
+	assert.Error(t, err)
+
+	sampler, err := newDiskIOSampler(context.Background(), 10*time.Millisecond, getStatsFn)
+	assert.NoError(t, err)
+	defer sampler.Close()
+
+	_, ok := sampler.LatestForDevice("sda")
+	assert.False(t, ok)
+
+	assert.Eventually(t, func() bool {
+		_, ok := sampler.LatestForDevice("sdb")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	rates := sampler.Latest()
+	assert.Len(t, rates, 2)
+	assert.Equal(t, float64(10), rates["sda"].ReadIOPS)
+	assert.Equal(t, time.Second, rates["sda"].Interval)
+}
@@ -0,0 +1,222 @@
+package sys
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetLoadedKernelModules returns the loaded kernel modules keyed by their names,
+// by parsing <procDir>/modules. If the procDir is empty, it points to /proc by default.
+func GetLoadedKernelModules(procDir string) (modules map[string]types.KernelModule, err error) {
+	if procDir == "" {
+		procDir = types.SysProcDirectory
+	}
+	modulesPath := filepath.Join(procDir, types.SysProcModules)
+
+	defer func() {
+		err = errors.Wrapf(err, "failed to get loaded kernel modules from %s", modulesPath)
+	}()
+
+	file, err := os.Open(modulesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := file.Close(); errClose != nil {
+			logrus.WithError(errClose).Errorf("Failed to close file %s", modulesPath)
+		}
+	}()
+
+	modules = map[string]types.KernelModule{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		module, err := parseProcModulesLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		modules[module.Name] = *module
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// parseProcModulesLine parses a /proc/modules line, e.g.:
+//
+//	nvme_core 200704 3 nvme,nvme_tcp, Live 0x0000000000000000
+func parseProcModulesLine(line string) (*types.KernelModule, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return nil, errors.Errorf("invalid kernel module line %q", line)
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid size in kernel module line %q", line)
+	}
+	refCount, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid reference count in kernel module line %q", line)
+	}
+
+	module := &types.KernelModule{
+		Name:     fields[0],
+		Size:     size,
+		RefCount: refCount,
+		State:    fields[4],
+	}
+	if fields[3] != "-" {
+		for _, dependent := range strings.Split(fields[3], ",") {
+			if dependent != "" {
+				module.Dependents = append(module.Dependents, dependent)
+			}
+		}
+	}
+	return module, nil
+}
+
+// GetBuiltinKernelModules returns the names of the modules built into the
+// kernel, by reading <modulesDir>/<kernelVersion>/modules.builtin. If the
+// modulesDir is empty, it points to /lib/modules by default.
+func GetBuiltinKernelModules(modulesDir, kernelVersion string) (modules map[string]bool, err error) {
+	return getKernelModuleList(modulesDir, kernelVersion, types.SysModulesBuiltin)
+}
+
+// GetLoadableKernelModules returns the names of the modules that are built
+// as loadable modules of the kernel, by reading
+// <modulesDir>/<kernelVersion>/modules.dep. If the modulesDir is empty, it
+// points to /lib/modules by default.
+func GetLoadableKernelModules(modulesDir, kernelVersion string) (modules map[string]bool, err error) {
+	return getKernelModuleList(modulesDir, kernelVersion, types.SysModulesDep)
+}
+
+// getKernelModuleList returns the names of the modules in the module list
+// file of the kernel, which starts every line with the path of a module.
+func getKernelModuleList(modulesDir, kernelVersion, fileName string) (modules map[string]bool, err error) {
+	if kernelVersion == "" {
+		return nil, errors.New("kernelVersion cannot be empty")
+	}
+	if modulesDir == "" {
+		modulesDir = types.SysLibModulesDirectory
+	}
+	listPath := filepath.Join(modulesDir, kernelVersion, fileName)
+
+	defer func() {
+		err = errors.Wrapf(err, "failed to get kernel modules from %s", listPath)
+	}()
+
+	content, err := os.ReadFile(listPath)
+	if err != nil {
+		return nil, err
+	}
+
+	modules = map[string]bool{}
+	for _, line := range strings.Split(string(content), "\n") {
+		// e.g. kernel/drivers/md/dm-crypt.ko in modules.builtin, and
+		// kernel/drivers/md/dm-crypt.ko.zst: kernel/drivers/md/dm-mod.ko.zst in modules.dep
+		modulePath, _, _ := strings.Cut(line, ":")
+		modulePath = strings.TrimSpace(modulePath)
+		if modulePath == "" {
+			continue
+		}
+		moduleName, _, _ := strings.Cut(filepath.Base(modulePath), ".ko")
+		modules[NormalizeKernelModuleName(moduleName)] = true
+	}
+	return modules, nil
+}
+
+// NormalizeKernelModuleName returns the name the kernel uses for the module,
+// which has underscores in place of dashes (e.g. dm-crypt is dm_crypt).
+func NormalizeKernelModuleName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// IsKernelModuleLoaded checks if the module is loaded, in /proc/modules or
+// with an initstate in /sys/module. The modules built into the kernel are not
+// loaded, use GetKernelFeatureState to check them too.
+func IsKernelModuleLoaded(moduleName string) (bool, error) {
+	return isKernelModuleLoaded(moduleName, types.SysProcDirectory, types.SysModuleDirectory)
+}
+
+func isKernelModuleLoaded(moduleName, procDir, sysModuleDir string) (bool, error) {
+	moduleName = NormalizeKernelModuleName(moduleName)
+
+	modules, err := GetLoadedKernelModules(procDir)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := modules[moduleName]; ok {
+		return true, nil
+	}
+
+	// Only the loadable modules have an initstate.
+	if _, err := os.Stat(filepath.Join(sysModuleDir, moduleName, "initstate")); err == nil {
+		return true, nil
+	}
+	return false, nil
+}
+
+// GetKernelFeatureState returns the availability of a kernel feature, by
+// combining the config value of configKey (e.g. CONFIG_DM_CRYPT) in the kernel
+// config map with the state of the module providing it (e.g. dm_crypt). The
+// config map can be read with GetBootKernelConfigMap or GetProcKernelConfigMap.
+// When the config key is missing, e.g. the config map is incomplete, the
+// state of the module decides, and a module that is not loaded is looked up
+// in modules.builtin and modules.dep.
+func GetKernelFeatureState(configMap map[string]string, configKey, moduleName string) (types.KernelFeatureState, error) {
+	kernelVersion, err := GetKernelRelease()
+	if err != nil {
+		return "", err
+	}
+	return getKernelFeatureState(configMap, configKey, moduleName, types.SysProcDirectory, types.SysModuleDirectory, types.SysLibModulesDirectory, kernelVersion)
+}
+
+func getKernelFeatureState(configMap map[string]string, configKey, moduleName, procDir, sysModuleDir, modulesDir, kernelVersion string) (types.KernelFeatureState, error) {
+	switch configMap[configKey] {
+	case "y":
+		return types.KernelFeatureStateBuiltin, nil
+	case "m":
+		isLoaded, err := isKernelModuleLoaded(moduleName, procDir, sysModuleDir)
+		if err != nil {
+			return "", err
+		}
+		if isLoaded {
+			return types.KernelFeatureStateLoaded, nil
+		}
+		return types.KernelFeatureStateNotLoaded, nil
+	}
+
+	isLoaded, err := isKernelModuleLoaded(moduleName, procDir, sysModuleDir)
+	if err != nil {
+		return "", err
+	}
+	if isLoaded {
+		return types.KernelFeatureStateLoaded, nil
+	}
+
+	builtinModules, err := GetBuiltinKernelModules(modulesDir, kernelVersion)
+	if err != nil {
+		logrus.WithError(err).Debugf("Failed to check if kernel module %s is builtin", moduleName)
+	}
+	if builtinModules[NormalizeKernelModuleName(moduleName)] {
+		return types.KernelFeatureStateBuiltin, nil
+	}
+
+	loadableModules, err := GetLoadableKernelModules(modulesDir, kernelVersion)
+	if err != nil {
+		logrus.WithError(err).Debugf("Failed to check if kernel module %s is loadable", moduleName)
+	}
+	if loadableModules[NormalizeKernelModuleName(moduleName)] {
+		return types.KernelFeatureStateNotLoaded, nil
+	}
+	return types.KernelFeatureStateUnavailable, nil
+}
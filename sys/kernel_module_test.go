@@ -0,0 +1,148 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestGetLoadedKernelModules(t *testing.T) {
+	type testCase struct {
+		procModules string
+
+		expected    map[string]types.KernelModule
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Modules": {
+			procModules: "nvme_tcp 49152 0 - Live 0x0000000000000000\n" +
+				"nvme_core 200704 3 nvme,nvme_tcp, Live 0x0000000000000000\n",
+			expected: map[string]types.KernelModule{
+				"nvme_tcp":  {Name: "nvme_tcp", Size: 49152, RefCount: 0, State: "Live"},
+				"nvme_core": {Name: "nvme_core", Size: 200704, RefCount: 3, Dependents: []string{"nvme", "nvme_tcp"}, State: "Live"},
+			},
+		},
+		"Empty": {
+			expected: map[string]types.KernelModule{},
+		},
+		"Invalid line": {
+			procModules: "nvme_tcp 49152\n",
+			expectError: true,
+		},
+		"Invalid size": {
+			procModules: "nvme_tcp size 0 - Live 0x0000000000000000\n",
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			procDir := fake.CreateTempDirectory("", t)
+			defer func() {
+				_ = os.RemoveAll(procDir)
+			}()
+			err := os.WriteFile(filepath.Join(procDir, types.SysProcModules), []byte(testCase.procModules), 0644)
+			assert.NoError(t, err)
+
+			modules, err := GetLoadedKernelModules(procDir)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, modules, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestGetKernelFeatureState(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	kernelVersion := "1.2.3"
+	procDir := filepath.Join(fakeDir, "proc")
+	sysModuleDir := filepath.Join(fakeDir, "sys", "module")
+	modulesDir := filepath.Join(fakeDir, "lib", "modules")
+	files := map[string]string{
+		filepath.Join(procDir, types.SysProcModules):                        "dm_crypt 61440 0 - Live 0x0000000000000000\n",
+		filepath.Join(sysModuleDir, "iscsi_tcp", "initstate"):               "live\n",
+		filepath.Join(sysModuleDir, "nvme_core", "parameters", "multipath"): "Y\n",
+		filepath.Join(modulesDir, kernelVersion, types.SysModulesBuiltin):   "kernel/drivers/nvme/host/nvme-core.ko\n",
+		filepath.Join(modulesDir, kernelVersion, types.SysModulesDep): "kernel/drivers/scsi/libiscsi.ko.zst:\n" +
+			"kernel/drivers/nvme/host/nvme-tcp.ko.zst: kernel/drivers/nvme/host/nvme-fabrics.ko.zst\n",
+	}
+	for path, content := range files {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(path, []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	configMap := map[string]string{
+		"CONFIG_DM_CRYPT":   "m",
+		"CONFIG_NVME_TCP":   "m",
+		"CONFIG_BLK_DEV_DM": "y",
+	}
+
+	type testCase struct {
+		configKey  string
+		moduleName string
+
+		expected types.KernelFeatureState
+	}
+	testCases := map[string]testCase{
+		"Built in by config": {
+			configKey:  "CONFIG_BLK_DEV_DM",
+			moduleName: "dm_mod",
+			expected:   types.KernelFeatureStateBuiltin,
+		},
+		"Loaded module": {
+			configKey:  "CONFIG_DM_CRYPT",
+			moduleName: "dm-crypt",
+			expected:   types.KernelFeatureStateLoaded,
+		},
+		"Not loaded module": {
+			configKey:  "CONFIG_NVME_TCP",
+			moduleName: "nvme_tcp",
+			expected:   types.KernelFeatureStateNotLoaded,
+		},
+		"Loaded module missing in config": {
+			configKey:  "CONFIG_ISCSI_TCP",
+			moduleName: "iscsi_tcp",
+			expected:   types.KernelFeatureStateLoaded,
+		},
+		"Built in module missing in config": {
+			configKey:  "CONFIG_NVME_CORE",
+			moduleName: "nvme_core",
+			expected:   types.KernelFeatureStateBuiltin,
+		},
+		"Not loaded module missing in config": {
+			configKey:  "CONFIG_SCSI_ISCSI_ATTRS",
+			moduleName: "libiscsi",
+			expected:   types.KernelFeatureStateNotLoaded,
+		},
+		"Unavailable": {
+			configKey:  "CONFIG_NBD",
+			moduleName: "nbd",
+			expected:   types.KernelFeatureStateUnavailable,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			state, err := getKernelFeatureState(configMap, testCase.configKey, testCase.moduleName, procDir, sysModuleDir, modulesDir, kernelVersion)
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, state, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+
+	_, err := getKernelFeatureState(configMap, "CONFIG_NBD", "nbd", filepath.Join(fakeDir, "not-exist"), sysModuleDir, modulesDir, kernelVersion)
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package sys
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetMountInfo returns the mounts in the mount namespace of the process by
+// parsing <procDirectory>/<pid>/mountinfo. It uses the current process if pid
+// is 0.
+func GetMountInfo(procDirectory string, pid int) (mounts []types.MountInfo, err error) {
+	process := "self"
+	if pid != 0 {
+		process = strconv.Itoa(pid)
+	}
+	mountInfoPath := filepath.Join(procDirectory, process, "mountinfo")
+
+	defer func() {
+		err = errors.Wrapf(err, "failed to get mount info from %v", mountInfoPath)
+	}()
+
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() // nolint: errcheck // we don't care about errors here, we just want to close the file.
+
+	return parseMountInfo(file)
+}
+
+// parseMountInfo parses the mountinfo lines, e.g.:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(reader io.Reader) ([]types.MountInfo, error) {
+	var mounts []types.MountInfo
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		mount, err := parseMountInfoLine(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid mountinfo line %q", line)
+		}
+		mounts = append(mounts, *mount)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+func parseMountInfoLine(line string) (*types.MountInfo, error) {
+	fields := strings.Split(line, " ")
+
+	// The optional fields end with a "-" separator, after the first 6 fields.
+	separator := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			separator = i
+			break
+		}
+	}
+	if separator == -1 || len(fields) < separator+4 {
+		return nil, fmt.Errorf("expected at least 10 fields with a separator")
+	}
+
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mount ID")
+	}
+	parentID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid parent ID")
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(fields[2], "%d:%d", &major, &minor); err != nil {
+		return nil, errors.Wrapf(err, "invalid device number %q", fields[2])
+	}
+
+	mount := &types.MountInfo{
+		ID:           id,
+		ParentID:     parentID,
+		Major:        major,
+		Minor:        minor,
+		Root:         UnescapeMountPath(fields[3]),
+		MountPoint:   UnescapeMountPath(fields[4]),
+		Options:      strings.Split(fields[5], ","),
+		FSType:       UnescapeMountPath(fields[separator+1]),
+		Source:       UnescapeMountPath(fields[separator+2]),
+		SuperOptions: strings.Split(fields[separator+3], ","),
+	}
+
+	for _, field := range fields[6:separator] {
+		tag, value, _ := strings.Cut(field, ":")
+		var peerGroup *int
+		switch tag {
+		case "shared":
+			peerGroup = &mount.SharedPeerGroup
+		case "master":
+			peerGroup = &mount.MasterPeerGroup
+		case "propagate_from":
+			peerGroup = &mount.PropagateFrom
+		case "unbindable":
+			mount.IsUnbindable = true
+			continue
+		default:
+			// Unknown optional fields are ignored, as documented in proc(5).
+			continue
+		}
+		if *peerGroup, err = strconv.Atoi(value); err != nil {
+			return nil, errors.Wrapf(err, "invalid optional field %q", field)
+		}
+	}
+	return mount, nil
+}
+
+// UnescapeMountPath replaces the octal escapes the kernel uses for the space,
+// tab, newline and backslash characters in /proc/mounts and mountinfo, e.g.
+// \040 for a space.
+func UnescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				builder.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		builder.WriteByte(path[i])
+	}
+	return builder.String()
+}
+
+// FindMountsUnderPath returns the mounts at the path or below it.
+func FindMountsUnderPath(mounts []types.MountInfo, path string) []types.MountInfo {
+	path = filepath.Clean(path)
+	prefix := path + "/"
+	if path == "/" {
+		prefix = "/"
+	}
+
+	var result []types.MountInfo
+	for _, mount := range mounts {
+		if mount.MountPoint == path || strings.HasPrefix(mount.MountPoint, prefix) {
+			result = append(result, mount)
+		}
+	}
+	return result
+}
+
+// FindMountsByDevice returns the mounts of the filesystem on the device with
+// the major and minor numbers, including its bind mounts.
+func FindMountsByDevice(mounts []types.MountInfo, major, minor int) []types.MountInfo {
+	var result []types.MountInfo
+	for _, mount := range mounts {
+		if mount.Major == major && mount.Minor == minor {
+			result = append(result, mount)
+		}
+	}
+	return result
+}
+
+// FindMountForPath returns the mount the path is on, which is the last mount
+// with the longest mount point containing the path, as later mounts hide the
+// earlier ones on the same mount point. It returns nil if there is none.
+func FindMountForPath(mounts []types.MountInfo, path string) *types.MountInfo {
+	path = filepath.Clean(path)
+
+	var result *types.MountInfo
+	for i, mount := range mounts {
+		if mount.MountPoint != path && mount.MountPoint != "/" && !strings.HasPrefix(path, mount.MountPoint+"/") {
+			continue
+		}
+		if result == nil || len(mount.MountPoint) >= len(result.MountPoint) {
+			result = &mounts[i]
+		}
+	}
+	return result
+}
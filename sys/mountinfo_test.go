@@ -0,0 +1,127 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+const fakeMountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro
+25 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+30 22 8:17 / /var/lib/longhorn rw,relatime shared:20 - xfs /dev/sdb1 rw,attr2,inode64,prjquota
+31 30 8:17 /replicas/pvc-1 /var/lib/kubelet/pods/a\040b/volume rw,relatime master:20 propagate_from:1 - xfs /dev/sdb1 rw,attr2
+32 22 0:50 / /mnt/private ro,nosuid unbindable - fuse.sshfs user@host:/path\011tab rw,user_id=0
+`
+
+func TestParseMountInfo(t *testing.T) {
+	type testCase struct {
+		mountInfo string
+
+		expected    []types.MountInfo
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Mounts": {
+			mountInfo: fakeMountInfo,
+			expected: []types.MountInfo{
+				{
+					ID: 22, ParentID: 1, Major: 8, Minor: 1, Root: "/", MountPoint: "/",
+					Options: []string{"rw", "relatime"}, SharedPeerGroup: 1,
+					FSType: "ext4", Source: "/dev/sda1", SuperOptions: []string{"rw", "errors=remount-ro"},
+				},
+				{
+					ID: 25, ParentID: 22, Major: 0, Minor: 21, Root: "/", MountPoint: "/proc",
+					Options: []string{"rw", "nosuid", "nodev", "noexec", "relatime"}, SharedPeerGroup: 12,
+					FSType: "proc", Source: "proc", SuperOptions: []string{"rw"},
+				},
+				{
+					ID: 30, ParentID: 22, Major: 8, Minor: 17, Root: "/", MountPoint: "/var/lib/longhorn",
+					Options: []string{"rw", "relatime"}, SharedPeerGroup: 20,
+					FSType: "xfs", Source: "/dev/sdb1", SuperOptions: []string{"rw", "attr2", "inode64", "prjquota"},
+				},
+				{
+					ID: 31, ParentID: 30, Major: 8, Minor: 17, Root: "/replicas/pvc-1", MountPoint: "/var/lib/kubelet/pods/a b/volume",
+					Options: []string{"rw", "relatime"}, MasterPeerGroup: 20, PropagateFrom: 1,
+					FSType: "xfs", Source: "/dev/sdb1", SuperOptions: []string{"rw", "attr2"},
+				},
+				{
+					ID: 32, ParentID: 22, Major: 0, Minor: 50, Root: "/", MountPoint: "/mnt/private",
+					Options: []string{"ro", "nosuid"}, IsUnbindable: true,
+					FSType: "fuse.sshfs", Source: "user@host:/path\ttab", SuperOptions: []string{"rw", "user_id=0"},
+				},
+			},
+		},
+		"Empty": {
+			mountInfo: "",
+		},
+		"Missing separator": {
+			mountInfo:   "22 1 8:1 / / rw,relatime shared:1 ext4 /dev/sda1 rw\n",
+			expectError: true,
+		},
+		"Invalid device number": {
+			mountInfo:   "22 1 8-1 / / rw,relatime - ext4 /dev/sda1 rw\n",
+			expectError: true,
+		},
+		"Invalid peer group": {
+			mountInfo:   "22 1 8:1 / / rw,relatime shared:x - ext4 /dev/sda1 rw\n",
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			mounts, err := parseMountInfo(strings.NewReader(testCase.mountInfo))
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, mounts, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestMountInfoQueries(t *testing.T) {
+	procDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(procDir)
+	}()
+
+	err := os.MkdirAll(filepath.Join(procDir, "123"), 0755)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(procDir, "123", "mountinfo"), []byte(fakeMountInfo), 0644)
+	assert.NoError(t, err)
+
+	_, err = GetMountInfo(procDir, 456)
+	assert.Error(t, err)
+
+	mounts, err := GetMountInfo(procDir, 123)
+	assert.NoError(t, err)
+
+	getMountIDs := func(mounts []types.MountInfo) []int {
+		var ids []int
+		for _, mount := range mounts {
+			ids = append(ids, mount.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []int{30, 31}, getMountIDs(FindMountsByDevice(mounts, 8, 17)))
+	assert.Nil(t, FindMountsByDevice(mounts, 8, 33))
+
+	assert.Equal(t, []int{22, 25, 30, 31, 32}, getMountIDs(FindMountsUnderPath(mounts, "/")))
+	assert.Equal(t, []int{31}, getMountIDs(FindMountsUnderPath(mounts, "/var/lib/kubelet/")))
+	assert.Nil(t, FindMountsUnderPath(mounts, "/var/lib/longhorn-other"))
+
+	assert.Equal(t, 30, FindMountForPath(mounts, "/var/lib/longhorn/replicas").ID)
+	assert.Equal(t, 31, FindMountForPath(mounts, "/var/lib/kubelet/pods/a b/volume").ID)
+	assert.Equal(t, 22, FindMountForPath(mounts, "/var/lib/longhorn-other").ID)
+	assert.Nil(t, FindMountForPath(nil, "/"))
+}
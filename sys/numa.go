@@ -0,0 +1,253 @@
+package sys
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// GetHugepages returns the system-wide huge page pools, in ascending order of size.
+func GetHugepages() ([]types.Hugepages, error) {
+	return getHugepages(types.SysKernelMMHugepagesDirectory, os.ReadDir, os.ReadFile)
+}
+
+// getHugepages returns the huge page pools in the hugepagesDirectory, which
+// has a hugepages-<size>kB directory for each pool.
+// It injects the readDirFn and readFileFn for testing.
+func getHugepages(hugepagesDirectory string, readDirFn func(string) ([]os.DirEntry, error), readFileFn func(string) ([]byte, error)) (hugepages []types.Hugepages, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get huge pages from %v", hugepagesDirectory)
+	}()
+
+	entries, err := readDirFn(hugepagesDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		sizeKB, ok := strings.CutPrefix(entry.Name(), "hugepages-")
+		if !ok {
+			continue
+		}
+		sizeKB, ok = strings.CutSuffix(sizeKB, "kB")
+		if !ok {
+			continue
+		}
+		size, err := strconv.ParseInt(sizeKB, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid huge page size %v", entry.Name())
+		}
+
+		poolDirectory := filepath.Join(hugepagesDirectory, entry.Name())
+		pool := types.Hugepages{Size: size * 1024}
+		for fileName, value := range map[string]*int64{
+			"nr_hugepages":      &pool.Total,
+			"free_hugepages":    &pool.Free,
+			"surplus_hugepages": &pool.Surplus,
+			"resv_hugepages":    &pool.Reserved,
+		} {
+			content, err := readFileFn(filepath.Join(poolDirectory, fileName))
+			if os.IsNotExist(err) {
+				// The NUMA node pools do not have resv_hugepages.
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if *value, err = strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64); err != nil {
+				return nil, errors.Wrapf(err, "invalid %v of %v", fileName, entry.Name())
+			}
+		}
+		hugepages = append(hugepages, pool)
+	}
+
+	sort.Slice(hugepages, func(i, j int) bool {
+		return hugepages[i].Size < hugepages[j].Size
+	})
+	return hugepages, nil
+}
+
+// GetNUMANodes returns the online NUMA nodes with their CPUs and huge page
+// pools, in ascending order of ID. A kernel built without NUMA support has no
+// node directories, and the system has a single node 0 with the online CPUs and
+// the system-wide huge page pools.
+func GetNUMANodes() ([]types.NUMANode, error) {
+	return getNUMANodes(types.SysDevicesSystemNodeDirectory, types.SysDevicesSystemCPUOnlinePath,
+		types.SysKernelMMHugepagesDirectory, os.ReadDir, os.ReadFile)
+}
+
+// getNUMANodes returns the NUMA nodes in the nodeDirectory, or the single node
+// of the cpuOnlinePath and hugepagesDirectory if the nodeDirectory is missing.
+// It injects the readDirFn and readFileFn for testing.
+func getNUMANodes(nodeDirectory, cpuOnlinePath, hugepagesDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error)) (nodes []types.NUMANode, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get NUMA nodes from %v", nodeDirectory)
+	}()
+
+	entries, err := readDirFn(nodeDirectory)
+	if errors.Is(err, fs.ErrNotExist) {
+		node, err := getSingleNUMANode(cpuOnlinePath, hugepagesDirectory, readDirFn, readFileFn)
+		if err != nil {
+			return nil, err
+		}
+		return []types.NUMANode{*node}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		idString, ok := strings.CutPrefix(entry.Name(), "node")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(idString)
+		if err != nil {
+			// Not a node directory, e.g. has_cpu.
+			continue
+		}
+
+		nodePath := filepath.Join(nodeDirectory, entry.Name())
+		cpuList, err := readFileFn(filepath.Join(nodePath, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		cpus, err := ParseCPUList(string(cpuList))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cpulist of %v", entry.Name())
+		}
+
+		// The hugepages directory is missing if the kernel has no huge page support.
+		hugepages, err := getHugepages(filepath.Join(nodePath, "hugepages"), readDirFn, readFileFn)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		nodes = append(nodes, types.NUMANode{
+			ID:        id,
+			CPUs:      cpus,
+			Hugepages: hugepages,
+		})
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes, nil
+}
+
+// getSingleNUMANode returns the node 0 of a system without NUMA, which has the
+// CPUs in the cpuOnlinePath and the huge page pools in the hugepagesDirectory.
+func getSingleNUMANode(cpuOnlinePath, hugepagesDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error)) (*types.NUMANode, error) {
+	cpuList, err := readFileFn(cpuOnlinePath)
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := ParseCPUList(string(cpuList))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid CPU list of %v", cpuOnlinePath)
+	}
+
+	hugepages, err := getHugepages(hugepagesDirectory, readDirFn, readFileFn)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return &types.NUMANode{
+		ID:        0,
+		CPUs:      cpus,
+		Hugepages: hugepages,
+	}, nil
+}
+
+// GetCPUNUMANodeMap returns the NUMA node IDs keyed by the CPU IDs.
+func GetCPUNUMANodeMap() (map[int]int, error) {
+	nodes, err := GetNUMANodes()
+	if err != nil {
+		return nil, err
+	}
+
+	cpuNodes := map[int]int{}
+	for _, node := range nodes {
+		for _, cpu := range node.CPUs {
+			cpuNodes[cpu] = node.ID
+		}
+	}
+	return cpuNodes, nil
+}
+
+// GetPCIDeviceNUMANode returns the NUMA node the PCI device at the address
+// (e.g. 0000:00:04.0) is attached to, or -1 if the device has no NUMA affinity.
+func GetPCIDeviceNUMANode(address string) (int, error) {
+	return getPCIDeviceNUMANode(types.SysBusPCIDevicesDirectory, address, os.ReadFile)
+}
+
+// getPCIDeviceNUMANode returns the NUMA node of the PCI device in the pciDevicesDirectory.
+// It injects the readFileFn for testing.
+func getPCIDeviceNUMANode(pciDevicesDirectory, address string, readFileFn func(string) ([]byte, error)) (int, error) {
+	node, err := readPCIDeviceNUMANode(filepath.Join(pciDevicesDirectory, address), readFileFn)
+	if err != nil {
+		return -1, errors.Wrapf(err, "failed to get NUMA node of PCI device %v", address)
+	}
+	return node, nil
+}
+
+// readPCIDeviceNUMANode reads the NUMA node of the PCI device at the
+// devicePath, or returns -1 with the error if it cannot be read.
+func readPCIDeviceNUMANode(devicePath string, readFileFn func(string) ([]byte, error)) (int, error) {
+	content, err := readFileFn(filepath.Join(devicePath, "numa_node"))
+	if err != nil {
+		return -1, err
+	}
+
+	node, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return -1, errors.Wrapf(err, "invalid NUMA node %q", strings.TrimSpace(string(content)))
+	}
+	return node, nil
+}
+
+// ParseCPUList parses a kernel CPU list (e.g. 0-3,8,10-11) into the CPU IDs,
+// in ascending order.
+func ParseCPUList(cpuList string) ([]int, error) {
+	cpuList = strings.TrimSpace(cpuList)
+	if cpuList == "" {
+		return nil, nil
+	}
+
+	var cpus []int
+	for _, cpuRange := range strings.Split(cpuList, ",") {
+		firstString, lastString, isRange := strings.Cut(cpuRange, "-")
+		first, err := strconv.Atoi(firstString)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid CPU range %q", cpuRange)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(lastString); err != nil {
+				return nil, errors.Wrapf(err, "invalid CPU range %q", cpuRange)
+			}
+		}
+		if last < first {
+			return nil, fmt.Errorf("invalid CPU range %q", cpuRange)
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	sort.Ints(cpus)
+	return cpus, nil
+}
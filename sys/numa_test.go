@@ -0,0 +1,111 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+func TestParseCPUList(t *testing.T) {
+	type testCase struct {
+		cpuList string
+
+		expected    []int
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Ranges":         {cpuList: "8-9,0-2,5\n", expected: []int{0, 1, 2, 5, 8, 9}},
+		"Single":         {cpuList: "3", expected: []int{3}},
+		"Empty":          {cpuList: "\n"},
+		"Invalid CPU":    {cpuList: "0-a", expectError: true},
+		"Reversed range": {cpuList: "3-1", expectError: true},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			cpus, err := ParseCPUList(testCase.cpuList)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, cpus, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestNUMATopology(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+
+	files := map[string]string{
+		"kernel/mm/hugepages/hugepages-2048kB/nr_hugepages":                      "1024\n",
+		"kernel/mm/hugepages/hugepages-2048kB/free_hugepages":                    "1000\n",
+		"kernel/mm/hugepages/hugepages-2048kB/surplus_hugepages":                 "0\n",
+		"kernel/mm/hugepages/hugepages-2048kB/resv_hugepages":                    "8\n",
+		"kernel/mm/hugepages/hugepages-1048576kB/nr_hugepages":                   "2\n",
+		"kernel/mm/hugepages/hugepages-1048576kB/free_hugepages":                 "2\n",
+		"kernel/mm/hugepages/hugepages-1048576kB/surplus_hugepages":              "0\n",
+		"kernel/mm/hugepages/hugepages-1048576kB/resv_hugepages":                 "0\n",
+		"devices/system/cpu/online":                                              "0-3\n",
+		"devices/system/node/has_cpu":                                            "0-1\n",
+		"devices/system/node/node0/cpulist":                                      "0-1,4\n",
+		"devices/system/node/node0/hugepages/hugepages-2048kB/nr_hugepages":      "512\n",
+		"devices/system/node/node0/hugepages/hugepages-2048kB/free_hugepages":    "500\n",
+		"devices/system/node/node0/hugepages/hugepages-2048kB/surplus_hugepages": "0\n",
+		"devices/system/node/node1/cpulist":                                      "2-3\n",
+		"bus/pci/devices/0000:00:04.0/numa_node":                                 "1\n",
+		"bus/pci/devices/0000:00:05.0/numa_node":                                 "-1\n",
+	}
+	for path, content := range files {
+		path = filepath.Join(fakeDir, path)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(path, []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	hugepages, err := getHugepages(filepath.Join(fakeDir, "kernel/mm/hugepages"), os.ReadDir, os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Hugepages{
+		{Size: 2 * 1024 * 1024, Total: 1024, Free: 1000, Reserved: 8},
+		{Size: 1024 * 1024 * 1024, Total: 2, Free: 2},
+	}, hugepages)
+
+	cpuOnlinePath := filepath.Join(fakeDir, "devices/system/cpu/online")
+	hugepagesDir := filepath.Join(fakeDir, "kernel/mm/hugepages")
+	nodes, err := getNUMANodes(filepath.Join(fakeDir, "devices/system/node"), cpuOnlinePath, hugepagesDir, os.ReadDir, os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.NUMANode{
+		{ID: 0, CPUs: []int{0, 1, 4}, Hugepages: []types.Hugepages{{Size: 2 * 1024 * 1024, Total: 512, Free: 500}}},
+		{ID: 1, CPUs: []int{2, 3}},
+	}, nodes)
+
+	// A kernel without NUMA support has no node directories.
+	nodes, err = getNUMANodes(filepath.Join(fakeDir, "devices/system/no-node"), cpuOnlinePath, hugepagesDir, os.ReadDir, os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.NUMANode{
+		{ID: 0, CPUs: []int{0, 1, 2, 3}, Hugepages: hugepages},
+	}, nodes)
+
+	pciDevicesDir := filepath.Join(fakeDir, "bus/pci/devices")
+	node, err := getPCIDeviceNUMANode(pciDevicesDir, "0000:00:04.0", os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, node)
+	node, err = getPCIDeviceNUMANode(pciDevicesDir, "0000:00:05.0", os.ReadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, node)
+	_, err = getPCIDeviceNUMANode(pciDevicesDir, "0000:00:06.0", os.ReadFile)
+	assert.Error(t, err)
+
+	_, err = getHugepages(filepath.Join(fakeDir, "not-exist"), os.ReadDir, os.ReadFile)
+	assert.Error(t, err)
+}
@@ -0,0 +1,290 @@
+package sys
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/longhorn/go-common-libs/types"
+	"github.com/longhorn/go-common-libs/utils"
+)
+
+// pciDiskDriverKernelNames maps the disk drivers to the names of the kernel
+// PCI drivers, which differ for vfio_pci and virtio-pci.
+var pciDiskDriverKernelNames = map[types.DiskDriver]string{
+	types.DiskDriverNvme:          "nvme",
+	types.DiskDriverVfioPci:       "vfio-pci",
+	types.DiskDriverUioPciGeneric: "uio_pci_generic",
+	types.DiskDriverVirtioPci:     "virtio-pci",
+}
+
+// ListPCIDevices returns the PCI devices in /sys/bus/pci/devices, in
+// ascending order of address.
+func ListPCIDevices() ([]types.PCIDevice, error) {
+	return listPCIDevices(types.SysBusPCIDirectory, os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+}
+
+// listPCIDevices returns the PCI devices in the devices directory of the pciBusDirectory.
+// It injects the readDirFn, readFileFn and evalSymlinksFn for testing.
+func listPCIDevices(pciBusDirectory string,
+	readDirFn func(string) ([]os.DirEntry, error),
+	readFileFn func(string) ([]byte, error),
+	evalSymlinksFn func(string) (string, error)) (devices []types.PCIDevice, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to list PCI devices in %v", pciBusDirectory)
+	}()
+
+	entries, err := readDirFn(filepath.Join(pciBusDirectory, "devices"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		device, err := getPCIDevice(pciBusDirectory, entry.Name(), readFileFn, evalSymlinksFn)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, *device)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Address < devices[j].Address
+	})
+	return devices, nil
+}
+
+// GetPCIDevice returns the PCI device at the address (e.g. 0000:00:04.0).
+func GetPCIDevice(address string) (*types.PCIDevice, error) {
+	return getPCIDevice(types.SysBusPCIDirectory, address, os.ReadFile, filepath.EvalSymlinks)
+}
+
+// getPCIDevice returns the PCI device at the address in the pciBusDirectory.
+// It injects the readFileFn and evalSymlinksFn for testing.
+func getPCIDevice(pciBusDirectory, address string,
+	readFileFn func(string) ([]byte, error),
+	evalSymlinksFn func(string) (string, error)) (device *types.PCIDevice, err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to get PCI device %v", address)
+	}()
+
+	devicePath := filepath.Join(pciBusDirectory, "devices", address)
+	vendor, err := readFileFn(filepath.Join(devicePath, "vendor"))
+	if err != nil {
+		return nil, err
+	}
+
+	device = &types.PCIDevice{
+		Address:    address,
+		Vendor:     strings.TrimPrefix(strings.TrimSpace(string(vendor)), "0x"),
+		Device:     strings.TrimPrefix(utils.ReadSysfsString(filepath.Join(devicePath, "device"), readFileFn), "0x"),
+		Class:      strings.TrimPrefix(utils.ReadSysfsString(filepath.Join(devicePath, "class"), readFileFn), "0x"),
+		Driver:     getPCIDeviceDriver(devicePath, evalSymlinksFn),
+		IOMMUGroup: -1,
+		NUMANode:   -1,
+	}
+	device.DiskDriver = getPCIDiskDriver(device.Driver)
+
+	// The iommu_group link is missing if the IOMMU is disabled.
+	if iommuGroupPath, err := evalSymlinksFn(filepath.Join(devicePath, "iommu_group")); err == nil {
+		if device.IOMMUGroup, err = strconv.Atoi(filepath.Base(iommuGroupPath)); err != nil {
+			return nil, errors.Wrapf(err, "invalid IOMMU group %v", iommuGroupPath)
+		}
+	}
+
+	// The numa_node is missing if the kernel is built without NUMA support.
+	if device.NUMANode, err = readPCIDeviceNUMANode(devicePath, readFileFn); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// getPCIDeviceDriver returns the name of the kernel driver bound to the
+// device, or an empty string if the device is unbound.
+func getPCIDeviceDriver(devicePath string, evalSymlinksFn func(string) (string, error)) string {
+	driverPath, err := evalSymlinksFn(filepath.Join(devicePath, "driver"))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(driverPath)
+}
+
+// getPCIDiskDriver returns the disk driver of the kernel driver name.
+func getPCIDiskDriver(kernelDriver string) types.DiskDriver {
+	for diskDriver, name := range pciDiskDriverKernelNames {
+		if name == kernelDriver {
+			return diskDriver
+		}
+	}
+	return types.DiskDriverNone
+}
+
+// BindPCIDeviceDriver binds the PCI device at the address to the kernel driver
+// of the disk driver, e.g. to hand an NVMe disk over from nvme to vfio_pci.
+// The device is unbound from its current driver, and the driver_override of the
+// device is set so that no other driver can claim it. If the device is not
+// bound to the driver after probing, it is bound back to its previous driver.
+// The kernel module of the driver must be loaded.
+func BindPCIDeviceDriver(address string, driver types.DiskDriver) error {
+	return bindPCIDeviceDriver(types.SysBusPCIDirectory, address, driver, os.ReadFile, os.WriteFile, filepath.EvalSymlinks)
+}
+
+// bindPCIDeviceDriver binds the PCI device at the address in the pciBusDirectory.
+// It injects the readFileFn, writeFileFn and evalSymlinksFn for testing.
+func bindPCIDeviceDriver(pciBusDirectory, address string, driver types.DiskDriver,
+	readFileFn func(string) ([]byte, error),
+	writeFileFn func(string, []byte, os.FileMode) error,
+	evalSymlinksFn func(string) (string, error)) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to bind PCI device %v to driver %v", address, driver)
+	}()
+
+	kernelDriver, ok := pciDiskDriverKernelNames[driver]
+	if !ok {
+		return fmt.Errorf("disk driver %v as a PCI driver %w", driver, types.ErrNotSupported)
+	}
+
+	if _, err := evalSymlinksFn(filepath.Join(pciBusDirectory, "drivers", kernelDriver)); err != nil {
+		return errors.Wrapf(err, "kernel driver %v is not loaded", kernelDriver)
+	}
+
+	devicePath := filepath.Join(pciBusDirectory, "devices", address)
+	if _, err := readFileFn(filepath.Join(devicePath, "vendor")); err != nil {
+		return err
+	}
+
+	currentDriver := getPCIDeviceDriver(devicePath, evalSymlinksFn)
+	if currentDriver == kernelDriver {
+		return writePCIDeviceDriverOverride(devicePath, kernelDriver, writeFileFn)
+	}
+
+	// The driver_override reads (null) if it is not set.
+	currentDriverOverride := utils.ReadSysfsString(filepath.Join(devicePath, "driver_override"), readFileFn)
+	if currentDriverOverride == "(null)" {
+		currentDriverOverride = ""
+	}
+
+	if err := writePCIDeviceDriverOverride(devicePath, kernelDriver, writeFileFn); err != nil {
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		if errRestore := restorePCIDeviceDriver(pciBusDirectory, address, currentDriver, currentDriverOverride, writeFileFn, evalSymlinksFn); errRestore != nil {
+			logrus.WithError(errRestore).Errorf("Failed to restore driver %v of PCI device %v", currentDriver, address)
+		}
+	}()
+
+	if currentDriver != "" {
+		if err := writeFileFn(filepath.Join(devicePath, "driver", "unbind"), []byte(address), 0200); err != nil {
+			return errors.Wrapf(err, "failed to unbind from driver %v", currentDriver)
+		}
+	}
+
+	if err := writeFileFn(filepath.Join(pciBusDirectory, "drivers_probe"), []byte(address), 0200); err != nil {
+		return err
+	}
+
+	// The write to drivers_probe succeeds even if no driver claims the device.
+	if boundDriver := getPCIDeviceDriver(devicePath, evalSymlinksFn); boundDriver != kernelDriver {
+		return fmt.Errorf("device is bound to driver %q instead of %v after probing", boundDriver, kernelDriver)
+	}
+	return nil
+}
+
+// restorePCIDeviceDriver binds the PCI device at the address in the
+// pciBusDirectory back to the driver after a failed binding, and restores the
+// driver_override. An empty driver leaves the device unbound.
+func restorePCIDeviceDriver(pciBusDirectory, address, driver, driverOverride string,
+	writeFileFn func(string, []byte, os.FileMode) error,
+	evalSymlinksFn func(string) (string, error)) error {
+	devicePath := filepath.Join(pciBusDirectory, "devices", address)
+	if boundDriver := getPCIDeviceDriver(devicePath, evalSymlinksFn); boundDriver != driver && boundDriver != "" {
+		if err := writeFileFn(filepath.Join(devicePath, "driver", "unbind"), []byte(address), 0200); err != nil {
+			return errors.Wrapf(err, "failed to unbind from driver %v", boundDriver)
+		}
+	}
+
+	if driver != "" && getPCIDeviceDriver(devicePath, evalSymlinksFn) != driver {
+		if err := writePCIDeviceDriverOverride(devicePath, driver, writeFileFn); err != nil {
+			return err
+		}
+		if err := writeFileFn(filepath.Join(pciBusDirectory, "drivers_probe"), []byte(address), 0200); err != nil {
+			return err
+		}
+	}
+
+	return writePCIDeviceDriverOverride(devicePath, driverOverride, writeFileFn)
+}
+
+// UnbindPCIDeviceDriver unbinds the PCI device at the address from its kernel
+// driver, if any, and clears its driver_override so that the device can be
+// claimed by its default driver again.
+func UnbindPCIDeviceDriver(address string) error {
+	return unbindPCIDeviceDriver(types.SysBusPCIDirectory, address, os.WriteFile, filepath.EvalSymlinks)
+}
+
+// unbindPCIDeviceDriver unbinds the PCI device at the address in the pciBusDirectory.
+// It injects the writeFileFn and evalSymlinksFn for testing.
+func unbindPCIDeviceDriver(pciBusDirectory, address string,
+	writeFileFn func(string, []byte, os.FileMode) error,
+	evalSymlinksFn func(string) (string, error)) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to unbind PCI device %v", address)
+	}()
+
+	devicePath := filepath.Join(pciBusDirectory, "devices", address)
+	if currentDriver := getPCIDeviceDriver(devicePath, evalSymlinksFn); currentDriver != "" {
+		if err := writeFileFn(filepath.Join(devicePath, "driver", "unbind"), []byte(address), 0200); err != nil {
+			return errors.Wrapf(err, "failed to unbind from driver %v", currentDriver)
+		}
+	}
+
+	return writePCIDeviceDriverOverride(devicePath, "", writeFileFn)
+}
+
+// SetPCIDeviceDriverOverride sets the driver_override of the PCI device at the
+// address to the kernel driver of the disk driver, or clears it if the disk
+// driver is DiskDriverNone. It does not change the driver bound to the device.
+func SetPCIDeviceDriverOverride(address string, driver types.DiskDriver) error {
+	return setPCIDeviceDriverOverride(types.SysBusPCIDirectory, address, driver, os.WriteFile)
+}
+
+// setPCIDeviceDriverOverride sets the driver_override of the PCI device at the
+// address in the pciBusDirectory.
+// It injects the writeFileFn for testing.
+func setPCIDeviceDriverOverride(pciBusDirectory, address string, driver types.DiskDriver,
+	writeFileFn func(string, []byte, os.FileMode) error) (err error) {
+	defer func() {
+		err = errors.Wrapf(err, "failed to set driver override of PCI device %v", address)
+	}()
+
+	kernelDriver := ""
+	if driver != types.DiskDriverNone {
+		var ok bool
+		if kernelDriver, ok = pciDiskDriverKernelNames[driver]; !ok {
+			return fmt.Errorf("disk driver %v as a PCI driver %w", driver, types.ErrNotSupported)
+		}
+	}
+
+	devicePath := filepath.Join(pciBusDirectory, "devices", address)
+	return writePCIDeviceDriverOverride(devicePath, kernelDriver, writeFileFn)
+}
+
+// writePCIDeviceDriverOverride writes the kernel driver to the driver_override
+// of the device. A newline clears the override.
+func writePCIDeviceDriverOverride(devicePath, kernelDriver string, writeFileFn func(string, []byte, os.FileMode) error) error {
+	if err := writeFileFn(filepath.Join(devicePath, "driver_override"), []byte(kernelDriver+"\n"), 0200); err != nil {
+		return errors.Wrapf(err, "failed to write driver override %q", kernelDriver)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package sys
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+	"github.com/longhorn/go-common-libs/test/fake"
+	"github.com/longhorn/go-common-libs/types"
+)
+
+// createFakePCIBus creates a fake /sys/bus/pci tree with an NVMe disk bound to
+// nvme, an unbound NVMe disk, and a network device without NUMA affinity, and
+// the loaded nvme and vfio-pci drivers.
+func createFakePCIBus(t *testing.T, fakeDir string) string {
+	pciBusDir := filepath.Join(fakeDir, "bus", "pci")
+	files := map[string]string{
+		"bus/pci/drivers_probe":                        "",
+		"bus/pci/drivers/nvme/unbind":                  "",
+		"bus/pci/drivers/vfio-pci/unbind":              "",
+		"bus/pci/devices/0000:00:04.0/vendor":          "0x144d\n",
+		"bus/pci/devices/0000:00:04.0/device":          "0xa808\n",
+		"bus/pci/devices/0000:00:04.0/class":           "0x010802\n",
+		"bus/pci/devices/0000:00:04.0/numa_node":       "0\n",
+		"bus/pci/devices/0000:00:04.0/driver_override": "(null)\n",
+		"bus/pci/devices/0000:00:05.0/vendor":          "0x8086\n",
+		"bus/pci/devices/0000:00:05.0/device":          "0x0953\n",
+		"bus/pci/devices/0000:00:05.0/class":           "0x010802\n",
+		"bus/pci/devices/0000:00:05.0/numa_node":       "1\n",
+		"bus/pci/devices/0000:00:05.0/driver_override": "(null)\n",
+		"bus/pci/devices/0000:00:06.0/vendor":          "0x1af4\n",
+		"bus/pci/devices/0000:00:06.0/device":          "0x1000\n",
+		"bus/pci/devices/0000:00:06.0/class":           "0x020000\n",
+		"bus/pci/devices/0000:00:06.0/numa_node":       "-1\n",
+		"bus/pci/devices/0000:00:06.0/driver_override": "(null)\n",
+		"kernel/iommu_groups/12/type":                  "DMA\n",
+		"kernel/iommu_groups/13/type":                  "DMA\n",
+		"bus/pci/drivers/virtio-pci/unbind":            "",
+	}
+	for path, content := range files {
+		path = filepath.Join(fakeDir, path)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(path, []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	links := map[string]string{
+		"bus/pci/devices/0000:00:04.0/driver":      "../../drivers/nvme",
+		"bus/pci/devices/0000:00:04.0/iommu_group": "../../../../kernel/iommu_groups/12",
+		"bus/pci/devices/0000:00:05.0/iommu_group": "../../../../kernel/iommu_groups/13",
+		"bus/pci/devices/0000:00:06.0/driver":      "../../drivers/virtio-pci",
+	}
+	for path, target := range links {
+		err := os.Symlink(target, filepath.Join(fakeDir, path))
+		assert.NoError(t, err)
+	}
+	return pciBusDir
+}
+
+func TestListPCIDevices(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+	pciBusDir := createFakePCIBus(t, fakeDir)
+
+	devices, err := listPCIDevices(pciBusDir, os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.PCIDevice{
+		{
+			Address:    "0000:00:04.0",
+			Vendor:     "144d",
+			Device:     "a808",
+			Class:      types.PCIClassNVMe,
+			Driver:     "nvme",
+			DiskDriver: types.DiskDriverNvme,
+			IOMMUGroup: 12,
+			NUMANode:   0,
+		},
+		{
+			Address:    "0000:00:05.0",
+			Vendor:     "8086",
+			Device:     "0953",
+			Class:      types.PCIClassNVMe,
+			IOMMUGroup: 13,
+			NUMANode:   1,
+		},
+		{
+			Address:    "0000:00:06.0",
+			Vendor:     "1af4",
+			Device:     "1000",
+			Class:      "020000",
+			Driver:     "virtio-pci",
+			DiskDriver: types.DiskDriverVirtioPci,
+			IOMMUGroup: -1,
+			NUMANode:   -1,
+		},
+	}, devices)
+
+	_, err = listPCIDevices(filepath.Join(fakeDir, "not-exist"), os.ReadDir, os.ReadFile, filepath.EvalSymlinks)
+	assert.Error(t, err)
+}
+
+func TestBindPCIDeviceDriver(t *testing.T) {
+	type testCase struct {
+		address string
+		driver  types.DiskDriver
+
+		unclaimedDriver string
+
+		expectedWrites map[string]string
+		expectedDriver string
+		expectError    bool
+	}
+	testCases := map[string]testCase{
+		"Bound device": {
+			address: "0000:00:04.0",
+			driver:  types.DiskDriverVfioPci,
+			expectedWrites: map[string]string{
+				"devices/0000:00:04.0/driver_override": "vfio-pci\n",
+				"devices/0000:00:04.0/driver/unbind":   "0000:00:04.0",
+				"drivers_probe":                        "0000:00:04.0",
+			},
+			expectedDriver: "vfio-pci",
+		},
+		"Unbound device": {
+			address: "0000:00:05.0",
+			driver:  types.DiskDriverVfioPci,
+			expectedWrites: map[string]string{
+				"devices/0000:00:05.0/driver_override": "vfio-pci\n",
+				"drivers_probe":                        "0000:00:05.0",
+			},
+			expectedDriver: "vfio-pci",
+		},
+		"Bound device not claimed after probing": {
+			address:         "0000:00:04.0",
+			driver:          types.DiskDriverVfioPci,
+			unclaimedDriver: "vfio-pci",
+			expectedWrites: map[string]string{
+				"devices/0000:00:04.0/driver_override": "\n",
+				"devices/0000:00:04.0/driver/unbind":   "0000:00:04.0",
+				"drivers_probe":                        "0000:00:04.0",
+			},
+			expectedDriver: "nvme",
+			expectError:    true,
+		},
+		"Unbound device not claimed after probing": {
+			address:         "0000:00:05.0",
+			driver:          types.DiskDriverVfioPci,
+			unclaimedDriver: "vfio-pci",
+			expectedWrites: map[string]string{
+				"devices/0000:00:05.0/driver_override": "\n",
+				"drivers_probe":                        "0000:00:05.0",
+			},
+			expectError: true,
+		},
+		"Already bound": {
+			address: "0000:00:04.0",
+			driver:  types.DiskDriverNvme,
+			expectedWrites: map[string]string{
+				"devices/0000:00:04.0/driver_override": "nvme\n",
+			},
+			expectedDriver: "nvme",
+		},
+		"Driver not loaded": {
+			address:     "0000:00:04.0",
+			driver:      types.DiskDriverUioPciGeneric,
+			expectError: true,
+		},
+		"Not a PCI driver": {
+			address:     "0000:00:04.0",
+			driver:      types.DiskDriverAio,
+			expectError: true,
+		},
+		"Device not exist": {
+			address:     "0000:00:07.0",
+			driver:      types.DiskDriverVfioPci,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			fakeDir := fake.CreateTempDirectory("", t)
+			defer func() {
+				_ = os.RemoveAll(fakeDir)
+			}()
+			pciBusDir := createFakePCIBus(t, fakeDir)
+			driverLink := filepath.Join(pciBusDir, "devices", testCase.address, "driver")
+
+			// Simulate the kernel unbinding the device, and binding it to the
+			// driver of its driver_override unless the driver does not claim it.
+			writes := map[string]string{}
+			writeFileFn := func(path string, data []byte, perm os.FileMode) error {
+				relPath, err := filepath.Rel(pciBusDir, path)
+				if err != nil {
+					return err
+				}
+				writes[relPath] = string(data)
+
+				switch relPath {
+				case filepath.Join("devices", testCase.address, "driver", "unbind"):
+					return os.Remove(driverLink)
+				case "drivers_probe":
+					driverOverride := strings.TrimSpace(writes[filepath.Join("devices", testCase.address, "driver_override")])
+					if driverOverride == "" || driverOverride == testCase.unclaimedDriver {
+						return nil
+					}
+					return os.Symlink(filepath.Join("..", "..", "drivers", driverOverride), driverLink)
+				}
+				return nil
+			}
+
+			err := bindPCIDeviceDriver(pciBusDir, testCase.address, testCase.driver, os.ReadFile, writeFileFn, filepath.EvalSymlinks)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			} else {
+				assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			}
+			if testCase.expectedWrites != nil {
+				assert.Equal(t, testCase.expectedWrites, writes, Commentf(test.ErrResultFmt, testName))
+				assert.Equal(t, testCase.expectedDriver, getPCIDeviceDriver(filepath.Dir(driverLink), filepath.EvalSymlinks), Commentf(test.ErrResultFmt, testName))
+			}
+		})
+	}
+}
+
+func TestUnbindPCIDeviceDriver(t *testing.T) {
+	fakeDir := fake.CreateTempDirectory("", t)
+	defer func() {
+		_ = os.RemoveAll(fakeDir)
+	}()
+	pciBusDir := createFakePCIBus(t, fakeDir)
+
+	err := unbindPCIDeviceDriver(pciBusDir, "0000:00:04.0", os.WriteFile, filepath.EvalSymlinks)
+	assert.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(pciBusDir, "drivers", "nvme", "unbind"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0000:00:04.0", string(content))
+	content, err = os.ReadFile(filepath.Join(pciBusDir, "devices", "0000:00:04.0", "driver_override"))
+	assert.NoError(t, err)
+	assert.Equal(t, "\n", string(content))
+
+	err = unbindPCIDeviceDriver(pciBusDir, "0000:00:05.0", os.WriteFile, filepath.EvalSymlinks)
+	assert.NoError(t, err)
+}
+
+func TestSetPCIDeviceDriverOverride(t *testing.T) {
+	type testCase struct {
+		driver types.DiskDriver
+
+		expected    string
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Set":              {driver: types.DiskDriverVfioPci, expected: "vfio-pci\n"},
+		"Clear":            {driver: types.DiskDriverNone, expected: "\n"},
+		"Not a PCI driver": {driver: types.DiskDriverAio, expectError: true},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			fakeDir := fake.CreateTempDirectory("", t)
+			defer func() {
+				_ = os.RemoveAll(fakeDir)
+			}()
+			pciBusDir := createFakePCIBus(t, fakeDir)
+
+			err := setPCIDeviceDriverOverride(pciBusDir, "0000:00:05.0", testCase.driver, os.WriteFile)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+
+			content, err := os.ReadFile(filepath.Join(pciBusDir, "devices", "0000:00:05.0", "driver_override"))
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, string(content), Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
@@ -65,22 +65,96 @@ func GetKernelRelease() (string, error) {
 
 // GetOSDistro reads the /etc/os-release file and returns the ID field.
 func GetOSDistro(osReleaseContent string) (string, error) {
-	var err error
-	defer func() {
-		err = errors.Wrapf(err, "failed to get host OS distro")
-	}()
+	osRelease, err := ParseOSRelease(osReleaseContent)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get host OS distro")
+	}
+	logrus.Tracef("Found OS distro: %v", osRelease.ID)
+	return osRelease.ID, nil
+}
+
+// osReleaseEscapes are the characters that are escaped with a backslash in
+// the double-quoted os-release values.
+const osReleaseEscapes = `"\$` + "`"
+
+// ParseOSRelease parses the content of the /etc/os-release file. The values can
+// be unquoted, or quoted in single or double quotes, in which the backslash
+// escapes the ", \, $ and ` characters, as defined in os-release(5). The lines
+// that are not assignments are ignored. It returns an error if the ID field
+// is missing.
+func ParseOSRelease(osReleaseContent string) (*types.OSRelease, error) {
+	fields := map[string]string{}
+	for _, line := range strings.Split(osReleaseContent, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = unquoteOSReleaseValue(strings.TrimSpace(value))
+	}
 
-	lines := strings.Split(osReleaseContent, "\n")
-	for _, line := range lines {
-		if strings.HasPrefix(line, "ID=") {
-			id := strings.TrimPrefix(line, "ID=")
-			id = strings.Trim(id, `"`)
-			logrus.Tracef("Found OS distro: %v", id)
-			return id, nil
+	osRelease := &types.OSRelease{
+		ID:              fields["ID"],
+		Name:            fields["NAME"],
+		PrettyName:      fields["PRETTY_NAME"],
+		VersionID:       fields["VERSION_ID"],
+		VersionCodename: fields["VERSION_CODENAME"],
+		VariantID:       fields["VARIANT_ID"],
+	}
+	if osRelease.ID == "" {
+		return nil, errors.Errorf("failed to find ID field in %v", types.OsReleaseFilePath)
+	}
+	if idLike := strings.Fields(fields["ID_LIKE"]); len(idLike) > 0 {
+		osRelease.IDLike = idLike
+	}
+	return osRelease, nil
+}
+
+func unquoteOSReleaseValue(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+
+	switch quote := value[0]; {
+	case quote == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1]
+	case quote == '"' && value[len(value)-1] == '"':
+		value = value[1 : len(value)-1]
+		var builder strings.Builder
+		for i := 0; i < len(value); i++ {
+			if value[i] == '\\' && i+1 < len(value) && strings.IndexByte(osReleaseEscapes, value[i+1]) >= 0 {
+				i++
+			}
+			builder.WriteByte(value[i])
 		}
+		return builder.String()
+	default:
+		return value
+	}
+}
+
+// IsImmutableDistro checks if the OS is an immutable distro, whose root
+// filesystem is read-only and which does not allow installing packages on
+// the host, like Talos, Flatcar, SLE Micro and Bottlerocket. It returns false
+// if the OS release is nil.
+func IsImmutableDistro(osRelease *types.OSRelease) bool {
+	if osRelease == nil {
+		return false
 	}
 
-	return "", errors.Errorf("failed to find ID field in %v", types.OsReleaseFilePath)
+	switch osRelease.ID {
+	case types.OSDistroTalosLinux,
+		types.OSDistroFlatcar,
+		types.OSDistroBottlerocket,
+		types.OSDistroSLEMicro,
+		types.OSDistroSLMicro:
+		return true
+	default:
+		return false
+	}
 }
 
 // GetSystemBlockDeviceInfo returns the block device info for the system.
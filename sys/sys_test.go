@@ -106,6 +106,83 @@ ID_LIKE="suse"`,
 	}
 }
 
+func TestParseOSRelease(t *testing.T) {
+	type testCase struct {
+		mockFileContent string
+
+		expected    *types.OSRelease
+		expectError bool
+	}
+	testCases := map[string]testCase{
+		"Ubuntu": {
+			mockFileContent: `PRETTY_NAME="Ubuntu 22.04.4 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION="22.04.4 LTS (Jammy Jellyfish)"
+VERSION_CODENAME=jammy
+ID=ubuntu
+ID_LIKE=debian
+HOME_URL="https://www.ubuntu.com/"`,
+			expected: &types.OSRelease{
+				ID:              "ubuntu",
+				IDLike:          []string{"debian"},
+				Name:            "Ubuntu",
+				PrettyName:      "Ubuntu 22.04.4 LTS",
+				VersionID:       "22.04",
+				VersionCodename: "jammy",
+			},
+		},
+		"Quotes and escapes": {
+			mockFileContent: `# comment line
+ID='sl-micro'
+ID_LIKE="suse  opensuse"
+NAME='SUSE Linux "Micro"'
+PRETTY_NAME="SUSE \"Linux\" Micro \$6.0 \\ \n"
+VARIANT_ID=""
+
+not an assignment
+`,
+			expected: &types.OSRelease{
+				ID:         "sl-micro",
+				IDLike:     []string{"suse", "opensuse"},
+				Name:       `SUSE Linux "Micro"`,
+				PrettyName: `SUSE "Linux" Micro $6.0 \ \n`,
+			},
+		},
+		"Missing ID": {
+			mockFileContent: `NAME="SLES"
+ID_LIKE="suse"`,
+			expectError: true,
+		},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			result, err := ParseOSRelease(testCase.mockFileContent)
+			if testCase.expectError {
+				assert.Error(t, err, Commentf(test.ErrErrorFmt, testName, err))
+				return
+			}
+			assert.NoError(t, err, Commentf(test.ErrErrorFmt, testName, err))
+			assert.Equal(t, testCase.expected, result, Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}
+
+func TestIsImmutableDistro(t *testing.T) {
+	for distro, expected := range map[string]bool{
+		types.OSDistroTalosLinux:   true,
+		types.OSDistroFlatcar:      true,
+		types.OSDistroBottlerocket: true,
+		types.OSDistroSLEMicro:     true,
+		types.OSDistroSLMicro:      true,
+		"ubuntu":                   false,
+		"sles":                     false,
+	} {
+		assert.Equal(t, expected, IsImmutableDistro(&types.OSRelease{ID: distro}), Commentf(test.ErrResultFmt, distro))
+	}
+	assert.False(t, IsImmutableDistro(nil))
+}
+
 func TestGetSystemBlockDevices(t *testing.T) {
 	fakeDir := fake.CreateTempDirectory("", t)
 	defer func() {
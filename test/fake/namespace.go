@@ -1,6 +1,8 @@
 package fake
 
 import (
+	"context"
+	"strings"
 	"sync"
 	"time"
 )
@@ -61,6 +63,22 @@ func (e *Executor) ExecuteWithStdinPipe(string, []string, string, time.Duration)
 	return e.nextResult()
 }
 
+// ExecuteWithStdinStream returns the next result and calls lineFn for each of
+// its output lines. It returns the context error if ctx is already done.
+func (e *Executor) ExecuteWithStdinStream(ctx context.Context, _ string, _ []string, _ string, lineFn func(string)) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	output, err := e.nextResult()
+	if lineFn != nil {
+		for _, line := range strings.FieldsFunc(output, func(r rune) bool { return r == '\n' || r == '\r' }) {
+			lineFn(line)
+		}
+	}
+	return output, err
+}
+
 type Joiner struct {
 	MockDelay  time.Duration
 	MockResult interface{}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"strings"
 	"time"
 )
 
@@ -13,20 +14,100 @@ const (
 	CryptoPBKDF                = "CRYPTO_PBKDF"
 	CryptoPBKDFForceIterations = "CRYPTO_PBKDF_FORCE_ITERATIONS"
 	CryptoPBKDFMemory          = "CRYPTO_PBKDF_MEMORY"
+	CryptoIntegrity            = "CRYPTO_INTEGRITY"
 
 	CliAPIVersionForSupportingExtendLuks2HeaderSize = 12
 	CliAPIVersionExtraLUKS2HeaderReservation        = CliAPIVersionForSupportingExtendLuks2HeaderSize
 	Luks2EncryptionHeaderSize                       = 16 * 1024 * 1024
 )
 
+// Integrity algorithms supported by LUKS2 authenticated encryption (cryptsetup --integrity).
+const (
+	LuksIntegrityAead       = "aead"
+	LuksIntegrityPoly1305   = "poly1305"
+	LuksIntegrityCmacAes    = "cmac-aes"
+	LuksIntegrityHmacSha1   = "hmac-sha1"
+	LuksIntegrityHmacSha256 = "hmac-sha256"
+	LuksIntegrityHmacSha512 = "hmac-sha512"
+)
+
+// LuksIntegrityTagSizes are the sizes in bytes of the authentication tag stored
+// by dm-integrity for every sector.
+var LuksIntegrityTagSizes = map[string]int64{
+	LuksIntegrityAead:       16,
+	LuksIntegrityPoly1305:   16,
+	LuksIntegrityCmacAes:    16,
+	LuksIntegrityHmacSha1:   20,
+	LuksIntegrityHmacSha256: 32,
+	LuksIntegrityHmacSha512: 64,
+}
+
+const (
+	// dm-integrity defaults used by cryptsetup when formatting a LUKS2 device with integrity.
+	luksIntegritySectorSize        = 512
+	luksIntegrityInterleaveSectors = 32768
+	luksIntegrityMetadataAlignment = 4096
+	luksIntegritySuperblockSize    = 4096
+	luksIntegrityMaxJournalSize    = 64 * 1024 * 1024
+	luksIntegrityJournalSizeShift  = 7 // the journal takes 1/128 of the device, up to luksIntegrityMaxJournalSize
+)
+
 const LuksTimeout = time.Minute
 
 func GetBackendSize(volumeSize int64, encrypted bool, cliAPIVersion int) int64 {
+	return GetBackendSizeWithIntegrity(volumeSize, encrypted, cliAPIVersion, "", "")
+}
+
+// GetBackendSizeWithIntegrity returns the backend size needed for an encrypted volume formatted with the given
+// cipher and integrity algorithm. Besides the LUKS2 header, the size includes the dm-integrity metadata when the
+// integrity is not empty.
+func GetBackendSizeWithIntegrity(volumeSize int64, encrypted bool, cliAPIVersion int, cipher, integrity string) int64 {
 	if volumeSize > 0 && encrypted && cliAPIVersion >= CliAPIVersionForSupportingExtendLuks2HeaderSize {
 		//  The default size is 16MB for the LUKS2 header, so we need to add it to the replica size if the volume is encrypted.
 		//  otherwise, the device that users get will be 16MB smaller than the actual size users want, which will cause some issues as https://github.com/longhorn/longhorn/issues/9205.
 		//  https://gitlab.com/cryptsetup/cryptsetup/-/wikis/FrequentlyAskedQuestions
-		return volumeSize + Luks2EncryptionHeaderSize
+		return volumeSize + Luks2EncryptionHeaderSize + GetLuksIntegrityOverhead(volumeSize, cipher, integrity)
 	}
 	return volumeSize
 }
+
+// GetLuksIntegrityTagSize returns the per-sector tag size in bytes that dm-integrity stores for the cipher and
+// integrity algorithm. A cipher with a random IV (e.g. aes-gcm-random) stores the IV in the tag as well.
+// It returns 0 if the integrity is empty or unknown.
+func GetLuksIntegrityTagSize(cipher, integrity string) int64 {
+	tagSize, ok := LuksIntegrityTagSizes[integrity]
+	if !ok {
+		return 0
+	}
+
+	if strings.HasSuffix(cipher, "-random") {
+		// AEAD modes use a 96-bit nonce, other modes use an IV of the cipher block size.
+		if strings.HasPrefix(cipher, "chacha20") || strings.Contains(cipher, "-gcm-") || strings.Contains(cipher, "-ccm-") {
+			tagSize += 12
+		} else {
+			tagSize += 16
+		}
+	}
+	return tagSize
+}
+
+// GetLuksIntegrityOverhead returns an estimate of the space taken by the dm-integrity metadata (superblock,
+// journal and per-sector tags) for dataSize bytes of data. The estimate is rounded up, so the backend is never too
+// small for the requested data size.
+func GetLuksIntegrityOverhead(dataSize int64, cipher, integrity string) int64 {
+	tagSize := GetLuksIntegrityTagSize(cipher, integrity)
+	if dataSize <= 0 || tagSize == 0 {
+		return 0
+	}
+
+	// The tags are stored in a metadata area in front of every interleave area of data sectors.
+	areaSize := int64(luksIntegrityInterleaveSectors * luksIntegritySectorSize)
+	areas := (dataSize + areaSize - 1) / areaSize
+	metadataPerArea := luksIntegrityInterleaveSectors * tagSize
+	metadataPerArea = (metadataPerArea + luksIntegrityMetadataAlignment - 1) / luksIntegrityMetadataAlignment * luksIntegrityMetadataAlignment
+
+	journalSize := min(int64(luksIntegrityMaxJournalSize), dataSize>>luksIntegrityJournalSizeShift)
+	journalSize = (journalSize + luksIntegrityMetadataAlignment - 1) / luksIntegrityMetadataAlignment * luksIntegrityMetadataAlignment
+
+	return luksIntegritySuperblockSize + journalSize + areas*metadataPerArea
+}
@@ -20,4 +20,5 @@ var (
 	//	}
 
 	ErrNotConfigured = errors.New("is not configured")
+	ErrNotSupported  = errors.New("is not supported")
 )
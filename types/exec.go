@@ -7,9 +7,14 @@ import (
 const (
 	BinaryCryptsetup = "cryptsetup"
 	BinaryFstrim     = "fstrim"
+	BinaryModprobe   = "modprobe"
 )
 
 const (
 	ExecuteNoTimeout      = time.Duration(-1)
 	ExecuteDefaultTimeout = time.Minute
+
+	// ExecuteCancelGracePeriod is how long a cancelled command is given to
+	// exit after being interrupted before it is killed.
+	ExecuteCancelGracePeriod = 30 * time.Second
 )
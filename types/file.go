@@ -1,6 +1,7 @@
 package types
 
 import (
+	"strings"
 	"time"
 )
 
@@ -31,4 +32,192 @@ type DiskStat struct {
 	BlockSize        int64
 	StorageMaximum   int64
 	StorageAvailable int64
+	TotalInodes      int64
+	FreeInodes       int64
+	MountFlags       []string // Flags the filesystem is mounted with, e.g. ro and nodev.
+	FilesystemMagic  int64    // Magic number of the filesystem type, e.g. 0x58465342 for XFS.
+
+	// ProjectQuota is the quota of the project of the path. It is only filled
+	// in by GetDiskStatWithQuota, and is nil if the path has no project ID or
+	// the filesystem does not enforce project quotas.
+	ProjectQuota *ProjectQuota
+
+	// The following fields describe the disk backing the filesystem. Name is
+	// the kernel name of the block device, e.g. nvme0n1p1, and the other
+	// fields are empty if the filesystem has no block device.
+	Model              string
+	Serial             string
+	IsRotational       bool
+	LogicalSectorSize  int64
+	PhysicalSectorSize int64
+}
+
+// ProjectQuota is the usage and the limits of an XFS or ext4 project quota.
+// The limits are 0 if not set.
+type ProjectQuota struct {
+	ProjectID       uint32
+	UsedBytes       int64
+	SoftLimitBytes  int64
+	HardLimitBytes  int64
+	UsedInodes      int64
+	SoftLimitInodes int64
+	HardLimitInodes int64
+}
+
+type FileExtentType string
+
+const (
+	FileExtentTypeData = FileExtentType("data")
+	FileExtentTypeHole = FileExtentType("hole")
+)
+
+// FileExtent is a contiguous range of a file that either contains data or is a hole.
+type FileExtent struct {
+	Offset int64          // Offset of the range in bytes.
+	Length int64          // Length of the range in bytes.
+	Type   FileExtentType // Whether the range contains data or is a hole.
+
+	// The following fields are only filled in by FIEMAP.
+	PhysicalOffset int64 // Offset of the range on the device in bytes.
+	IsUnwritten    bool  // The range is allocated but not written, it reads as zeros.
+	IsShared       bool  // The range is shared with another file, e.g. by a reflink.
+}
+
+// FallocateMode is an operation done with fallocate on a range of a file.
+type FallocateMode string
+
+const (
+	FallocateModePreallocate         = FallocateMode("preallocate")           // Allocate the range and extend the file size.
+	FallocateModePreallocateKeepSize = FallocateMode("preallocate-keep-size") // Allocate the range without changing the file size.
+	FallocateModePunchHole           = FallocateMode("punch-hole")            // Deallocate the range, it reads as zeros.
+	FallocateModeZeroRange           = FallocateMode("zero-range")            // Zero the range, keeping it allocated.
+)
+
+var FallocateModes = []FallocateMode{
+	FallocateModePreallocate,
+	FallocateModePreallocateKeepSize,
+	FallocateModePunchHole,
+	FallocateModeZeroRange,
+}
+
+// CopyProgress is the progress of a copy operation.
+type CopyProgress struct {
+	CopiedBytes int64  // Bytes copied so far, holes included.
+	TotalBytes  int64  // Total bytes of the files to copy.
+	CopiedFiles int64  // Files copied so far.
+	TotalFiles  int64  // Total number of files to copy.
+	CurrentFile string // Source path of the file being copied.
+}
+
+// CopyResult is the summary of a completed copy operation.
+type CopyResult struct {
+	CopiedBytes int64
+	CopiedFiles int64
+
+	// Checksums are the SHA-256 checksums of the copied files in hex, keyed by
+	// the destination path. It is only filled in if checksums are requested.
+	Checksums map[string]string
+}
+
+type ChecksumAlgorithm string
+
+const (
+	ChecksumAlgorithmSHA256   = ChecksumAlgorithm("sha256")
+	ChecksumAlgorithmSHA512   = ChecksumAlgorithm("sha512")
+	ChecksumAlgorithmXXHash64 = ChecksumAlgorithm("xxhash64")
+	ChecksumAlgorithmCRC32C   = ChecksumAlgorithm("crc32c")
+)
+
+// FileChecksum is the checksum of a file in a ChecksumManifest.
+type FileChecksum struct {
+	Path   string `json:"path"`   // Path relative to the manifest root, with forward slashes.
+	Size   int64  `json:"size"`   // Size of the file in bytes.
+	Digest string `json:"digest"` // Hex encoded digest of the file content.
+}
+
+// ChecksumManifest holds the checksums of the regular files of a directory tree.
+type ChecksumManifest struct {
+	Algorithm ChecksumAlgorithm `json:"algorithm"`
+	// Digest is the root digest of the tree, computed over the sorted file
+	// entries. Two trees with the same content have the same root digest.
+	Digest string         `json:"digest"`
+	Files  []FileChecksum `json:"files"`
+}
+
+// FileEventOp is a bit mask of the changes reported by a file watcher.
+type FileEventOp uint32
+
+const (
+	FileEventOpCreate FileEventOp = 1 << iota // The path was created or moved in.
+	FileEventOpWrite                          // The content of the path was modified.
+	FileEventOpRemove                         // The path was removed.
+	FileEventOpRename                         // The path was moved out.
+	FileEventOpChmod                          // The attributes of the path were changed.
+	// FileEventOpResync means that events were lost, e.g. on inotify queue
+	// overflow, and the state of the path must be read again.
+	FileEventOpResync
+)
+
+func (op FileEventOp) String() string {
+	names := []string{"CREATE", "WRITE", "REMOVE", "RENAME", "CHMOD", "RESYNC"}
+	var set []string
+	for i, name := range names {
+		if op&(1<<i) != 0 {
+			set = append(set, name)
+		}
+	}
+	if len(set) == 0 {
+		return "NONE"
+	}
+	return strings.Join(set, "|")
+}
+
+// FileEvent is a change of a path reported by a file watcher.
+type FileEvent struct {
+	Path string
+	Op   FileEventOp
+}
+
+type OpenFileType string
+
+const (
+	OpenFileTypeFd   = OpenFileType("fd")   // The file is open through a file descriptor.
+	OpenFileTypeMmap = OpenFileType("mmap") // The file is memory mapped.
+)
+
+// OpenFile is a file held by a process.
+type OpenFile struct {
+	Path      string       // Path of the file, without the " (deleted)" suffix.
+	IsDeleted bool         // The file was removed while it is held.
+	Type      OpenFileType // How the file is held.
+
+	Pid         int      // ID of the process holding the file.
+	ProcessName string   // Name of the process, as in /proc/<pid>/comm.
+	Cmdline     []string // Command line of the process.
+
+	// The following fields are only filled in for OpenFileTypeFd.
+	Fd     int   // File descriptor number.
+	Flags  int   // Open flags (e.g. O_RDWR|O_DIRECT), as in /proc/<pid>/fdinfo/<fd>.
+	Offset int64 // Current file offset, as in /proc/<pid>/fdinfo/<fd>.
+
+	// The following field is only filled in for OpenFileTypeMmap.
+	MapPermissions string // Permissions of the first mapping of the file (e.g. r-xp).
+}
+
+// DiskUsage is the space used by a directory tree.
+type DiskUsage struct {
+	ApparentSize   int64 // Sum of the non-directory entry sizes in bytes.
+	AllocatedSize  int64 // Sum of the bytes allocated on disk for the non-directory entries.
+	FileCount      int64 // Number of non-directory entries, hard links are counted once.
+	DirectoryCount int64 // Number of directories, including the root.
+
+	// LargestFiles are the files allocating the most space, largest first.
+	LargestFiles []FileUsage
+}
+
+// FileUsage is the space used by a file.
+type FileUsage struct {
+	Path          string
+	ApparentSize  int64
+	AllocatedSize int64
 }
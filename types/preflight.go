@@ -0,0 +1,18 @@
+package types
+
+// PreflightStatus is the outcome of a node preflight check.
+type PreflightStatus string
+
+const (
+	PreflightStatusPass = PreflightStatus("pass")
+	PreflightStatusWarn = PreflightStatus("warn") // The node works with reduced functionality.
+	PreflightStatusFail = PreflightStatus("fail") // The node cannot work.
+)
+
+// PreflightResult is the result of a node preflight check.
+type PreflightResult struct {
+	Name        string          `json:"name"`
+	Status      PreflightStatus `json:"status"`
+	Message     string          `json:"message"`
+	Remediation string          `json:"remediation,omitempty"` // How to fix the node if the check did not pass.
+}
@@ -1,14 +1,35 @@
 package types
 
+import "time"
+
 const OsReleaseFilePath = "/etc/os-release"
 const SysClassBlockDirectory = "/sys/class/block/"
+const SysDevBlockDirectory = "/sys/dev/block/"
 const SysBootDirectory = "/boot/"
 const SysProcDirectory = "/proc/"
 const SysEtcDirectory = "/etc/"
+const SysModuleDirectory = "/sys/module/"
+const SysKernelMMHugepagesDirectory = "/sys/kernel/mm/hugepages/"
+const SysDevicesSystemNodeDirectory = "/sys/devices/system/node/"
+const SysDevicesSystemCPUOnlinePath = "/sys/devices/system/cpu/online"
+const SysBusPCIDirectory = "/sys/bus/pci/"
+const SysBusPCIDevicesDirectory = "/sys/bus/pci/devices/"
+const SysLibModulesDirectory = "/lib/modules/"
 
 const SysKernelConfigGz = "config.gz"
+const SysProcCrypto = "crypto"
+const SysProcModules = "modules"
+const SysProcDiskstats = "diskstats"
+const SysModulesBuiltin = "modules.builtin"
+const SysModulesDep = "modules.dep"
 
-const OSDistroTalosLinux = "talos"
+const (
+	OSDistroTalosLinux   = "talos"
+	OSDistroFlatcar      = "flatcar"
+	OSDistroBottlerocket = "bottlerocket"
+	OSDistroSLEMicro     = "sle-micro"
+	OSDistroSLMicro      = "sl-micro" // SUSE Linux Micro 6.0 and later.
+)
 
 // BlockDeviceInfo is a struct that contains the block device info.
 type BlockDeviceInfo struct {
@@ -16,3 +37,201 @@ type BlockDeviceInfo struct {
 	Major int    // Major number of the block device.
 	Minor int    // Minor number of the block device.
 }
+
+// KernelCryptoAlgorithm is a crypto algorithm registered in the kernel, as listed in /proc/crypto.
+type KernelCryptoAlgorithm struct {
+	Name     string // Name of the algorithm (e.g. aes, xts(aes), sha256, etc.).
+	Driver   string // Name of the driver implementing the algorithm (e.g. xts-aes-aesni).
+	Module   string // Module providing the driver, or "kernel" if it is built in.
+	Type     string // Type of the algorithm (e.g. cipher, skcipher, aead, shash, etc.).
+	Priority int    // Priority of the driver; the kernel picks the highest one for a name.
+}
+
+// BlockDeviceTransport is the bus a block device is attached through.
+type BlockDeviceTransport string
+
+const (
+	BlockDeviceTransportUnknown = BlockDeviceTransport("")
+	BlockDeviceTransportNvme    = BlockDeviceTransport("nvme")
+	BlockDeviceTransportScsi    = BlockDeviceTransport("scsi")
+	BlockDeviceTransportVirtio  = BlockDeviceTransport("virtio")
+	BlockDeviceTransportMmc     = BlockDeviceTransport("mmc")
+	BlockDeviceTransportVirtual = BlockDeviceTransport("virtual") // Devices without hardware, e.g. loop, dm and md.
+)
+
+// BlockDevice is a block device in /sys/class/block.
+type BlockDevice struct {
+	BlockDeviceInfo
+
+	Size         int64 // Size of the device in bytes.
+	IsRemovable  bool
+	IsReadOnly   bool
+	IsRotational bool
+
+	IsPartition bool
+	Parent      string   // Name of the disk of the partition, or empty if it is unknown.
+	Partitions  []string // Names of the partitions of the disk.
+	Holders     []string // Names of the devices stacked on top of the device, e.g. dm-0.
+	Slaves      []string // Names of the devices the device is stacked on.
+
+	LogicalBlockSize   int64
+	PhysicalBlockSize  int64
+	DiscardGranularity int64 // Zero if the device does not support discard.
+	MaxSectorsKB       int64 // Maximum size of a request in KiB.
+
+	WWN       string
+	Serial    string
+	Transport BlockDeviceTransport
+}
+
+// BlockDeviceStackType is the kind of a device in a block device stack.
+type BlockDeviceStackType string
+
+const (
+	BlockDeviceStackTypeDisk         = BlockDeviceStackType("disk")
+	BlockDeviceStackTypePartition    = BlockDeviceStackType("partition")
+	BlockDeviceStackTypeCrypt        = BlockDeviceStackType("crypt")
+	BlockDeviceStackTypeLvm          = BlockDeviceStackType("lvm")
+	BlockDeviceStackTypeMultipath    = BlockDeviceStackType("multipath")
+	BlockDeviceStackTypeRaid         = BlockDeviceStackType("raid")          // md devices.
+	BlockDeviceStackTypeDeviceMapper = BlockDeviceStackType("device-mapper") // Other device mapper targets.
+)
+
+// BlockDeviceStack is a block device and the devices it is stacked on, e.g.
+// an LVM volume on a dm-crypt device on a partition.
+type BlockDeviceStack struct {
+	Name   string // Kernel name of the device (e.g. dm-0, md0, sda1).
+	Path   string // Device path, /dev/mapper/<dm name> for device mapper devices.
+	Type   BlockDeviceStackType
+	DMName string // Device mapper name, empty for the other devices.
+	DMUUID string // Device mapper UUID, its prefix is set by the owner, e.g. CRYPT- or LVM-.
+
+	Slaves []BlockDeviceStack // The devices this device is stacked on.
+}
+
+// MountInfo is a mount of a mount namespace, as listed in /proc/<pid>/mountinfo.
+type MountInfo struct {
+	ID         int      // Unique ID of the mount.
+	ParentID   int      // ID of the parent mount, or of itself for the root of the namespace.
+	Major      int      // Major number of the device of the filesystem.
+	Minor      int      // Minor number of the device of the filesystem.
+	Root       string   // Directory of the filesystem mounted at MountPoint, not "/" for bind mounts.
+	MountPoint string   // Mount point relative to the root of the process.
+	Options    []string // Per-mount options (e.g. rw, nosuid, relatime).
+
+	// The propagation of the mount. The peer group IDs are 0 if not set.
+	SharedPeerGroup int  // Peer group the mount shares events with (shared:N).
+	MasterPeerGroup int  // Peer group the mount receives events from (master:N).
+	PropagateFrom   int  // Closest dominant peer group of a slave mount in the namespace (propagate_from:N).
+	IsUnbindable    bool // The mount cannot be bind mounted (unbindable).
+
+	FSType       string   // Filesystem type, with the subtype if any (e.g. fuse.sshfs).
+	Source       string   // Filesystem specific source, e.g. /dev/sda1, or "none".
+	SuperOptions []string // Per-superblock options.
+}
+
+// KernelModule is a loaded kernel module, as listed in /proc/modules.
+type KernelModule struct {
+	Name       string   // Name of the module (e.g. dm_crypt).
+	Size       int64    // Memory size of the module in bytes.
+	RefCount   int      // Number of references to the module.
+	Dependents []string // Modules using the module.
+	State      string   // Load state (Live, Loading or Unloading).
+}
+
+// KernelFeatureState is the availability of a kernel feature.
+type KernelFeatureState string
+
+const (
+	KernelFeatureStateBuiltin     = KernelFeatureState("builtin")     // Built into the kernel (=y).
+	KernelFeatureStateLoaded      = KernelFeatureState("loaded")      // Built as a module that is loaded (=m).
+	KernelFeatureStateNotLoaded   = KernelFeatureState("not-loaded")  // Built as a module that is not loaded (=m).
+	KernelFeatureStateUnavailable = KernelFeatureState("unavailable") // Not built.
+)
+
+// OSRelease is the operating system identification, as described in os-release(5).
+type OSRelease struct {
+	ID              string   // Lower-case identifier of the OS (e.g. ubuntu, sles, talos).
+	IDLike          []string // Identifiers of the OSes the OS is derived from, closest first.
+	Name            string   // Name of the OS (e.g. Ubuntu).
+	PrettyName      string   // Name of the OS for presentation (e.g. Ubuntu 22.04.4 LTS).
+	VersionID       string   // Lower-case version of the OS (e.g. 22.04).
+	VersionCodename string   // Lower-case release code name of the OS (e.g. jammy).
+	VariantID       string   // Lower-case identifier of the variant of the OS (e.g. server).
+}
+
+// Hugepages is the pool of the huge pages of a size, system-wide or on a NUMA node.
+type Hugepages struct {
+	Size     int64 // Size of a huge page in bytes.
+	Total    int64 // Number of huge pages in the pool.
+	Free     int64 // Number of huge pages not allocated.
+	Surplus  int64 // Number of huge pages above Total allocated on demand.
+	Reserved int64 // Number of free huge pages reserved for allocations. It is only reported system-wide.
+}
+
+// NUMANode is a NUMA node of the system.
+type NUMANode struct {
+	ID        int
+	CPUs      []int       // CPUs of the node, in ascending order.
+	Hugepages []Hugepages // Huge page pools of the node, in ascending order of size.
+}
+
+// PCIClassNVMe is the class code of the NVMe controllers.
+const PCIClassNVMe = "010802"
+
+// PCIDevice is a PCI device, as listed in /sys/bus/pci/devices.
+type PCIDevice struct {
+	Address    string     // Address of the device in the domain:bus:device.function form (e.g. 0000:00:04.0).
+	Vendor     string     // Vendor ID of the device in hex (e.g. 144d).
+	Device     string     // Device ID of the device in hex (e.g. a808).
+	Class      string     // Class code of the device in hex (e.g. 010802 for NVMe).
+	Driver     string     // Name of the kernel driver bound to the device, or empty if unbound.
+	DiskDriver DiskDriver // Disk driver of the kernel driver, or DiskDriverNone if it is not a disk driver.
+	IOMMUGroup int        // IOMMU group of the device, or -1 if the IOMMU is disabled.
+	NUMANode   int        // NUMA node of the device, or -1 if the device has no NUMA affinity.
+}
+
+// DiskIOStats are the cumulative I/O counters of a block device since boot,
+// as reported by /proc/diskstats and /sys/class/block/<device>/stat. The
+// discard and flush counters are zero on kernels that do not report them.
+type DiskIOStats struct {
+	BlockDeviceInfo
+
+	ReadsCompleted  uint64
+	ReadsMerged     uint64
+	SectorsRead     uint64 // Number of 512-byte sectors read.
+	ReadTime        time.Duration
+	WritesCompleted uint64
+	WritesMerged    uint64
+	SectorsWritten  uint64 // Number of 512-byte sectors written.
+	WriteTime       time.Duration
+	IOsInProgress   uint64        // Number of I/Os in flight. It is not cumulative.
+	IOTime          time.Duration // Time the device had I/Os in flight.
+	WeightedIOTime  time.Duration // Time the I/Os spent in flight, summed over the I/Os.
+
+	DiscardsCompleted uint64
+	DiscardsMerged    uint64
+	SectorsDiscarded  uint64
+	DiscardTime       time.Duration
+
+	FlushesCompleted uint64
+	FlushTime        time.Duration
+
+	Timestamp time.Time // Time the counters were read.
+}
+
+// DiskIORates are the I/O rates of a block device between two DiskIOStats samples.
+type DiskIORates struct {
+	BlockDeviceInfo
+
+	ReadIOPS            float64
+	WriteIOPS           float64
+	ReadBytesPerSecond  float64
+	WriteBytesPerSecond float64
+	ReadLatency         time.Duration // Average time of the completed reads.
+	WriteLatency        time.Duration // Average time of the completed writes.
+	Utilization         float64       // Fraction of the interval the device had I/Os in flight, from 0 to 1.
+	InFlight            uint64        // Number of I/Os in flight at the end of the interval.
+	Interval            time.Duration // Time between the samples.
+	Timestamp           time.Time     // Time of the later sample.
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ReadSysfsString returns the trimmed content of the sysfs attribute read with
+// readFileFn, or an empty string if it cannot be read.
+func ReadSysfsString(path string, readFileFn func(string) ([]byte, error)) string {
+	content, err := readFileFn(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// ReadSysfsInt64 returns the sysfs attribute read with readFileFn as an
+// integer, or 0 if it cannot be read.
+func ReadSysfsInt64(path string, readFileFn func(string) ([]byte, error)) int64 {
+	value, err := strconv.ParseInt(ReadSysfsString(path, readFileFn), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	. "gopkg.in/check.v1"
+
+	"github.com/longhorn/go-common-libs/test"
+)
+
+func TestReadSysfs(t *testing.T) {
+	files := map[string]string{
+		"size":    "1024\n",
+		"model":   "Fake Disk   \n",
+		"invalid": "none\n",
+	}
+	readFileFn := func(path string) ([]byte, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, fmt.Errorf("%v not found", path)
+		}
+		return []byte(content), nil
+	}
+
+	type testCase struct {
+		path string
+
+		expectedString string
+		expectedInt64  int64
+	}
+	testCases := map[string]testCase{
+		"Integer":    {path: "size", expectedString: "1024", expectedInt64: 1024},
+		"String":     {path: "model", expectedString: "Fake Disk"},
+		"Not number": {path: "invalid", expectedString: "none"},
+		"Not exist":  {path: "serial"},
+	}
+	for testName, testCase := range testCases {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedString, ReadSysfsString(testCase.path, readFileFn), Commentf(test.ErrResultFmt, testName))
+			assert.Equal(t, testCase.expectedInt64, ReadSysfsInt64(testCase.path, readFileFn), Commentf(test.ErrResultFmt, testName))
+		})
+	}
+}